@@ -0,0 +1,68 @@
+// Texture Array GL Helper Functions
+package glf
+
+import (
+	"errors"
+	"fmt"
+	"image"
+
+	"github.com/go-gl/gl/v4.6-core/gl"
+)
+
+// Creates and binds a 2D array texture ID via a uint32 ID
+func GenBindTextureArray() uint32 {
+    var texID uint32
+    gl.GenTextures(1, &texID)
+    BindTextureArray(texID)
+    return texID
+}
+
+// Binds a 2D array texture ID via a uint32 ID
+func BindTextureArray(texID uint32) {
+    gl.BindTexture(gl.TEXTURE_2D_ARRAY, texID)
+}
+
+// Creates a GL_TEXTURE_2D_ARRAY with one layer per image, every image has to be the same size
+//
+// Shaders pick the layer with the third texture coordinate of a sampler2DArray, so sprites or tiles in
+// different layers can be drawn without rebinding. The options work as they do for NewTextureFromImage.
+func NewTextureArray(images []image.Image, opts TextureOptions) (uint32, error) {
+    if len(images) == 0 {
+        return 0, errors.New("a texture array needs at least one image")
+    }
+    size := images[0].Bounds().Size()
+    for i, img := range images {
+        if img.Bounds().Size() != size {
+            return 0, fmt.Errorf("texture array layer %d is %v, the first layer is %v", i, img.Bounds().Size(), size)
+        }
+    }
+    opts = opts.withDefaults()
+    if opts.NoMipmaps && isMipmapFilter(opts.MinFilter) {
+        return 0, errors.New("a mipmap min filter needs mipmaps, but NoMipmaps is set")
+    }
+
+    texture := GenBindTextureArray()
+    setTextureParameters(gl.TEXTURE_2D_ARRAY, opts)
+    gl.TexImage3D(gl.TEXTURE_2D_ARRAY, 0, opts.InternalFormat, int32(size.X), int32(size.Y), int32(len(images)), 0,
+        gl.RGBA, gl.UNSIGNED_BYTE, nil)
+    for layer, img := range images {
+        texSubImageLayer(layer, img, opts)
+    }
+    if !opts.NoMipmaps {
+        gl.GenerateMipmap(gl.TEXTURE_2D_ARRAY)
+    }
+    return texture, nil
+}
+
+// Loads same sized texture files into the layers of a 2D array texture in order, see NewTextureArray
+func LoadTextureArray(filePaths []string, opts TextureOptions) (uint32, error) {
+    images := make([]image.Image, len(filePaths))
+    for i, filePath := range filePaths {
+        var err error
+        images[i], _, err = DecodeTextureFile(filePath)
+        if err != nil {
+            return 0, err
+        }
+    }
+    return NewTextureArray(images, opts)
+}
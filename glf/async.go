@@ -0,0 +1,437 @@
+// Asynchronous Texture Loading GL Helper Functions
+package glf
+
+import (
+	"bufio"
+	"context"
+	"errors"
+	"fmt"
+	"image/color"
+	"io"
+	"os"
+	"runtime"
+	"sync"
+	"sync/atomic"
+	"unsafe"
+
+	"github.com/go-gl/gl/v4.6-core/gl"
+)
+
+// Color of the 1x1 placeholder a TextureLoader fills a texture with until its contents are ready
+var PlaceholderColor = color.NRGBA{128, 128, 128, 255}
+
+// Bytes a TextureLoader streams into textures per Update when its UploadBudget is 0
+const DefaultUploadBudget = 4 << 20
+
+// TextureLoader decodes texture files on worker goroutines and streams them into a pixel buffer object a
+// band of rows at a time, so loading doesn't stall the GL thread, then fills the texture from it in one go
+//
+// With a Thread the uploads are queued on it and Load can be called from any goroutine. Otherwise Load,
+// which creates the texture, and Update have to be called from the thread the context is current on,
+// Update regularly, usually once a frame.
+type TextureLoader struct {
+    Thread          *GLThread
+    UploadBudget    int     // Bytes uploaded per Update, DefaultUploadBudget when 0
+    pbo             uint32
+    slots           chan struct{}
+    mutex           sync.Mutex
+    uploads         []*AsyncTexture
+    pending         atomic.Int64
+    scheduled       atomic.Bool
+    workers         sync.WaitGroup
+    ctx             context.Context
+    cancel          context.CancelFunc
+}
+
+// AsyncTexture is a texture a TextureLoader is loading
+type AsyncTexture struct {
+    FilePath    string
+    Options     TextureOptions
+    loader      *TextureLoader
+    texture     atomic.Uint32   // Holds the placeholder until ready, 0 once deleted
+    ready       atomic.Bool
+    pixels      texturePixels
+    compressed  *CompressedTexture
+    width       int
+    height      int
+    fileSize    atomic.Int64
+    read        atomic.Int64    // Bytes of the file decoded so far
+    rows        atomic.Int64    // Rows to upload, known once decoded, 1 for a compressed texture
+    uploaded    atomic.Int64    // Rows uploaded so far
+    ctx         context.Context
+    cancel      context.CancelFunc
+    done        chan struct{}
+    err         error
+    finishOnce  sync.Once
+}
+
+// Creates a TextureLoader with (workers) decoding goroutines, runtime.NumCPU() when 0
+//
+// (thread) is the GL thread to upload on, or nil to upload in Update. Without a thread it has to be
+// called on the thread the context is current on.
+func NewTextureLoader(thread *GLThread, workers int) *TextureLoader {
+    if workers <= 0 {
+        workers = runtime.NumCPU()
+    }
+    loader := &TextureLoader{
+        Thread: thread,
+        slots:  make(chan struct{}, workers),
+    }
+    loader.ctx, loader.cancel = context.WithCancel(context.Background())
+    loader.onThread(func() {
+        gl.GenBuffers(1, &loader.pbo)
+    })
+    return loader
+}
+
+// Creates a 2D texture holding one PlaceholderColor pixel, with the sampling parameters of (opts)
+func newPlaceholderTexture(opts TextureOptions) uint32 {
+    texture := GenBindTexture()
+    setTextureParameters(gl.TEXTURE_2D, opts)
+    pixel := []uint8{PlaceholderColor.R, PlaceholderColor.G, PlaceholderColor.B, PlaceholderColor.A}
+    gl.TexImage2D(gl.TEXTURE_2D, 0, gl.RGBA8, 1, 1, 0, gl.RGBA, gl.UNSIGNED_BYTE, gl.Ptr(pixel))
+    return texture
+}
+
+// Runs f on the loader's thread if it has one, or right away
+func (loader *TextureLoader) onThread(f func()) {
+    if loader.Thread != nil {
+        loader.Thread.Call(f)
+    } else {
+        f()
+    }
+}
+
+// Starts loading a texture file in the background and returns its handle right away
+//
+// The handle's texture exists from the start, holding a 1x1 PlaceholderColor pixel until the file's contents
+// replace it. DDS and KTX2 files are decoded in the background too, but go to GL in one piece since they're
+// already compressed.
+func (loader *TextureLoader) Load(filePath string, opts TextureOptions) *AsyncTexture {
+    tex := &AsyncTexture{FilePath: filePath, Options: opts.withDefaults(), loader: loader, done: make(chan struct{})}
+    tex.ctx, tex.cancel = context.WithCancel(loader.ctx)
+    loader.onThread(func() {
+        tex.texture.Store(newPlaceholderTexture(tex.Options))
+    })
+    loader.pending.Add(1)
+    if tex.Options.NoMipmaps && isMipmapFilter(tex.Options.MinFilter) {
+        tex.finish(errors.New("a mipmap min filter needs mipmaps, but NoMipmaps is set"))
+        return tex
+    }
+    loader.workers.Add(1)
+    go loader.decode(tex)
+    return tex
+}
+
+// Number of loads that are neither ready nor failed yet
+func (loader *TextureLoader) Pending() int {
+    return int(loader.pending.Load())
+}
+
+// Decodes a texture file on a worker slot and queues its pixels for upload
+func (loader *TextureLoader) decode(tex *AsyncTexture) {
+    defer loader.workers.Done()
+    select {
+    case loader.slots <- struct{}{}:
+        defer func() {
+            <-loader.slots
+        }()
+    case <-tex.ctx.Done():
+        tex.finish(tex.ctx.Err())
+        return
+    }
+
+    err := tex.decodeFile()
+    if err == nil {
+        err = tex.ctx.Err()
+    }
+    if err != nil {
+        tex.finish(err)
+        return
+    }
+    loader.mutex.Lock()
+    loader.uploads = append(loader.uploads, tex)
+    loader.mutex.Unlock()
+    loader.schedule()
+}
+
+// Reads and decodes the file into pixels ready for GL, counting the bytes read for Progress
+func (tex *AsyncTexture) decodeFile() error {
+    file, err := os.Open(tex.FilePath)
+    if err != nil {
+        return err
+    }
+    defer file.Close()
+    if info, err := file.Stat(); err == nil {
+        tex.fileSize.Store(info.Size())
+    }
+
+    img, compressed, err := decodeAnyTexture(bufio.NewReader(&progressReader{file, tex.ctx, &tex.read}))
+    if err != nil {
+        return fmt.Errorf("%s: %w", tex.FilePath, err)
+    }
+    if compressed != nil {
+        tex.compressed = compressed
+        tex.rows.Store(1)
+        return nil
+    }
+    tex.width, tex.height = img.Bounds().Dx(), img.Bounds().Dy()
+    tex.pixels = imagePixels(img, isSingleChannel(tex.Options.InternalFormat), tex.Options.PremultiplyAlpha)
+    tex.rows.Store(int64(tex.height))
+    return nil
+}
+
+// Reader that counts the bytes read and stops once its context is cancelled
+type progressReader struct {
+    reader  io.Reader
+    ctx     context.Context
+    read    *atomic.Int64
+}
+
+func (r *progressReader) Read(p []byte) (int, error) {
+    if err := r.ctx.Err(); err != nil {
+        return 0, err
+    }
+    n, err := r.reader.Read(p)
+    r.read.Add(int64(n))
+    return n, err
+}
+
+// Queues an Update on the loader's thread, if it has one and one isn't queued already
+func (loader *TextureLoader) schedule() {
+    if loader.Thread == nil || !loader.scheduled.CompareAndSwap(false, true) {
+        return
+    }
+    loader.Thread.Submit(func() {
+        loader.scheduled.Store(false)
+        loader.Update()
+    })
+}
+
+// Streams up to UploadBudget bytes of decoded textures into GL, and returns how many became ready
+//
+// Has to be called on the thread the context is current on. With a Thread the loader calls it itself.
+func (loader *TextureLoader) Update() int {
+    budget := loader.UploadBudget
+    if budget <= 0 {
+        budget = DefaultUploadBudget
+    }
+    ready := 0
+    for budget > 0 {
+        loader.mutex.Lock()
+        if len(loader.uploads) == 0 {
+            loader.mutex.Unlock()
+            break
+        }
+        tex := loader.uploads[0]
+        loader.mutex.Unlock()
+
+        finished, err := loader.upload(tex, &budget)
+        if err == nil && !finished {
+            continue
+        }
+        if err == nil {
+            err = tex.ctx.Err() // Cancelled while the last band went up
+        }
+        loader.mutex.Lock()
+        loader.uploads = loader.uploads[1:]
+        loader.mutex.Unlock()
+        if err != nil {
+            tex.finish(err)
+            continue
+        }
+        tex.ready.Store(true)
+        tex.finish(nil)
+        ready++
+    }
+
+    loader.mutex.Lock()
+    more := len(loader.uploads) > 0
+    loader.mutex.Unlock()
+    if more {
+        loader.schedule()
+    }
+    return ready
+}
+
+// Copies the next band of rows of (tex) into the PBO, taking what it copies from (budget), and fills the
+// texture from the PBO once every row is there
+func (loader *TextureLoader) upload(tex *AsyncTexture, budget *int) (bool, error) {
+    if err := tex.ctx.Err(); err != nil {
+        return false, err
+    }
+    if tex.compressed != nil {
+        err := uploadCompressed(tex.texture.Load(), tex.compressed, tex.Options)
+        for _, level := range tex.compressed.Levels {
+            *budget -= len(level)
+        }
+        tex.compressed = nil
+        if err != nil {
+            return false, fmt.Errorf("%s: %w", tex.FilePath, err)
+        }
+        tex.uploaded.Store(1)
+        return true, nil
+    }
+
+    rowSize := rowBytes(tex.pixels, tex.width)
+    first := int(tex.uploaded.Load())
+    gl.BindBuffer(gl.PIXEL_UNPACK_BUFFER, loader.pbo)
+    defer gl.BindBuffer(gl.PIXEL_UNPACK_BUFFER, 0)
+    if first == 0 {
+        gl.BufferData(gl.PIXEL_UNPACK_BUFFER, rowSize*tex.height, nil, gl.STREAM_DRAW)
+    }
+    rows := min(max(*budget/max(rowSize, 1), 1), tex.height-first)
+    if rows > 0 {
+        data := pixelBytes(tex.pixels)[first*rowSize : (first+rows)*rowSize]
+        mapped := gl.MapBufferRange(gl.PIXEL_UNPACK_BUFFER, first*rowSize, len(data), gl.MAP_WRITE_BIT|gl.MAP_INVALIDATE_RANGE_BIT)
+        if mapped == nil {
+            return false, fmt.Errorf("%s: mapping the pixel buffer failed", tex.FilePath)
+        }
+        copy(unsafe.Slice((*byte)(mapped), len(data)), data)
+        gl.UnmapBuffer(gl.PIXEL_UNPACK_BUFFER)
+        tex.uploaded.Add(int64(rows))
+        *budget -= len(data)
+    }
+    if first+rows < tex.height {
+        return false, nil
+    }
+
+    // The placeholder stays in place until this replaces it with every row at once
+    BindTexture(tex.texture.Load())
+    gl.PixelStorei(gl.UNPACK_ALIGNMENT, 1)
+    gl.TexImage2D(gl.TEXTURE_2D, 0, tex.Options.InternalFormat, int32(tex.width), int32(tex.height), 0,
+        tex.pixels.format, tex.pixels.xtype, gl.PtrOffset(0))
+    gl.PixelStorei(gl.UNPACK_ALIGNMENT, 4)
+    gl.BufferData(gl.PIXEL_UNPACK_BUFFER, 0, nil, gl.STREAM_DRAW)
+    if !tex.Options.NoMipmaps {
+        gl.GenerateMipmap(gl.TEXTURE_2D)
+    }
+    tex.pixels = texturePixels{}
+    return true, nil
+}
+
+// Returns the bytes in one row of (width) pixels
+func rowBytes(pixels texturePixels, width int) int {
+    channels := 4
+    if pixels.format == gl.RED {
+        channels = 1
+    }
+    if pixels.xtype == gl.FLOAT {
+        return width * channels * 4
+    }
+    return width * channels
+}
+
+// Returns the pixel data as bytes without copying it
+func pixelBytes(pixels texturePixels) []byte {
+    switch data := pixels.data.(type) {
+    case []uint8:
+        return data
+    case []float32:
+        if len(data) == 0 {
+            return nil
+        }
+        return unsafe.Slice((*byte)(unsafe.Pointer(&data[0])), len(data)*4)
+    }
+    return nil
+}
+
+// Cancels every load, waits for the workers, and deletes the pixel buffer
+//
+// The textures are left alone, ready or not, Delete frees them. Without a Thread it has to be called on the
+// thread the context is current on, with one it has to be called before the thread is closed.
+func (loader *TextureLoader) Close() {
+    loader.cancel()
+    loader.workers.Wait()
+    loader.onThread(func() {
+        loader.mutex.Lock()
+        uploads := loader.uploads
+        loader.uploads = nil
+        loader.mutex.Unlock()
+        for _, tex := range uploads {
+            tex.finish(context.Canceled)
+        }
+        gl.DeleteBuffers(1, &loader.pbo)
+    })
+}
+
+// Records the result of the load and wakes anything waiting on it, only the first call counts
+func (tex *AsyncTexture) finish(err error) {
+    tex.finishOnce.Do(func() {
+        tex.err = err
+        tex.loader.pending.Add(-1)
+        close(tex.done)
+    })
+}
+
+// Returns the 2D texture, which holds the placeholder pixel until it's ready, and keeps it if loading fails
+//
+// The same texture is given out before and after it's ready, so it can be stored at load time. 0 after Delete.
+func (tex *AsyncTexture) Texture() uint32 {
+    return tex.texture.Load()
+}
+
+// Returns true once the texture is ready to use
+func (tex *AsyncTexture) Ready() bool {
+    return tex.ready.Load()
+}
+
+// Returns a channel that's closed once the load is ready, has failed or was cancelled
+func (tex *AsyncTexture) Done() <-chan struct{} {
+    return tex.done
+}
+
+// Returns why the load failed, or nil if it's ready or still going
+func (tex *AsyncTexture) Err() error {
+    select {
+    case <-tex.done:
+        return tex.err
+    default:
+        return nil
+    }
+}
+
+// Waits for the load to finish, returning its error, or the context's if that's done first
+//
+// Without a Thread this mustn't be called on the GL thread, nothing would call Update
+func (tex *AsyncTexture) Wait(ctx context.Context) error {
+    select {
+    case <-tex.done:
+        return tex.err
+    case <-ctx.Done():
+        return ctx.Err()
+    }
+}
+
+// Returns how far along the load is from 0 to 1, the first half is decoding and the second uploading
+func (tex *AsyncTexture) Progress() float32 {
+    if tex.Ready() {
+        return 1
+    }
+    var decoded, uploaded float32
+    if size := tex.fileSize.Load(); size > 0 {
+        decoded = min(float32(tex.read.Load())/float32(size), 1)
+    }
+    if rows := tex.rows.Load(); rows > 0 {
+        uploaded = float32(tex.uploaded.Load()) / float32(rows)
+    }
+    return decoded/2 + uploaded/2
+}
+
+// Stops the load, the texture keeps the placeholder and Err returns context.Canceled
+//
+// A texture that's already ready is kept, use Delete to free it
+func (tex *AsyncTexture) Cancel() {
+    tex.cancel()
+    tex.finish(context.Canceled)
+}
+
+// Cancels the load if it's still going, and deletes the texture, ready or not
+func (tex *AsyncTexture) Delete() {
+    tex.Cancel()
+    if texture := tex.texture.Swap(0); texture != 0 {
+        tex.loader.onThread(func() {
+            gl.DeleteTextures(1, &texture)
+        })
+    }
+}
@@ -0,0 +1,178 @@
+package glf
+
+import (
+	"context"
+	"image"
+	"image/color"
+	"image/png"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/go-gl/gl/v4.6-core/gl"
+)
+
+// Writes a 2x2 PNG with a different color in each corner, returning its path and the image
+func writeTestPNG(t *testing.T) (string, *image.NRGBA) {
+    img := image.NewNRGBA(image.Rect(0, 0, 2, 2))
+    img.SetNRGBA(0, 0, color.NRGBA{255, 0, 0, 255})
+    img.SetNRGBA(1, 0, color.NRGBA{0, 255, 0, 255})
+    img.SetNRGBA(0, 1, color.NRGBA{0, 0, 255, 255})
+    img.SetNRGBA(1, 1, color.NRGBA{255, 255, 255, 255})
+    filePath := filepath.Join(t.TempDir(), "corners.png")
+    file, err := os.Create(filePath)
+    if err != nil {
+        t.Fatal(err)
+    }
+    defer file.Close()
+    if err := png.Encode(file, img); err != nil {
+        t.Fatal(err)
+    }
+    return filePath, img
+}
+
+// The texture handed out by Load has to be the one the contents end up in, so it can be kept from the start
+func TestAsyncTextureKeepsItsTexture(t *testing.T) {
+    ctx, err := NewThreadedComputeContext(DefaultContextBackend)
+    if err != nil {
+        t.Skipf("no GL context could be created: %v", err)
+    }
+    defer ctx.Release()
+    filePath, want := writeTestPNG(t)
+
+    ctx.Do(func() {
+        // Without a Thread nothing is uploaded until Update, so the placeholder is still there
+        loader := NewTextureLoader(nil, 1)
+        defer loader.Close()
+        tex := loader.Load(filePath, TextureOptions{NoMipmaps: true, MinFilter: gl.NEAREST})
+        defer tex.Delete()
+        texture := tex.Texture()
+        if texture == 0 {
+            t.Fatal("Load gave no texture")
+        }
+        placeholder, err := ReadTexture(texture, 0, false)
+        if err != nil {
+            t.Fatal(err)
+        }
+        if placeholder.Rect.Dx() != 1 || placeholder.NRGBAAt(0, 0) != PlaceholderColor {
+            t.Errorf("the texture starts out as %v with %v, want 1x1 %v", placeholder.Rect, placeholder.NRGBAAt(0, 0), PlaceholderColor)
+        }
+
+        deadline := time.Now().Add(10 * time.Second)
+        for !tex.Ready() && tex.Err() == nil && time.Now().Before(deadline) {
+            loader.Update()
+            time.Sleep(time.Millisecond)
+        }
+        if !tex.Ready() {
+            t.Fatalf("the texture isn't ready, error %v", tex.Err())
+        }
+        if tex.Texture() != texture {
+            t.Errorf("the ready texture is %d, want the %d Load gave out", tex.Texture(), texture)
+        }
+        if tex.Progress() != 1 {
+            t.Errorf("a ready texture's progress is %v", tex.Progress())
+        }
+        got, err := ReadTexture(texture, 0, false)
+        if err != nil {
+            t.Fatal(err)
+        }
+        if got.Rect != want.Rect {
+            t.Fatalf("the texture is %v, want %v", got.Rect, want.Rect)
+        }
+        for y := 0; y < 2; y++ {
+            for x := 0; x < 2; x++ {
+                if got.NRGBAAt(x, y) != want.NRGBAAt(x, y) {
+                    t.Errorf("pixel (%d, %d) is %v, want %v", x, y, got.NRGBAAt(x, y), want.NRGBAAt(x, y))
+                }
+            }
+        }
+    })
+}
+
+// A load that fails keeps the placeholder in the texture it gave out
+func TestAsyncTextureFailureKeepsPlaceholder(t *testing.T) {
+    ctx, err := NewThreadedComputeContext(DefaultContextBackend)
+    if err != nil {
+        t.Skipf("no GL context could be created: %v", err)
+    }
+    defer ctx.Release()
+
+    loader := NewTextureLoader(ctx.Thread, 1)
+    defer loader.Close()
+    tex := loader.Load(filepath.Join(t.TempDir(), "missing.png"), TextureOptions{})
+    defer tex.Delete()
+    <-tex.Done()
+    if tex.Err() == nil || tex.Ready() {
+        t.Fatal("loading a missing file didn't fail")
+    }
+    ctx.Do(func() {
+        placeholder, err := ReadTexture(tex.Texture(), 0, false)
+        if err != nil {
+            t.Fatal(err)
+        }
+        if placeholder.NRGBAAt(0, 0) != PlaceholderColor {
+            t.Errorf("a failed texture holds %v, want %v", placeholder.NRGBAAt(0, 0), PlaceholderColor)
+        }
+    })
+}
+
+// Compressed files are uploaded in one piece, so their progress has to go from half way to done
+func TestAsyncTextureProgress(t *testing.T) {
+    tex := &AsyncTexture{}
+    tex.fileSize.Store(100)
+    tex.read.Store(50)
+    if got := tex.Progress(); got != 0.25 {
+        t.Errorf("half decoded gives %v, want 0.25", got)
+    }
+    tex.read.Store(100)
+    tex.rows.Store(1)
+    if got := tex.Progress(); got != 0.5 {
+        t.Errorf("a decoded compressed texture gives %v, want 0.5", got)
+    }
+    tex.rows.Store(8)
+    tex.uploaded.Store(2)
+    if got := tex.Progress(); got != 0.625 {
+        t.Errorf("a quarter of the rows uploaded gives %v, want 0.625", got)
+    }
+    tex.ready.Store(true)
+    if got := tex.Progress(); got != 1 {
+        t.Errorf("a ready texture gives %v, want 1", got)
+    }
+}
+
+// A compressed file goes into the texture Load gave out in one piece
+func TestAsyncTextureCompressed(t *testing.T) {
+    ctx, err := NewThreadedComputeContext(DefaultContextBackend)
+    if err != nil {
+        t.Skipf("no GL context could be created: %v", err)
+    }
+    defer ctx.Release()
+
+    filePath := filepath.Join(t.TempDir(), "bc4.dds")
+    file := makeDDS(ddsHeader{width: 4, height: 4, levels: 1, pixelFlags: ddsPixelFormatFourCC, fourCC: "ATI1"}, testBytes(8, 0))
+    if err := os.WriteFile(filePath, file, 0o644); err != nil {
+        t.Fatal(err)
+    }
+
+    loader := NewTextureLoader(ctx.Thread, 1)
+    defer loader.Close()
+    tex := loader.Load(filePath, TextureOptions{})
+    defer tex.Delete()
+    texture := tex.Texture()
+    if err := tex.Wait(context.Background()); err != nil {
+        t.Fatal(err)
+    }
+    if tex.Texture() != texture || tex.Progress() != 1 {
+        t.Errorf("texture %d became %d with progress %v", texture, tex.Texture(), tex.Progress())
+    }
+    var width, format int32
+    ctx.Do(func() {
+        BindTexture(texture)
+        gl.GetTexLevelParameteriv(gl.TEXTURE_2D, 0, gl.TEXTURE_WIDTH, &width)
+        gl.GetTexLevelParameteriv(gl.TEXTURE_2D, 0, gl.TEXTURE_INTERNAL_FORMAT, &format)
+    })
+    if width != 4 || format != gl.COMPRESSED_RED_RGTC1 {
+        t.Errorf("the texture is %d wide in format 0x%X, want 4 wide in BC4", width, format)
+    }
+}
@@ -0,0 +1,259 @@
+// Texture Atlas GL Helper Functions
+package glf
+
+import (
+	"errors"
+	"fmt"
+	"image"
+	"image/draw"
+	"sort"
+
+	"github.com/go-gl/gl/v4.6-core/gl"
+)
+
+// AtlasRegion is where an image ended up in an Atlas
+type AtlasRegion struct {
+    X, Y            int         // Top left pixel of the image, padding excluded
+    Width, Height   int
+    UV              [4]float32  // u0, v0, u1, v1, with v0 at the image's top row, the first row uploaded
+}
+
+// Atlas packs images of different sizes into one 2D texture, so they can be drawn without rebinding
+//
+// Every image gets (Padding) pixels of space around it so filtering doesn't bleed neighbours into it.
+// With (Extrude) set the padding repeats the image's edge pixels instead of staying transparent, which
+// also keeps clamped sampling and lower mipmaps right at the edges. Images can be added at any time,
+// each Add uploads just that image.
+type Atlas struct {
+    Texture     uint32
+    Width       int
+    Height      int
+    Padding     int
+    Extrude     bool
+    Options     TextureOptions
+    regions     map[string]AtlasRegion
+    skyline     []skylineNode
+}
+
+// A horizontal segment of the packed area's bottom edge, everything above y across it is taken
+type skylineNode struct {
+    x, y, width int
+}
+
+// Creates an empty, transparent atlas texture of (width) x (height) pixels
+//
+// The options work as they do for NewTextureFromImage, mipmaps are regenerated after every Add.
+func NewAtlas(width, height, padding int, extrude bool, opts TextureOptions) (*Atlas, error) {
+    if width <= 0 || height <= 0 || padding < 0 {
+        return nil, fmt.Errorf("invalid atlas size %dx%d with %d padding", width, height, padding)
+    }
+    opts = opts.withDefaults()
+    if opts.NoMipmaps && isMipmapFilter(opts.MinFilter) {
+        return nil, errors.New("a mipmap min filter needs mipmaps, but NoMipmaps is set")
+    }
+    atlas := &Atlas{
+        Width:      width,
+        Height:     height,
+        Padding:    padding,
+        Extrude:    extrude,
+        Options:    opts,
+        regions:    make(map[string]AtlasRegion),
+        skyline:    []skylineNode{{0, 0, width}},
+    }
+    atlas.Texture = GenBindTexture()
+    setTextureParameters(gl.TEXTURE_2D, opts)
+    gl.TexImage2D(gl.TEXTURE_2D, 0, opts.InternalFormat, int32(width), int32(height), 0, gl.RGBA, gl.UNSIGNED_BYTE,
+        gl.Ptr(make([]uint8, width*height*4)))
+    if !opts.NoMipmaps {
+        gl.GenerateMipmap(gl.TEXTURE_2D)
+    }
+    return atlas, nil
+}
+
+// Returns the region an image was added to under (name)
+func (atlas *Atlas) Region(name string) (AtlasRegion, bool) {
+    region, ok := atlas.regions[name]
+    return region, ok
+}
+
+// Packs (img) into the atlas under (name), uploads it, and returns its region
+//
+// Fails if the name is taken or there's no room left, the atlas is unchanged either way
+func (atlas *Atlas) Add(name string, img image.Image) (AtlasRegion, error) {
+    region, err := atlas.place(name, img)
+    if err != nil {
+        return region, err
+    }
+    if !atlas.Options.NoMipmaps {
+        BindTexture(atlas.Texture)
+        gl.GenerateMipmap(gl.TEXTURE_2D)
+    }
+    return region, nil
+}
+
+// Packs several images at once, tallest first since that packs tighter than adding them one at a time
+//
+// Stops at the first image that doesn't fit, the ones before it stay in the atlas
+func (atlas *Atlas) AddAll(images map[string]image.Image) (map[string]AtlasRegion, error) {
+    names := make([]string, 0, len(images))
+    for name := range images {
+        names = append(names, name)
+    }
+    sort.Slice(names, func(i, j int) bool {
+        a, b := images[names[i]].Bounds().Size(), images[names[j]].Bounds().Size()
+        if a.Y != b.Y {
+            return a.Y > b.Y
+        }
+        if a.X != b.X {
+            return a.X > b.X
+        }
+        return names[i] < names[j]
+    })
+
+    regions := make(map[string]AtlasRegion, len(names))
+    var err error
+    for _, name := range names {
+        var region AtlasRegion
+        if region, err = atlas.place(name, images[name]); err != nil {
+            break
+        }
+        regions[name] = region
+    }
+    if len(regions) > 0 && !atlas.Options.NoMipmaps {
+        BindTexture(atlas.Texture)
+        gl.GenerateMipmap(gl.TEXTURE_2D)
+    }
+    return regions, err
+}
+
+// Deletes the atlas texture
+func (atlas *Atlas) Delete() {
+    gl.DeleteTextures(1, &atlas.Texture)
+}
+
+// Finds room for (img) with its padding, uploads it, and records its region
+func (atlas *Atlas) place(name string, img image.Image) (AtlasRegion, error) {
+    if _, ok := atlas.regions[name]; ok {
+        return AtlasRegion{}, fmt.Errorf("atlas already has an image named %q", name)
+    }
+    size := img.Bounds().Size()
+    padded := image.Pt(size.X+2*atlas.Padding, size.Y+2*atlas.Padding)
+    x, y, ok := atlas.pack(padded.X, padded.Y)
+    if !ok {
+        return AtlasRegion{}, fmt.Errorf("no room for %q (%dx%d) in the %dx%d atlas", name, size.X, size.Y, atlas.Width, atlas.Height)
+    }
+
+    BindTexture(atlas.Texture)
+    upload := padImage(img, atlas.Padding, atlas.Extrude)
+    pixels := imagePixels(upload, isSingleChannel(atlas.Options.InternalFormat), atlas.Options.PremultiplyAlpha)
+    gl.PixelStorei(gl.UNPACK_ALIGNMENT, 1)
+    gl.TexSubImage2D(gl.TEXTURE_2D, 0, int32(x), int32(y), int32(padded.X), int32(padded.Y), pixels.format, pixels.xtype,
+        gl.Ptr(pixels.data))
+    gl.PixelStorei(gl.UNPACK_ALIGNMENT, 4)
+
+    x, y = x+atlas.Padding, y+atlas.Padding
+    region := AtlasRegion{
+        X:      x,
+        Y:      y,
+        Width:  size.X,
+        Height: size.Y,
+        UV: [4]float32{
+            float32(x) / float32(atlas.Width),
+            float32(y) / float32(atlas.Height),
+            float32(x+size.X) / float32(atlas.Width),
+            float32(y+size.Y) / float32(atlas.Height),
+        },
+    }
+    atlas.regions[name] = region
+    return region, nil
+}
+
+// Finds the lowest, then leftmost, spot on the skyline a (w) x (h) rectangle fits, and raises the skyline over it
+func (atlas *Atlas) pack(w, h int) (int, int, bool) {
+    best, bestX, bestY, bestWidth := -1, 0, atlas.Height, 0
+    for i, node := range atlas.skyline {
+        if node.x+w > atlas.Width {
+            break
+        }
+        // The rectangle rests on the highest node under it
+        y, covered := 0, 0
+        for j := i; covered < w; j++ {
+            y = max(y, atlas.skyline[j].y)
+            covered = atlas.skyline[j].x + atlas.skyline[j].width - node.x
+        }
+        if y+h <= atlas.Height && (y < bestY || (y == bestY && node.width < bestWidth)) {
+            best, bestX, bestY, bestWidth = i, node.x, y, node.width
+        }
+    }
+    if best < 0 {
+        return 0, 0, false
+    }
+
+    // Replace the covered part of the skyline with the rectangle's top edge
+    placed := skylineNode{bestX, bestY + h, w}
+    var skyline []skylineNode
+    skyline = append(skyline, atlas.skyline[:best]...)
+    skyline = append(skyline, placed)
+    for _, node := range atlas.skyline[best:] {
+        end := node.x + node.width
+        if end <= placed.x+placed.width {
+            continue
+        }
+        if node.x < placed.x+placed.width {
+            node.width = end - (placed.x + placed.width)
+            node.x = placed.x + placed.width
+        }
+        skyline = append(skyline, node)
+    }
+
+    // Merge neighbours at the same height
+    atlas.skyline = skyline[:1]
+    for _, node := range skyline[1:] {
+        last := &atlas.skyline[len(atlas.skyline)-1]
+        if last.y == node.y {
+            last.width += node.width
+        } else {
+            atlas.skyline = append(atlas.skyline, node)
+        }
+    }
+    return bestX, bestY, true
+}
+
+// Returns (img) with (padding) pixels around it, transparent or repeating the edge pixels if (extrude)
+//
+// FloatImages stay float so HDR values survive, everything else becomes an *image.NRGBA
+func padImage(img image.Image, padding int, extrude bool) image.Image {
+    if padding == 0 {
+        return img
+    }
+    bounds := img.Bounds()
+    rect := image.Rect(0, 0, bounds.Dx()+2*padding, bounds.Dy()+2*padding)
+    inner := image.Rect(padding, padding, padding+bounds.Dx(), padding+bounds.Dy())
+    var padded draw.Image
+    if float, ok := img.(*FloatImage); ok {
+        // Copied by rows, draw.Draw would go through FloatColor.RGBA and clamp to 0-1
+        paddedFloat := NewFloatImage(rect)
+        for y := 0; y < bounds.Dy(); y++ {
+            row := float.Pix[float.PixOffset(bounds.Min.X, bounds.Min.Y+y):][:4*bounds.Dx()]
+            copy(paddedFloat.Pix[paddedFloat.PixOffset(inner.Min.X, inner.Min.Y+y):], row)
+        }
+        padded = paddedFloat
+    } else {
+        padded = image.NewNRGBA(rect)
+        draw.Draw(padded, inner, img, bounds.Min, draw.Src)
+    }
+    if !extrude {
+        return padded
+    }
+
+    for y := 0; y < rect.Dy(); y++ {
+        for x := 0; x < rect.Dx(); x++ {
+            if (image.Point{x, y}).In(inner) {
+                continue
+            }
+            from := image.Pt(min(max(x, inner.Min.X), inner.Max.X-1), min(max(y, inner.Min.Y), inner.Max.Y-1))
+            padded.Set(x, y, padded.At(from.X, from.Y))
+        }
+    }
+    return padded
+}
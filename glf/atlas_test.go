@@ -0,0 +1,49 @@
+package glf
+
+import (
+	"image"
+	"testing"
+)
+
+func TestPadImageKeepsHDRValues(t *testing.T) {
+    src := NewFloatImage(image.Rect(0, 0, 2, 2))
+    for i := range src.Pix {
+        src.Pix[i] = 4.5
+    }
+    src.Pix[src.PixOffset(1, 1)] = -2
+
+    for _, extrude := range []bool{false, true} {
+        padded, ok := padImage(src, 2, extrude).(*FloatImage)
+        if !ok {
+            t.Fatalf("extrude %v: padding a FloatImage didn't give a FloatImage", extrude)
+        }
+        if got := padded.At(2, 2).(FloatColor); got != (FloatColor{4.5, 4.5, 4.5, 4.5}) {
+            t.Errorf("extrude %v: inner pixel is %v, want 4.5 in every channel", extrude, got)
+        }
+        if got := padded.At(3, 3).(FloatColor).R; got != -2 {
+            t.Errorf("extrude %v: inner red is %v, want -2", extrude, got)
+        }
+        corner := padded.At(0, 0).(FloatColor)
+        want := FloatColor{}
+        if extrude {
+            want = FloatColor{4.5, 4.5, 4.5, 4.5}
+        }
+        if corner != want {
+            t.Errorf("extrude %v: padding pixel is %v, want %v", extrude, corner, want)
+        }
+    }
+}
+
+func TestPadImageOffsetBounds(t *testing.T) {
+    src := NewFloatImage(image.Rect(0, 0, 4, 4))
+    src.Pix[src.PixOffset(2, 3)] = 8
+    sub := &FloatImage{Pix: src.Pix[src.PixOffset(1, 1):], Stride: src.Stride, Rect: image.Rect(1, 1, 4, 4)}
+
+    padded := padImage(sub, 1, false).(*FloatImage)
+    if padded.Bounds() != image.Rect(0, 0, 5, 5) {
+        t.Fatalf("padded bounds are %v, want 5x5", padded.Bounds())
+    }
+    if got := padded.At(2, 3).(FloatColor).R; got != 8 {
+        t.Errorf("pixel (2, 3) of the sub image is at the wrong place, padded (2, 3) has red %v, want 8", got)
+    }
+}
@@ -0,0 +1,147 @@
+// Compute Backend GL Helper Functions
+package glf
+
+import (
+	"regexp"
+	"runtime"
+	"strconv"
+	"sync"
+	"sync/atomic"
+)
+
+// ComputeBackend runs the compute work of a ShaderManager in place of GL
+type ComputeBackend[T ShaderData] interface {
+    Execute(data []T, sizeWorkGP ...int) []T
+    Cleanup()
+}
+
+// The built in compute shader inputs of one invocation, named after their gl_ variables
+type Invocation struct {
+    GlobalInvocationID      [3]uint32
+    LocalInvocationID       [3]uint32
+    WorkGroupID             [3]uint32
+    NumWorkGroups           [3]uint32
+    WorkGroupSize           [3]uint32
+    LocalInvocationIndex    uint32
+}
+
+// Go version of a compute shader, called once per invocation with the slice bound to binding 0
+type Kernel[T ShaderData] func(inv Invocation, data []T)
+
+// Workgroup size the CPU backend divides by when Execute isn't given one.
+// The GL path uses GL_MAX_COMPUTE_WORK_GROUP_SIZE, and 1024 is the minimum a GL 4.3 driver can report.
+const DefaultMaxWorkGroupSize = 1024
+
+// CPUBackend runs a Go kernel across goroutines with the same workgroup layout Execute dispatches on the GPU
+//
+// Workgroups are spread over the workers and the invocations of one workgroup run in order on the same goroutine.
+// There is no barrier() or shared memory, so kernels that need them can't be emulated.
+type CPUBackend[T ShaderData] struct {
+    Kernel      Kernel[T]
+    LocalSize   [3]uint32 // The layout(local_size_x/y/z) of the shader
+    Workers     int       // Number of goroutines, runtime.NumCPU() if 0
+}
+
+var registeredKernels = make(map[string]any)
+
+// Registers a Go kernel for the compute shader with the given source file name.
+//
+// The InitShaderManager functions fall back to it when no GL context can be created.
+func RegisterKernel[T ShaderData](sourceFile string, kernel Kernel[T]) {
+    registeredKernels[sourceFile] = kernel
+}
+
+// Creates a ShaderManager that always runs (kernel) on the CPU, with the local size read from (shaderSource)
+//
+// Useful for testing a kernel against the GPU path, no GL context is needed
+func NewCPUShaderManager[T ShaderData](shaderSource string, kernel Kernel[T]) *ShaderManager[T] {
+    return &ShaderManager[T]{
+        Backend: NewCPUBackend(shaderSource, kernel),
+        source:  shaderSource,
+    }
+}
+
+// Creates a CPUBackend for (kernel), taking the local size from the layout qualifier in (shaderSource)
+func NewCPUBackend[T ShaderData](shaderSource string, kernel Kernel[T]) *CPUBackend[T] {
+    return &CPUBackend[T]{
+        Kernel:     kernel,
+        LocalSize:  parseLocalSize(shaderSource),
+    }
+}
+
+var localSizePattern = regexp.MustCompile(`local_size_([xyz])\s*=\s*(\d+)`)
+
+// Reads the local_size_x/y/z values out of a compute shader source, any missing size is 1
+func parseLocalSize(shaderSource string) [3]uint32 {
+    size := [3]uint32{1, 1, 1}
+    for _, match := range localSizePattern.FindAllStringSubmatch(shaderSource, -1) {
+        value, err := strconv.ParseUint(match[2], 10, 32)
+        if err != nil || value == 0 {
+            continue
+        }
+        size[match[1][0]-'x'] = uint32(value)
+    }
+    return size
+}
+
+// Execute runs the kernel on (data) in place, and returns it
+//
+// The number of workgroups is worked out the same way as the GL path of ShaderManager.Execute
+func (b *CPUBackend[T]) Execute(data []T, sizeWorkGP ...int) []T {
+    workGroupSize := DefaultMaxWorkGroupSize
+    if len(sizeWorkGP) > 0 {
+        workGroupSize = sizeWorkGP[0]
+    }
+    numWorkGroups := uint32((len(data) + workGroupSize - 1) / workGroupSize)
+    if numWorkGroups == 0 {
+        return data
+    }
+
+    workers := b.Workers
+    if workers <= 0 {
+        workers = runtime.NumCPU()
+    }
+    workers = min(workers, int(numWorkGroups))
+
+    var next atomic.Uint32
+    var wg sync.WaitGroup
+    wg.Add(workers)
+    for range workers {
+        go func() {
+            defer wg.Done()
+            for {
+                group := next.Add(1) - 1
+                if group >= numWorkGroups {
+                    return
+                }
+                b.runWorkGroup(data, group, numWorkGroups)
+            }
+        }()
+    }
+    wg.Wait()
+
+    return data
+}
+
+// Runs every invocation of one workgroup in order
+func (b *CPUBackend[T]) runWorkGroup(data []T, group, numWorkGroups uint32) {
+    size := b.LocalSize
+    inv := Invocation{
+        WorkGroupID:    [3]uint32{group, 0, 0},
+        NumWorkGroups:  [3]uint32{numWorkGroups, 1, 1},
+        WorkGroupSize:  size,
+    }
+    for z := uint32(0); z < size[2]; z++ {
+        for y := uint32(0); y < size[1]; y++ {
+            for x := uint32(0); x < size[0]; x++ {
+                inv.LocalInvocationID = [3]uint32{x, y, z}
+                inv.GlobalInvocationID = [3]uint32{group*size[0] + x, y, z}
+                inv.LocalInvocationIndex = z*size[0]*size[1] + y*size[0] + x
+                b.Kernel(inv, data)
+            }
+        }
+    }
+}
+
+// Nothing to release for the CPU
+func (b *CPUBackend[T]) Cleanup() {}
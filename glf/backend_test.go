@@ -0,0 +1,120 @@
+package glf
+
+import (
+	"errors"
+	"sync"
+	"testing"
+)
+
+func TestParseLocalSize(t *testing.T) {
+    tests := []struct {
+        name    string
+        source  string
+        want    [3]uint32
+    }{
+        {"all three", "layout(local_size_x = 8, local_size_y = 4, local_size_z = 2) in;", [3]uint32{8, 4, 2}},
+        {"missing", "#version 430\nvoid main() {}", [3]uint32{1, 1, 1}},
+        {"only x", "layout(local_size_x = 64) in;", [3]uint32{64, 1, 1}},
+        {"y and z", "layout(local_size_z=3, local_size_y=5) in;", [3]uint32{1, 5, 3}},
+        {"spacing", "layout(local_size_x\t=  16 ,local_size_y=\n2) in;", [3]uint32{16, 2, 1}},
+        {"zero stays 1", "layout(local_size_x = 0, local_size_y = 7) in;", [3]uint32{1, 7, 1}},
+        {"too big stays 1", "layout(local_size_x = 99999999999) in;", [3]uint32{1, 1, 1}},
+        {"specialization constant", "layout(local_size_x_id = 0) in;", [3]uint32{1, 1, 1}},
+    }
+    for _, test := range tests {
+        if got := parseLocalSize(test.source); got != test.want {
+            t.Errorf("%s: got %v, want %v", test.name, got, test.want)
+        }
+    }
+}
+
+// Every invocation of every workgroup runs once, with the IDs GL would give it
+func TestCPUBackendInvocations(t *testing.T) {
+    tests := []struct {
+        name            string
+        source          string
+        length          int
+        workGroupSize   []int
+        wantGroups      uint32
+    }{
+        {"default workgroup size", "layout(local_size_x = 4) in;", 10, nil, 1},
+        {"partial last group", "layout(local_size_x = 4) in;", 10, []int{4}, 3},
+        {"2D local size", "layout(local_size_x = 2, local_size_y = 3) in;", 12, []int{6}, 2},
+        {"3D local size", "layout(local_size_x = 2, local_size_y = 2, local_size_z = 2) in;", 16, []int{8}, 2},
+        {"empty", "layout(local_size_x = 4) in;", 0, []int{4}, 0},
+    }
+    for _, test := range tests {
+        var mutex sync.Mutex
+        var seen []Invocation
+        backend := NewCPUBackend(test.source, func(inv Invocation, data []float32) {
+            mutex.Lock()
+            seen = append(seen, inv)
+            mutex.Unlock()
+        })
+        backend.Workers = 3
+        backend.Execute(make([]float32, test.length), test.workGroupSize...)
+
+        size := backend.LocalSize
+        perGroup := size[0] * size[1] * size[2]
+        if uint32(len(seen)) != test.wantGroups*perGroup {
+            t.Errorf("%s: %d invocations ran, want %d groups of %d", test.name, len(seen), test.wantGroups, perGroup)
+            continue
+        }
+        counts := make(map[Invocation]int)
+        for _, inv := range seen {
+            counts[inv]++
+            local, group := inv.LocalInvocationID, inv.WorkGroupID[0]
+            want := Invocation{
+                GlobalInvocationID:     [3]uint32{group*size[0] + local[0], local[1], local[2]},
+                LocalInvocationID:      local,
+                WorkGroupID:            [3]uint32{group, 0, 0},
+                NumWorkGroups:          [3]uint32{test.wantGroups, 1, 1},
+                WorkGroupSize:          size,
+                LocalInvocationIndex:   local[2]*size[0]*size[1] + local[1]*size[0] + local[0],
+            }
+            if inv != want || group >= test.wantGroups || local[0] >= size[0] || local[1] >= size[1] || local[2] >= size[2] {
+                t.Errorf("%s: got invocation %+v, want %+v", test.name, inv, want)
+            }
+        }
+        for inv, count := range counts {
+            if count != 1 {
+                t.Errorf("%s: invocation %+v ran %d times", test.name, inv, count)
+            }
+        }
+    }
+}
+
+// A registered kernel is what the InitShaderManager functions fall back to, for its own data type only
+func TestRegisterKernel(t *testing.T) {
+    const file = "shaders/test_register_kernel.comp"
+    defer delete(registeredKernels, file)
+    source := "#version 430\nlayout(local_size_x = 4) in;\nvoid main() {}"
+    RegisterKernel(file, func(inv Invocation, data []float32) {
+        i := inv.GlobalInvocationID[0]
+        if int(i) < len(data) {
+            data[i] = data[i]*2 + float32(inv.LocalInvocationIndex)
+        }
+    })
+
+    noContext := errors.New("no context")
+    if sm := cpuFallback[int32](source, file, noContext); sm != nil {
+        t.Error("a float32 kernel was used for int32 data")
+    }
+    if sm := cpuFallback[float32](source, "shaders/other.comp", noContext); sm != nil {
+        t.Error("a kernel was used for another file")
+    }
+    sm := cpuFallback[float32](source, file, noContext)
+    if sm == nil {
+        t.Fatal("no fallback for the registered kernel")
+    }
+    defer sm.Cleanup()
+    if backend, ok := sm.Backend.(*CPUBackend[float32]); !ok || backend.LocalSize != [3]uint32{4, 1, 1} {
+        t.Errorf("the fallback backend is %#v", sm.Backend)
+    }
+
+    got := sm.Execute([]float32{1, 2, 3, 4, 5, 6}, 4)
+    want := []float32{2, 5, 8, 11, 10, 13}
+    if !equalFloats(got, want) {
+        t.Errorf("the registered kernel gave %v, want %v", got, want)
+    }
+}
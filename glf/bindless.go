@@ -0,0 +1,218 @@
+// Bindless Texture GL Helper Functions
+package glf
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"regexp"
+	"strings"
+
+	"github.com/go-gl/gl/v4.6-core/gl"
+)
+
+// Name of the sampler2D array the fallback path of a TextureTable binds its textures to
+const TextureTableUniform = "uTextureTable"
+
+// Reference counts of the resident texture handles, a handle has to be made resident only once however
+// many tables hold it
+var residentHandles = make(map[uint64]int)
+
+// Returns true if the driver supports ARB_bindless_texture, which TextureTables use when they can
+func HasBindlessSupport() bool {
+    return HasExtension("GL_ARB_bindless_texture")
+}
+
+// TextureTable gives shaders a list of 2D textures they index into, bindless where the driver supports it
+//
+// With ARB_bindless_texture each texture's 64 bit handle is made resident and written to a buffer, an SSBO
+// or a UBO, so one buffer binding makes them all available. Without it the textures are bound to texture
+// units of a sampler2D array instead, see Bind. Shaders read entry i with TEXTURE_TABLE(i) either way,
+// from the GLSL that Header returns:
+//
+//  #extension GL_ARB_bindless_texture : enable
+//  #ifdef GL_ARB_bindless_texture
+//  layout(std430, binding = 3) readonly buffer TextureTableBlock { uvec2 textureTableHandles[]; };
+//  #define TEXTURE_TABLE(i) sampler2D(textureTableHandles[i])
+//  #else
+//  uniform sampler2D uTextureTable[16];
+//  #define TEXTURE_TABLE(i) uTextureTable[i]
+//  #endif
+//
+// Written like this, file based shaders pick the same path as the table does. On the fallback path the
+// index has to be the same for the whole draw, like a material index from a uniform.
+type TextureTable struct {
+    Textures    []uint32
+    Handles     []uint64    // Resident handle of each texture, nil on the fallback path
+    Bindless    bool
+    Buffer      uint32      // Buffer holding the handles, 0 on the fallback path
+    Target      uint32      // gl.SHADER_STORAGE_BUFFER, or gl.UNIFORM_BUFFER for a std140 uniform block
+    Binding     uint32      // Binding point of the buffer
+}
+
+// Creates a table of (textures), with the handles in a (target) buffer on (binding) if the driver supports bindless textures
+//
+// Textures with a handle can't have their storage or parameters changed, so a texture in a bindless table
+// can't be reloaded by CheckTexturesforChanges until the table is deleted.
+func NewTextureTable(textures []uint32, target, binding uint32) (*TextureTable, error) {
+    if len(textures) == 0 {
+        return nil, errors.New("a texture table needs at least one texture")
+    }
+    if target != gl.SHADER_STORAGE_BUFFER && target != gl.UNIFORM_BUFFER {
+        return nil, errors.New("a texture table's buffer has to be a gl.SHADER_STORAGE_BUFFER or a gl.UNIFORM_BUFFER")
+    }
+    table := &TextureTable{
+        Textures:   append([]uint32(nil), textures...),
+        Bindless:   HasBindlessSupport(),
+        Target:     target,
+        Binding:    binding,
+    }
+    if !table.Bindless {
+        return table, nil
+    }
+
+    if target == gl.UNIFORM_BUFFER {
+        var maxSize int32
+        gl.GetIntegerv(gl.MAX_UNIFORM_BLOCK_SIZE, &maxSize)
+        if len(textures)*table.stride() > int(maxSize) {
+            return nil, fmt.Errorf("%d texture handles don't fit in a %d byte uniform block, use an SSBO", len(textures), maxSize)
+        }
+    }
+    table.Handles = make([]uint64, len(textures))
+    for i, texture := range textures {
+        handle, err := acquireHandle(texture)
+        if err != nil {
+            table.Delete()
+            return nil, err
+        }
+        table.Handles[i] = handle
+    }
+    data := make([]byte, len(textures)*table.stride())
+    for i, handle := range table.Handles {
+        binary.LittleEndian.PutUint64(data[i*table.stride():], handle)
+    }
+    table.Buffer = GenBindBuffers(target)
+    gl.BufferData(target, len(data), gl.Ptr(data), gl.DYNAMIC_DRAW)
+    gl.BindBuffer(target, 0)
+    return table, nil
+}
+
+// Bytes between entries, a uvec2 is padded to 16 in a std140 array
+func (table *TextureTable) stride() int {
+    if table.Target == gl.UNIFORM_BUFFER {
+        return 16
+    }
+    return 8
+}
+
+// Gets the handle of a texture and makes it resident, or adds a reference if it already is
+func acquireHandle(texture uint32) (uint64, error) {
+    handle := gl.GetTextureHandleARB(texture)
+    if handle == 0 {
+        return 0, fmt.Errorf("couldn't get a bindless handle for texture %d", texture)
+    }
+    if residentHandles[handle] == 0 {
+        gl.MakeTextureHandleResidentARB(handle)
+    }
+    residentHandles[handle]++
+    return handle, nil
+}
+
+// Drops a reference to a resident handle, and makes it non resident with the last one
+func releaseHandle(handle uint64) {
+    if residentHandles[handle] == 0 {
+        return
+    }
+    residentHandles[handle]--
+    if residentHandles[handle] == 0 {
+        delete(residentHandles, handle)
+        gl.MakeTextureHandleNonResidentARB(handle)
+    }
+}
+
+// Returns the GLSL that declares the table and TEXTURE_TABLE(i), to go right after the #version line
+//
+// The output only depends on the buffer target, binding and size, not on which path the table takes,
+// so it can be pasted into shader files too.
+func (table *TextureTable) Header() string {
+    block := fmt.Sprintf("layout(std430, binding = %d) readonly buffer TextureTableBlock { uvec2 textureTableHandles[]; };", table.Binding)
+    if table.Target == gl.UNIFORM_BUFFER {
+        block = fmt.Sprintf("layout(std140, binding = %d) uniform TextureTableBlock { uvec2 textureTableHandles[%d]; };",
+            table.Binding, len(table.Textures))
+    }
+    return strings.Join([]string{
+        "#extension GL_ARB_bindless_texture : enable",
+        "#ifdef GL_ARB_bindless_texture",
+        block,
+        "#define TEXTURE_TABLE(i) sampler2D(textureTableHandles[i])",
+        "#else",
+        fmt.Sprintf("uniform sampler2D %s[%d];", TextureTableUniform, len(table.Textures)),
+        fmt.Sprintf("#define TEXTURE_TABLE(i) %s[i]", TextureTableUniform),
+        "#endif",
+    }, "\n") + "\n"
+}
+
+var versionLinePattern = regexp.MustCompile(`(?m)^[ \t]*#[ \t]*version\b.*$`)
+
+// Returns (shaderSource) with Header put after its #version line, which doesn't have to be the first line
+//
+// Returns an error if there's no #version line, since the #extension line in the header has to come after it.
+func (table *TextureTable) Source(shaderSource string) (string, error) {
+    location := versionLinePattern.FindStringIndex(shaderSource)
+    if location == nil {
+        return "", errors.New("the shader has no #version line to put the texture table after")
+    }
+    end := location[1]
+    if end == len(shaderSource) {
+        return shaderSource + "\n" + table.Header(), nil
+    }
+    return shaderSource[:end+1] + table.Header() + shaderSource[end+1:], nil
+}
+
+// Makes the table available to (shader), binding the handle buffer, or on the fallback path binding each
+// texture to its element of uTextureTable with the shader's texture unit allocator
+func (table *TextureTable) Bind(shader *ShaderInfo) error {
+    if table.Bindless {
+        gl.BindBufferBase(table.Target, table.Binding, table.Buffer)
+        return nil
+    }
+    for i, texture := range table.Textures {
+        if err := shader.BindTexture2D(fmt.Sprintf("%s[%d]", TextureTableUniform, i), texture); err != nil {
+            return err
+        }
+    }
+    return nil
+}
+
+// Replaces entry (i) with (texture), updating the handle buffer and residency on the bindless path
+func (table *TextureTable) Set(i int, texture uint32) error {
+    if i < 0 || i >= len(table.Textures) {
+        return fmt.Errorf("texture table index %d is out of range, the table has %d entries", i, len(table.Textures))
+    }
+    if table.Bindless {
+        handle, err := acquireHandle(texture)
+        if err != nil {
+            return err
+        }
+        releaseHandle(table.Handles[i])
+        table.Handles[i] = handle
+        gl.BindBuffer(table.Target, table.Buffer)
+        gl.BufferSubData(table.Target, i*table.stride(), 8, gl.Ptr(&handle))
+        gl.BindBuffer(table.Target, 0)
+    }
+    table.Textures[i] = texture
+    return nil
+}
+
+// Makes the table's handles non resident, unless another table still holds them, and deletes its buffer
+//
+// The textures themselves are left alone.
+func (table *TextureTable) Delete() {
+    for _, handle := range table.Handles {
+        releaseHandle(handle)
+    }
+    table.Handles = nil
+    if table.Buffer != 0 {
+        gl.DeleteBuffers(1, &table.Buffer)
+    }
+}
@@ -0,0 +1,104 @@
+package glf
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/go-gl/gl/v4.6-core/gl"
+)
+
+func TestTextureTableHeader(t *testing.T) {
+    storage := &TextureTable{Textures: make([]uint32, 3), Target: gl.SHADER_STORAGE_BUFFER, Binding: 5}
+    want := strings.Join([]string{
+        "#extension GL_ARB_bindless_texture : enable",
+        "#ifdef GL_ARB_bindless_texture",
+        "layout(std430, binding = 5) readonly buffer TextureTableBlock { uvec2 textureTableHandles[]; };",
+        "#define TEXTURE_TABLE(i) sampler2D(textureTableHandles[i])",
+        "#else",
+        "uniform sampler2D uTextureTable[3];",
+        "#define TEXTURE_TABLE(i) uTextureTable[i]",
+        "#endif",
+    }, "\n") + "\n"
+    if got := storage.Header(); got != want {
+        t.Errorf("the SSBO header is\n%s\nwant\n%s", got, want)
+    }
+
+    uniform := &TextureTable{Textures: make([]uint32, 4), Target: gl.UNIFORM_BUFFER, Binding: 2}
+    header := uniform.Header()
+    for _, line := range []string{
+        "layout(std140, binding = 2) uniform TextureTableBlock { uvec2 textureTableHandles[4]; };",
+        "uniform sampler2D uTextureTable[4];",
+    } {
+        if !strings.Contains(header, line+"\n") {
+            t.Errorf("the UBO header is missing %q:\n%s", line, header)
+        }
+    }
+    // The header doesn't depend on which path the table takes
+    uniform.Bindless = true
+    if uniform.Header() != header {
+        t.Error("the header changed with Bindless")
+    }
+}
+
+func TestTextureTableSource(t *testing.T) {
+    table := &TextureTable{Textures: make([]uint32, 2), Target: gl.SHADER_STORAGE_BUFFER}
+    header := table.Header()
+    tests := []struct {
+        name    string
+        source  string
+        want    string
+    }{
+        {"first line", "#version 430\nvoid main() {}\n", "#version 430\n" + header + "void main() {}\n"},
+        {"after comments", "// Blur\n/* two passes */\n#version 450 core\nvoid main() {}",
+            "// Blur\n/* two passes */\n#version 450 core\n" + header + "void main() {}"},
+        {"indented with spaces after #", "\n  #  version 430\r\nvoid main() {}", "\n  #  version 430\r\n" + header + "void main() {}"},
+        {"only the version", "#version 430", "#version 430\n" + header},
+    }
+    for _, test := range tests {
+        got, err := table.Source(test.source)
+        if err != nil {
+            t.Errorf("%s: %v", test.name, err)
+            continue
+        }
+        if got != test.want {
+            t.Errorf("%s: got\n%s\nwant\n%s", test.name, got, test.want)
+        }
+    }
+
+    for _, source := range []string{"", "void main() {}\n", "#define versions 2\nvoid main() {}", "// #version 430\nvoid main() {}"} {
+        if got, err := table.Source(source); err == nil {
+            t.Errorf("a source without a #version line gave no error:\n%s", got)
+        }
+    }
+}
+
+// Whichever path the driver takes, a shader with the table's header has to compile
+func TestTextureTableSourceCompiles(t *testing.T) {
+    ctx, err := NewThreadedComputeContext(DefaultContextBackend)
+    if err != nil {
+        t.Skipf("no GL context could be created: %v", err)
+    }
+    defer ctx.Release()
+
+    table := &TextureTable{Textures: make([]uint32, 2), Target: gl.SHADER_STORAGE_BUFFER, Binding: 1}
+    source, err := table.Source(strings.Join([]string{
+        "// Reads the first texel of a table entry",
+        "#version 430",
+        "layout(local_size_x = 1) in;",
+        "layout(std430, binding = 0) buffer Output { vec4 color; };",
+        "uniform int uIndex;",
+        "void main() {",
+        "    color = textureLod(TEXTURE_TABLE(uIndex), vec2(0.5), 0.0);",
+        "}",
+    }, "\n"))
+    if err != nil {
+        t.Fatal(err)
+    }
+    ctx.Do(func() {
+        program, err := TryCreateComputeShader(source, "texture_table.comp")
+        if err != nil {
+            t.Fatal(err)
+        }
+        gl.DeleteProgram(program)
+    })
+}
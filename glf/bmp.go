@@ -0,0 +1,251 @@
+// BMP Texture Decoder
+package glf
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"image"
+	"image/color"
+	"io"
+	"math/bits"
+)
+
+// BMP compression types
+const (
+    bmpRGB          = 0
+    bmpRLE8         = 1
+    bmpRLE4         = 2
+    bmpBitFields    = 3
+    bmpAlphaFields  = 6
+)
+
+func matchBMP(header []byte) bool {
+    return len(header) >= 18 && header[0] == 'B' && header[1] == 'M'
+}
+
+// Decodes an uncompressed, bit field or RLE BMP, with any info header version from OS/2 core to V5
+func decodeBMP(r io.Reader) (image.Image, error) {
+    data, err := io.ReadAll(r)
+    if err != nil {
+        return nil, err
+    }
+    if len(data) < 26 {
+        return nil, errors.New("bmp: file is truncated")
+    }
+    pixelOffset := int(binary.LittleEndian.Uint32(data[10:]))
+    headerSize := int(binary.LittleEndian.Uint32(data[14:]))
+    if 14+headerSize > len(data) {
+        return nil, errors.New("bmp: info header is truncated")
+    }
+    info := data[14 : 14+headerSize]
+
+    var width, height, bitCount, compression, paletteSize int
+    paletteEntry := 4
+    if headerSize == 12 {
+        // OS/2 core header, 16 bit sizes and 3 byte palette entries
+        width = int(binary.LittleEndian.Uint16(info[4:]))
+        height = int(int16(binary.LittleEndian.Uint16(info[6:])))
+        bitCount = int(binary.LittleEndian.Uint16(info[10:]))
+        paletteEntry = 3
+    } else if headerSize >= 40 {
+        width = int(int32(binary.LittleEndian.Uint32(info[4:])))
+        height = int(int32(binary.LittleEndian.Uint32(info[8:])))
+        bitCount = int(binary.LittleEndian.Uint16(info[14:]))
+        compression = int(binary.LittleEndian.Uint32(info[16:]))
+        paletteSize = int(binary.LittleEndian.Uint32(info[32:]))
+    } else {
+        return nil, fmt.Errorf("bmp: unsupported info header size %d", headerSize)
+    }
+
+    topDown := height < 0
+    if topDown {
+        height = -height
+    }
+    if width <= 0 || height <= 0 || width > 1<<15 || height > 1<<15 {
+        return nil, fmt.Errorf("bmp: invalid size %dx%d", width, height)
+    }
+    if pixelOffset > len(data) {
+        return nil, errors.New("bmp: pixel data is truncated")
+    }
+
+    // Palette for 8 bits and under
+    var palette []color.NRGBA
+    if bitCount <= 8 {
+        if paletteSize == 0 {
+            paletteSize = 1 << bitCount
+        }
+        start := 14 + headerSize
+        for i := 0; i < paletteSize && start+i*paletteEntry+3 <= len(data); i++ {
+            entry := data[start+i*paletteEntry:]
+            palette = append(palette, color.NRGBA{entry[2], entry[1], entry[0], 0xff})
+        }
+    }
+
+    // Channel masks for 16 and 32 bits, from the header or the 12 bytes after a plain 40 byte header
+    masks := [4]uint32{}
+    switch compression {
+    case bmpBitFields, bmpAlphaFields:
+        count := 3
+        if compression == bmpAlphaFields || headerSize >= 56 {
+            count = 4
+        }
+        source := info[40:]
+        if headerSize == 40 {
+            source = data[14+40:]
+        }
+        if len(source) < count*4 {
+            return nil, errors.New("bmp: bit field masks are truncated")
+        }
+        for i := 0; i < count; i++ {
+            masks[i] = binary.LittleEndian.Uint32(source[i*4:])
+        }
+    case bmpRGB:
+        switch bitCount {
+        case 16:
+            masks = [4]uint32{0x7c00, 0x03e0, 0x001f, 0}
+        case 32:
+            masks = [4]uint32{0xff0000, 0xff00, 0xff, 0xff000000}
+        }
+    case bmpRLE8, bmpRLE4:
+        return decodeBMPRLE(data[pixelOffset:], width, height, topDown, compression == bmpRLE4, palette)
+    default:
+        return nil, fmt.Errorf("bmp: unsupported compression %d", compression)
+    }
+
+    img := image.NewNRGBA(image.Rect(0, 0, width, height))
+    rowSize := (width*bitCount + 31) / 32 * 4
+    pixels := data[pixelOffset:]
+    if len(pixels) < rowSize*height {
+        return nil, errors.New("bmp: pixel data is truncated")
+    }
+
+    zeroAlpha := true
+    for row := 0; row < height; row++ {
+        y := height - 1 - row
+        if topDown {
+            y = row
+        }
+        line := pixels[row*rowSize : (row+1)*rowSize]
+        out := img.Pix[y*img.Stride:]
+        for x := 0; x < width; x++ {
+            var c color.NRGBA
+            switch bitCount {
+            case 1, 2, 4, 8:
+                bit := x * bitCount
+                index := int(line[bit/8]>>(8-bitCount-bit%8)) & (1<<bitCount - 1)
+                if index < len(palette) {
+                    c = palette[index]
+                }
+            case 24:
+                c = color.NRGBA{line[x*3+2], line[x*3+1], line[x*3], 0xff}
+            case 16, 32:
+                var value uint32
+                if bitCount == 16 {
+                    value = uint32(binary.LittleEndian.Uint16(line[x*2:]))
+                } else {
+                    value = binary.LittleEndian.Uint32(line[x*4:])
+                }
+                c = color.NRGBA{maskedByte(value, masks[0]), maskedByte(value, masks[1]), maskedByte(value, masks[2]), 0xff}
+                if masks[3] != 0 {
+                    c.A = maskedByte(value, masks[3])
+                    zeroAlpha = zeroAlpha && c.A == 0
+                }
+            default:
+                return nil, fmt.Errorf("bmp: unsupported bit count %d", bitCount)
+            }
+            out[x*4], out[x*4+1], out[x*4+2], out[x*4+3] = c.R, c.G, c.B, c.A
+        }
+    }
+
+    // Plenty of writers leave the alpha byte of 32 bit pixels at 0, so an all 0 alpha channel means opaque
+    if masks[3] != 0 && zeroAlpha {
+        for i := 3; i < len(img.Pix); i += 4 {
+            img.Pix[i] = 0xff
+        }
+    }
+    return img, nil
+}
+
+// Scales the bits under (mask) in (value) to 0-255
+func maskedByte(value, mask uint32) uint8 {
+    if mask == 0 {
+        return 0
+    }
+    shift := bits.TrailingZeros32(mask)
+    width := bits.OnesCount32(mask)
+    v := (value & mask) >> shift
+    if width >= 8 {
+        return uint8(v >> (width - 8))
+    }
+    return uint8(v * 255 / (1<<width - 1))
+}
+
+// Decodes RLE8 or RLE4 pixel data, skipped pixels are left transparent
+func decodeBMPRLE(data []byte, width, height int, topDown, rle4 bool, palette []color.NRGBA) (image.Image, error) {
+    img := image.NewNRGBA(image.Rect(0, 0, width, height))
+    x, row := 0, 0
+    set := func(index int) {
+        if x < width && row < height && index < len(palette) {
+            y := height - 1 - row
+            if topDown {
+                y = row
+            }
+            c := palette[index]
+            i := y*img.Stride + x*4
+            img.Pix[i], img.Pix[i+1], img.Pix[i+2], img.Pix[i+3] = c.R, c.G, c.B, c.A
+        }
+        x++
+    }
+    nibble := func(value byte, i int) int {
+        if i%2 == 0 {
+            return int(value >> 4)
+        }
+        return int(value & 0xf)
+    }
+
+    for i := 0; i+1 < len(data); {
+        count, value := int(data[i]), data[i+1]
+        i += 2
+        if count > 0 {
+            for n := 0; n < count; n++ {
+                if rle4 {
+                    set(nibble(value, n))
+                } else {
+                    set(int(value))
+                }
+            }
+            continue
+        }
+        switch value {
+        case 0: // End of line
+            x, row = 0, row+1
+        case 1: // End of bitmap
+            return img, nil
+        case 2: // Delta
+            if i+1 >= len(data) {
+                return img, nil
+            }
+            x, row = x+int(data[i]), row+int(data[i+1])
+            i += 2
+        default: // Literal run, padded to 2 bytes
+            length := int(value)
+            size := length
+            if rle4 {
+                size = (length + 1) / 2
+            }
+            if i+size > len(data) {
+                return nil, errors.New("bmp: RLE data is truncated")
+            }
+            for n := 0; n < length; n++ {
+                if rle4 {
+                    set(nibble(data[i+n/2], n))
+                } else {
+                    set(int(data[i+n]))
+                }
+            }
+            i += size + size%2
+        }
+    }
+    return img, nil
+}
@@ -0,0 +1,150 @@
+package glf
+
+import (
+	"bytes"
+	"encoding/binary"
+	"image/color"
+	"strings"
+	"testing"
+)
+
+// BMP header fields for makeBMP
+type bmpHeader struct {
+    width, height   int
+    bitCount        int
+    compression     int
+    palette         []color.NRGBA
+    masks           []uint32 // Bit field masks, written after the 40 byte info header
+    core            bool     // Write a 12 byte OS/2 core header instead
+}
+
+// Builds a BMP file from its header and pixel data
+func makeBMP(h bmpHeader, pixels []byte) []byte {
+    var info []byte
+    entry := 4
+    if h.core {
+        info = make([]byte, 12)
+        binary.LittleEndian.PutUint32(info, 12)
+        binary.LittleEndian.PutUint16(info[4:], uint16(h.width))
+        binary.LittleEndian.PutUint16(info[6:], uint16(h.height))
+        binary.LittleEndian.PutUint16(info[10:], uint16(h.bitCount))
+        entry = 3
+    } else {
+        info = make([]byte, 40)
+        binary.LittleEndian.PutUint32(info, 40)
+        binary.LittleEndian.PutUint32(info[4:], uint32(int32(h.width)))
+        binary.LittleEndian.PutUint32(info[8:], uint32(int32(h.height)))
+        binary.LittleEndian.PutUint16(info[14:], uint16(h.bitCount))
+        binary.LittleEndian.PutUint32(info[16:], uint32(h.compression))
+        binary.LittleEndian.PutUint32(info[32:], uint32(len(h.palette)))
+        for _, mask := range h.masks {
+            info = binary.LittleEndian.AppendUint32(info, mask)
+        }
+    }
+    for _, c := range h.palette {
+        info = append(info, []byte{c.B, c.G, c.R, 0}[:entry]...)
+    }
+
+    data := make([]byte, 14)
+    data[0], data[1] = 'B', 'M'
+    binary.LittleEndian.PutUint32(data[10:], uint32(14+len(info)))
+    data = append(data, info...)
+    return append(data, pixels...)
+}
+
+func TestDecodeBMP(t *testing.T) {
+    red, green, blue, white := color.NRGBA{255, 0, 0, 255}, color.NRGBA{0, 255, 0, 255}, color.NRGBA{0, 0, 255, 255}, color.NRGBA{255, 255, 255, 255}
+    palette := []color.NRGBA{red, green, blue, white}
+    tests := []struct {
+        name    string
+        file    []byte
+        width   int
+        want    []color.NRGBA
+    }{
+        // Rows are padded to 4 bytes
+        {"24 bit bottom up", makeBMP(bmpHeader{width: 2, height: 2, bitCount: 24},
+            []byte{0, 0, 255, 0, 255, 0, 0, 0, 255, 0, 0, 255, 255, 255, 0, 0}), 2, []color.NRGBA{blue, white, red, green}},
+        {"24 bit top down", makeBMP(bmpHeader{width: 1, height: -2, bitCount: 24},
+            []byte{0, 0, 255, 0, 255, 0, 0, 0}), 1, []color.NRGBA{red, blue}},
+        {"32 bit with zero alpha", makeBMP(bmpHeader{width: 2, height: 1, bitCount: 32},
+            []byte{0, 0, 255, 0, 255, 0, 0, 0}), 2, []color.NRGBA{red, blue}},
+        {"32 bit with alpha", makeBMP(bmpHeader{width: 2, height: 1, bitCount: 32},
+            []byte{0, 0, 255, 128, 255, 0, 0, 0}), 2, []color.NRGBA{{255, 0, 0, 128}, {0, 0, 255, 0}}},
+        {"16 bit 555", makeBMP(bmpHeader{width: 2, height: 1, bitCount: 16},
+            []byte{0x00, 0x7c, 0x1f, 0x00}), 2, []color.NRGBA{red, blue}},
+        {"16 bit 565 bit fields", makeBMP(bmpHeader{width: 2, height: 1, bitCount: 16, compression: bmpBitFields, masks: []uint32{0xf800, 0x07e0, 0x001f}},
+            []byte{0xe0, 0x07, 0x00, 0xf8}), 2, []color.NRGBA{green, red}},
+        {"32 bit alpha fields", makeBMP(bmpHeader{width: 1, height: 1, bitCount: 32, compression: bmpAlphaFields, masks: []uint32{0xff, 0xff00, 0xff0000, 0xff000000}},
+            []byte{255, 0, 0, 64}), 1, []color.NRGBA{{255, 0, 0, 64}}},
+        {"8 bit", makeBMP(bmpHeader{width: 3, height: 1, bitCount: 8, palette: palette},
+            []byte{3, 1, 7, 0}), 3, []color.NRGBA{white, green, {}}},
+        {"4 bit", makeBMP(bmpHeader{width: 3, height: 1, bitCount: 4, palette: palette},
+            []byte{0x21, 0x30, 0, 0}), 3, []color.NRGBA{blue, green, white}},
+        {"1 bit", makeBMP(bmpHeader{width: 3, height: 1, bitCount: 1, palette: palette[:2]},
+            []byte{0xa0, 0, 0, 0}), 3, []color.NRGBA{green, red, green}},
+        {"OS/2 core", makeBMP(bmpHeader{width: 2, height: 1, bitCount: 8, palette: palette, core: true},
+            []byte{2, 0, 0, 0}), 2, []color.NRGBA{blue, red}},
+        // A run of 3, end of line, a literal run of 3 padded to 4 bytes, then end of bitmap
+        {"RLE8", makeBMP(bmpHeader{width: 3, height: 2, bitCount: 8, compression: bmpRLE8, palette: palette},
+            []byte{3, 1, 0, 0, 0, 3, 0, 2, 3, 0, 0, 1}), 3, []color.NRGBA{red, blue, white, green, green, green}},
+        // A delta skips the first pixel, which is left transparent
+        {"RLE4", makeBMP(bmpHeader{width: 4, height: 1, bitCount: 4, compression: bmpRLE4, palette: palette},
+            []byte{0, 2, 1, 0, 3, 0x32, 0, 1}), 4, []color.NRGBA{{}, white, blue, white}},
+    }
+    for _, test := range tests {
+        if !matchBMP(test.file) {
+            t.Errorf("%s: matchBMP is false", test.name)
+        }
+        img, err := decodeBMP(bytes.NewReader(test.file))
+        if err != nil {
+            t.Errorf("%s: %v", test.name, err)
+            continue
+        }
+        expectNRGBA(t, test.name, img, test.width, test.want)
+    }
+}
+
+func TestDecodeBMPErrors(t *testing.T) {
+    tests := []struct {
+        name    string
+        file    []byte
+        want    string
+    }{
+        {"file", []byte("BM\x00\x00"), "file is truncated"},
+        {"info header", makeBMP(bmpHeader{width: 1, height: 1, bitCount: 24}, nil)[:30], "info header is truncated"},
+        {"info header size", append(makeBMP(bmpHeader{width: 1, height: 1, bitCount: 24, core: true}, nil)[:14], 20, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0),
+            "unsupported info header size 20"},
+        {"zero size", makeBMP(bmpHeader{height: 1, bitCount: 24}, []byte{0, 0, 0, 0}), "invalid size 0x1"},
+        {"too big", makeBMP(bmpHeader{width: 1<<15 + 1, height: 1, bitCount: 24}, nil), "invalid size 32769x1"},
+        {"compression", makeBMP(bmpHeader{width: 1, height: 1, bitCount: 24, compression: 4}, []byte{0, 0, 0, 0}), "unsupported compression 4"},
+        {"bit count", makeBMP(bmpHeader{width: 1, height: 1, bitCount: 12}, []byte{0, 0, 0, 0}), "unsupported bit count 12"},
+        {"masks", makeBMP(bmpHeader{width: 1, height: 1, bitCount: 16, compression: bmpBitFields, masks: []uint32{0xf800}}, nil), "bit field masks are truncated"},
+        {"pixels", makeBMP(bmpHeader{width: 2, height: 2, bitCount: 24}, make([]byte, 15)), "pixel data is truncated"},
+        {"RLE literal", makeBMP(bmpHeader{width: 4, height: 1, bitCount: 8, compression: bmpRLE8, palette: make([]color.NRGBA, 4)}, []byte{0, 4, 1, 2}), "RLE data is truncated"},
+    }
+    for _, test := range tests {
+        _, err := decodeBMP(bytes.NewReader(test.file))
+        if err == nil || !strings.HasPrefix(err.Error(), "bmp: ") || !strings.Contains(err.Error(), test.want) {
+            t.Errorf("%s: got error %v, want one containing %q", test.name, err, test.want)
+        }
+    }
+}
+
+func TestMaskedByte(t *testing.T) {
+    tests := []struct {
+        value, mask uint32
+        want        uint8
+    }{
+        {0x7c00, 0x7c00, 255},
+        {0x4000, 0x7c00, 131},
+        {0x0000, 0x7c00, 0},
+        {0xabcd, 0xffff, 0xab},
+        {0x1, 0x1, 255},
+        {0x12345678, 0, 0},
+    }
+    for _, test := range tests {
+        if got := maskedByte(test.value, test.mask); got != test.want {
+            t.Errorf("maskedByte(0x%X, 0x%X) = %d, want %d", test.value, test.mask, got, test.want)
+        }
+    }
+}
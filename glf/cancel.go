@@ -0,0 +1,148 @@
+// Cancellable Compute GL Helper Functions
+package glf
+
+import (
+	"context"
+	"errors"
+	"time"
+	"unsafe"
+
+	"github.com/go-gl/gl/v4.6-core/gl"
+)
+
+// Name of the uint uniform ExecuteContext sets to the index of the first invocation of each bounded dispatch
+//
+// Every dispatch starts again at gl_GlobalInvocationID.x 0, so uDispatchOffset + gl_GlobalInvocationID.x is
+// the index in the bound buffer. Shaders that don't declare it can't be split, and run in one dispatch
+// per chunk instead.
+const DispatchOffsetUniform = "uDispatchOffset"
+
+// Most workgroups ExecuteContext puts in one dispatch before checking for cancellation
+var MaxGroupsPerDispatch = 1024
+
+// How long a fence wait blocks before checking for cancellation again
+var FencePollInterval = time.Millisecond
+
+// Queues a fence after the GL commands issued so far and waits for the GPU to reach it
+//
+// Returns ctx.Err() if (ctx) is done first, the commands keep running on the GPU but nothing waits on them
+func WaitForGPU(ctx context.Context) error {
+    fence := gl.FenceSync(gl.SYNC_GPU_COMMANDS_COMPLETE, 0)
+    defer gl.DeleteSync(fence)
+
+    // Only the first wait has to flush, after that the fence is on its way to the GPU
+    flags := uint32(gl.SYNC_FLUSH_COMMANDS_BIT)
+    for {
+        switch gl.ClientWaitSync(fence, flags, uint64(FencePollInterval)) {
+        case gl.ALREADY_SIGNALED, gl.CONDITION_SATISFIED:
+            return nil
+        case gl.WAIT_FAILED:
+            return errors.New("glClientWaitSync failed")
+        }
+        flags = 0
+        if err := ctx.Err(); err != nil {
+            return err
+        }
+    }
+}
+
+// ExecuteTimeout runs the compute shader like ExecuteContext, giving up after (timeout)
+func (sm *ShaderManager[T]) ExecuteTimeout(data []T, timeout time.Duration, sizeWorkGP ...int) ([]T, error) {
+    ctx, cancel := context.WithTimeout(context.Background(), timeout)
+    defer cancel()
+    return sm.ExecuteContext(ctx, data, sizeWorkGP...)
+}
+
+// ExecuteContext runs the compute shader with the provided data, returning ctx.Err() if (ctx) is done first
+//
+// The work is split into dispatches of at most MaxGroupsPerDispatch workgroups with a fence wait after each,
+// see DispatchOffsetUniform, and data over MaxStorageBlockSize goes through in chunks like ExecuteStreamed.
+// When cancelled, chunks already read back hold their results and the rest of (data) is unchanged. Work
+// already sent to the GPU can't be stopped, but the ShaderManager can be used again right away.
+func (sm *ShaderManager[T]) ExecuteContext(ctx context.Context, data []T, sizeWorkGP ...int) ([]T, error) {
+    if err := ctx.Err(); err != nil {
+        return data, err
+    }
+    if sm.Backend != nil {
+        return sm.Backend.Execute(data, sizeWorkGP...), ctx.Err()
+    }
+    if thread := sm.glThread(); thread != nil {
+        var err error
+        thread.Call(func() {
+            data, err = sm.ExecuteContext(ctx, data, sizeWorkGP...)
+        })
+        return data, err
+    }
+    if sm.convert32 {
+        var err error
+        data = sm.executeAsFloat32(data, func(inner *ShaderManager[float32], data32 []float32) {
+            _, err = inner.ExecuteContext(ctx, data32, sizeWorkGP...)
+        })
+        return data, err
+    }
+    if len(data) == 0 {
+        return data, nil
+    }
+
+    groupSize := workGroupSize(sizeWorkGP)
+    elemSize := int(unsafe.Sizeof(data[0]))
+    chunkSize := min(max(MaxStorageBlockSize()/elemSize/groupSize, 1)*groupSize, len(data))
+
+    sm.Profiler.Begin("ExecuteContext")
+    defer sm.Profiler.End()
+
+    var buffer uint32
+    gl.GenBuffers(1, &buffer)
+    gl.BindBuffer(gl.SHADER_STORAGE_BUFFER, buffer)
+    gl.BufferData(gl.SHADER_STORAGE_BUFFER, chunkSize*elemSize, nil, gl.DYNAMIC_COPY)
+    defer func() {
+        gl.BindBuffer(gl.SHADER_STORAGE_BUFFER, 0)
+        gl.DeleteBuffers(1, &buffer)
+    }()
+
+    gl.UseProgram(sm.ShaderProgram)
+    chunkLocation := gl.GetUniformLocation(sm.ShaderProgram, gl.Str(ChunkOffsetUniform+"\x00"))
+    dispatchLocation := gl.GetUniformLocation(sm.ShaderProgram, gl.Str(DispatchOffsetUniform+"\x00"))
+
+    for start := 0; start < len(data); start += chunkSize {
+        part := data[start:min(start+chunkSize, len(data))]
+
+        sm.Profiler.Begin("upload")
+        gl.BindBuffer(gl.SHADER_STORAGE_BUFFER, buffer)
+        gl.BufferSubData(gl.SHADER_STORAGE_BUFFER, 0, len(part)*elemSize, unsafe.Pointer(&part[0]))
+        gl.BindBufferRange(gl.SHADER_STORAGE_BUFFER, 0, buffer, 0, len(part)*elemSize)
+        sm.bindAttached()
+        sm.Profiler.End()
+
+        numGroups := (len(part) + groupSize - 1) / groupSize
+        batch := numGroups
+        if dispatchLocation >= 0 {
+            batch = max(MaxGroupsPerDispatch, 1)
+        }
+
+        gl.UseProgram(sm.ShaderProgram)
+        gl.Uniform1ui(chunkLocation, uint32(start))
+        for first := 0; first < numGroups; first += batch {
+            sm.Profiler.Begin("dispatch")
+            gl.Uniform1ui(dispatchLocation, uint32(first*groupSize))
+            gl.DispatchCompute(uint32(min(batch, numGroups-first)), 1, 1)
+            gl.MemoryBarrier(gl.SHADER_STORAGE_BARRIER_BIT | gl.BUFFER_UPDATE_BARRIER_BIT)
+            err := WaitForGPU(ctx)
+            sm.Profiler.End()
+            if err != nil {
+                return data, err
+            }
+            // Some drivers finish the work inside the first wait, so the fence never gets to time out
+            if err := ctx.Err(); err != nil {
+                return data, err
+            }
+        }
+
+        sm.Profiler.Begin("readback")
+        gl.BindBuffer(gl.SHADER_STORAGE_BUFFER, buffer)
+        gl.GetBufferSubData(gl.SHADER_STORAGE_BUFFER, 0, len(part)*elemSize, unsafe.Pointer(&part[0]))
+        sm.Profiler.End()
+    }
+
+    return data, nil
+}
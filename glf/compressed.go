@@ -0,0 +1,454 @@
+// Compressed Texture GL Helper Functions
+package glf
+
+import (
+	"bytes"
+	"compress/zlib"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"math/bits"
+	"os"
+
+	"github.com/go-gl/gl/v4.6-core/gl"
+)
+
+// sRGB S3TC formats from GL_EXT_texture_sRGB, which the core bindings leave out
+const (
+    compressedSRGBDXT1      = 0x8C4C
+    compressedSRGBAlphaDXT1 = 0x8C4D
+    compressedSRGBAlphaDXT3 = 0x8C4E
+    compressedSRGBAlphaDXT5 = 0x8C4F
+)
+
+// A block compressed GL format, the bytes per 4x4 block and the extensions that can provide it
+type compressedFormat struct {
+    name        string
+    blockSize   int
+    extensions  []string // Any one will do, none for formats that are core in GL 4.3
+}
+
+var compressedFormats = map[uint32]compressedFormat{
+    gl.COMPRESSED_RGB_S3TC_DXT1_EXT:        {"BC1", 8, []string{"GL_EXT_texture_compression_s3tc"}},
+    gl.COMPRESSED_RGBA_S3TC_DXT1_EXT:       {"BC1", 8, []string{"GL_EXT_texture_compression_s3tc"}},
+    gl.COMPRESSED_RGBA_S3TC_DXT3_EXT:       {"BC2", 16, []string{"GL_EXT_texture_compression_s3tc"}},
+    gl.COMPRESSED_RGBA_S3TC_DXT5_EXT:       {"BC3", 16, []string{"GL_EXT_texture_compression_s3tc"}},
+    compressedSRGBDXT1:                     {"BC1 sRGB", 8, []string{"GL_EXT_texture_compression_s3tc_srgb", "GL_EXT_texture_sRGB"}},
+    compressedSRGBAlphaDXT1:                {"BC1 sRGB", 8, []string{"GL_EXT_texture_compression_s3tc_srgb", "GL_EXT_texture_sRGB"}},
+    compressedSRGBAlphaDXT3:                {"BC2 sRGB", 16, []string{"GL_EXT_texture_compression_s3tc_srgb", "GL_EXT_texture_sRGB"}},
+    compressedSRGBAlphaDXT5:                {"BC3 sRGB", 16, []string{"GL_EXT_texture_compression_s3tc_srgb", "GL_EXT_texture_sRGB"}},
+    gl.COMPRESSED_RED_RGTC1:                {"BC4", 8, nil},
+    gl.COMPRESSED_SIGNED_RED_RGTC1:         {"BC4 signed", 8, nil},
+    gl.COMPRESSED_RG_RGTC2:                 {"BC5", 16, nil},
+    gl.COMPRESSED_SIGNED_RG_RGTC2:          {"BC5 signed", 16, nil},
+    gl.COMPRESSED_RGB_BPTC_UNSIGNED_FLOAT:  {"BC6H", 16, nil},
+    gl.COMPRESSED_RGB_BPTC_SIGNED_FLOAT:    {"BC6H signed", 16, nil},
+    gl.COMPRESSED_RGBA_BPTC_UNORM:          {"BC7", 16, nil},
+    gl.COMPRESSED_SRGB_ALPHA_BPTC_UNORM:    {"BC7 sRGB", 16, nil},
+}
+
+// CompressedTexture is a block compressed texture with its mip chain, as decoded from a DDS or KTX2 file
+type CompressedTexture struct {
+    Format          uint32      // GL compressed internal format, e.g. gl.COMPRESSED_RGBA_BPTC_UNORM
+    Width, Height   int
+    Layers          int         // Array layers, 0 for a texture that isn't an array
+    Faces           int         // 6 for cubemaps, 1 otherwise
+    Levels          [][]byte    // Each mip level from the largest, holding every layer, and every face of each layer, in order
+}
+
+// Returns the texture target the compressed texture is uploaded to
+func (tex *CompressedTexture) Target() uint32 {
+    switch {
+    case tex.Faces == 6 && tex.Layers > 0:
+        return gl.TEXTURE_CUBE_MAP_ARRAY
+    case tex.Faces == 6:
+        return gl.TEXTURE_CUBE_MAP
+    case tex.Layers > 0:
+        return gl.TEXTURE_2D_ARRAY
+    }
+    return gl.TEXTURE_2D
+}
+
+// Returns the size in bytes of one layer face of mip (level)
+func (tex *CompressedTexture) faceSize(level int) int {
+    blocksX := (max(tex.Width>>level, 1) + 3) / 4
+    blocksY := (max(tex.Height>>level, 1) + 3) / 4
+    return blocksX * blocksY * compressedFormats[tex.Format].blockSize
+}
+
+// Returns how many layer faces each level holds
+func (tex *CompressedTexture) images() int {
+    return max(tex.Layers, 1) * tex.Faces
+}
+
+// Returns an error naming the format and the missing extension if the driver can't sample (format)
+func CheckCompressedFormat(format uint32) error {
+    info, ok := compressedFormats[format]
+    if !ok {
+        return fmt.Errorf("compressed format 0x%X isn't supported", format)
+    }
+    if len(info.extensions) == 0 {
+        return nil
+    }
+    for _, extension := range info.extensions {
+        if HasExtension(extension) {
+            return nil
+        }
+    }
+    return fmt.Errorf("%s textures need %s, which the driver doesn't have", info.name, info.extensions[0])
+}
+
+// Loads a DDS or KTX2 file into a texture, returning it and the target it has to be bound to
+//
+// 2D, array, cubemap and cubemap array textures are made from the matching layouts, see NewCompressedTexture
+func LoadCompressedTexture(filePath string, opts TextureOptions) (uint32, uint32, error) {
+    tex, err := DecodeCompressedTextureFile(filePath)
+    if err != nil {
+        return 0, 0, err
+    }
+    texture, err := NewCompressedTexture(tex, opts)
+    if err != nil {
+        return 0, 0, fmt.Errorf("%s: %w", filePath, err)
+    }
+    return texture, tex.Target(), nil
+}
+
+// Uploads a compressed texture with glCompressedTexImage, leaving it bound to tex.Target()
+//
+// The mip levels come from the file, mipmaps can't be generated for compressed formats, so the texture
+// is limited to the levels it has and NoMipmaps uploads only the first. InternalFormat and PremultiplyAlpha
+// don't apply, the rest of the options work as they do for NewTextureFromImage. Cubemaps default to
+// CLAMP_TO_EDGE like NewCubemap does.
+func NewCompressedTexture(tex *CompressedTexture, opts TextureOptions) (uint32, error) {
+    var texture uint32
+    gl.GenTextures(1, &texture)
+    if err := uploadCompressed(texture, tex, opts); err != nil {
+        gl.DeleteTextures(1, &texture)
+        return 0, err
+    }
+    return texture, nil
+}
+
+// Checks a compressed texture and uploads it into (texture), replacing whatever it held
+func uploadCompressed(texture uint32, tex *CompressedTexture, opts TextureOptions) error {
+    if err := CheckCompressedFormat(tex.Format); err != nil {
+        return err
+    }
+    if len(tex.Levels) == 0 {
+        return errors.New("compressed texture has no levels")
+    }
+    for level, data := range tex.Levels {
+        if len(data) != tex.faceSize(level)*tex.images() {
+            return fmt.Errorf("compressed texture level %d is %d bytes, expected %d", level, len(data), tex.faceSize(level)*tex.images())
+        }
+    }
+    target := tex.Target()
+    if tex.Faces == 6 {
+        opts = cubemapDefaults(opts)
+    } else {
+        opts = opts.withDefaults()
+    }
+    levels := len(tex.Levels)
+    if opts.NoMipmaps {
+        if isMipmapFilter(opts.MinFilter) {
+            return errors.New("a mipmap min filter needs mipmaps, but NoMipmaps is set")
+        }
+        levels = 1
+    }
+
+    gl.BindTexture(target, texture)
+    setTextureParameters(target, opts)
+    gl.TexParameteri(target, gl.TEXTURE_MAX_LEVEL, int32(levels-1))
+    if tex.Faces == 6 {
+        gl.TexParameteri(target, gl.TEXTURE_WRAP_R, opts.WrapS)
+        gl.Enable(gl.TEXTURE_CUBE_MAP_SEAMLESS)
+    }
+
+    for level, data := range tex.Levels[:levels] {
+        width, height := int32(max(tex.Width>>level, 1)), int32(max(tex.Height>>level, 1))
+        switch target {
+        case gl.TEXTURE_2D:
+            gl.CompressedTexImage2D(target, int32(level), tex.Format, width, height, 0, int32(len(data)), gl.Ptr(data))
+        case gl.TEXTURE_CUBE_MAP:
+            size := tex.faceSize(level)
+            for face := 0; face < 6; face++ {
+                gl.CompressedTexImage2D(gl.TEXTURE_CUBE_MAP_POSITIVE_X+uint32(face), int32(level), tex.Format, width, height, 0,
+                    int32(size), gl.Ptr(data[face*size:]))
+            }
+        default:
+            gl.CompressedTexImage3D(target, int32(level), tex.Format, width, height, int32(tex.images()), 0, int32(len(data)), gl.Ptr(data))
+        }
+    }
+    if err := gl.GetError(); err != gl.NO_ERROR {
+        return fmt.Errorf("uploading the %s texture failed with GL error 0x%X", compressedFormats[tex.Format].name, err)
+    }
+    return nil
+}
+
+// Returns true if (header) starts like a DDS or KTX2 file
+func isCompressedTexture(header []byte) bool {
+    return matchDDS(header) || matchKTX2(header)
+}
+
+// Decodes a DDS or KTX2 file, picked by its content
+func DecodeCompressedTexture(r io.Reader) (*CompressedTexture, error) {
+    data, err := io.ReadAll(r)
+    if err != nil {
+        return nil, err
+    }
+    switch {
+    case matchDDS(data):
+        return decodeDDS(data)
+    case matchKTX2(data):
+        return decodeKTX2(data)
+    }
+    return nil, errors.New("not a DDS or KTX2 file")
+}
+
+// Opens and decodes a DDS or KTX2 file, see DecodeCompressedTexture
+func DecodeCompressedTextureFile(filePath string) (*CompressedTexture, error) {
+    file, err := os.Open(filePath)
+    if err != nil {
+        return nil, err
+    }
+    defer file.Close()
+
+    tex, err := DecodeCompressedTexture(file)
+    if err != nil {
+        return nil, fmt.Errorf("%s: %w", filePath, err)
+    }
+    return tex, nil
+}
+
+// Checks the size and layout of a decoded texture and that its levels fit in (data), returning the level count to use
+func checkCompressedLayout(tex *CompressedTexture, levels int) (int, error) {
+    if _, ok := compressedFormats[tex.Format]; !ok {
+        return 0, fmt.Errorf("compressed format 0x%X isn't supported", tex.Format)
+    }
+    if tex.Width <= 0 || tex.Height <= 0 || tex.Width > 1<<16 || tex.Height > 1<<16 {
+        return 0, fmt.Errorf("invalid size %dx%d", tex.Width, tex.Height)
+    }
+    if tex.Layers < 0 || tex.Layers > 1<<12 {
+        return 0, fmt.Errorf("invalid layer count %d", tex.Layers)
+    }
+    if tex.Faces == 6 && tex.Width != tex.Height {
+        return 0, fmt.Errorf("cubemap faces have to be square, not %dx%d", tex.Width, tex.Height)
+    }
+    return min(max(levels, 1), bits.Len(uint(max(tex.Width, tex.Height)))), nil
+}
+
+// DDS Container
+
+const (
+    ddsPixelFormatFourCC    = 0x4
+    ddsPixelFormatAlpha     = 0x1
+    ddsCubemap              = 0x200
+    ddsCubemapAllFaces      = 0xFC00
+    ddsVolume               = 0x200000
+    ddsMiscCubemap          = 0x4
+    ddsDimensionTexture2D   = 3
+)
+
+// DXGI formats for the BC1-BC7 blocks a DX10 header can name, typeless ones are read as UNORM
+var dxgiFormats = map[uint32]uint32{
+    70: gl.COMPRESSED_RGBA_S3TC_DXT1_EXT, 71: gl.COMPRESSED_RGBA_S3TC_DXT1_EXT, 72: compressedSRGBAlphaDXT1,
+    73: gl.COMPRESSED_RGBA_S3TC_DXT3_EXT, 74: gl.COMPRESSED_RGBA_S3TC_DXT3_EXT, 75: compressedSRGBAlphaDXT3,
+    76: gl.COMPRESSED_RGBA_S3TC_DXT5_EXT, 77: gl.COMPRESSED_RGBA_S3TC_DXT5_EXT, 78: compressedSRGBAlphaDXT5,
+    79: gl.COMPRESSED_RED_RGTC1, 80: gl.COMPRESSED_RED_RGTC1, 81: gl.COMPRESSED_SIGNED_RED_RGTC1,
+    82: gl.COMPRESSED_RG_RGTC2, 83: gl.COMPRESSED_RG_RGTC2, 84: gl.COMPRESSED_SIGNED_RG_RGTC2,
+    94: gl.COMPRESSED_RGB_BPTC_UNSIGNED_FLOAT, 95: gl.COMPRESSED_RGB_BPTC_UNSIGNED_FLOAT, 96: gl.COMPRESSED_RGB_BPTC_SIGNED_FLOAT,
+    97: gl.COMPRESSED_RGBA_BPTC_UNORM, 98: gl.COMPRESSED_RGBA_BPTC_UNORM, 99: gl.COMPRESSED_SRGB_ALPHA_BPTC_UNORM,
+}
+
+// Formats of the legacy FourCC codes
+var ddsFourCCs = map[string]uint32{
+    "DXT1": gl.COMPRESSED_RGBA_S3TC_DXT1_EXT,
+    "DXT2": gl.COMPRESSED_RGBA_S3TC_DXT3_EXT, "DXT3": gl.COMPRESSED_RGBA_S3TC_DXT3_EXT,
+    "DXT4": gl.COMPRESSED_RGBA_S3TC_DXT5_EXT, "DXT5": gl.COMPRESSED_RGBA_S3TC_DXT5_EXT,
+    "ATI1": gl.COMPRESSED_RED_RGTC1, "BC4U": gl.COMPRESSED_RED_RGTC1, "BC4S": gl.COMPRESSED_SIGNED_RED_RGTC1,
+    "ATI2": gl.COMPRESSED_RG_RGTC2, "BC5U": gl.COMPRESSED_RG_RGTC2, "BC5S": gl.COMPRESSED_SIGNED_RG_RGTC2,
+}
+
+func matchDDS(header []byte) bool {
+    return bytes.HasPrefix(header, []byte("DDS "))
+}
+
+// Decodes a BC1-BC7 DDS file, legacy or with a DX10 header, reordering its faces into levels
+func decodeDDS(data []byte) (*CompressedTexture, error) {
+    if len(data) < 128 {
+        return nil, errors.New("dds: header is truncated")
+    }
+    header := data[4:128]
+    field := func(offset int) uint32 {
+        return binary.LittleEndian.Uint32(header[offset:])
+    }
+    tex := &CompressedTexture{Width: int(field(12)), Height: int(field(8)), Faces: 1}
+    levels := int(field(24))
+    pixelFlags, fourCC := field(76), string(header[80:84])
+    caps2 := field(108)
+    offset := 128
+
+    if pixelFlags&ddsPixelFormatFourCC == 0 {
+        return nil, errors.New("dds: only block compressed (BC1-BC7) files are supported")
+    }
+    if fourCC == "DX10" {
+        if len(data) < 148 {
+            return nil, errors.New("dds: DX10 header is truncated")
+        }
+        dx10 := data[128:148]
+        format, ok := dxgiFormats[binary.LittleEndian.Uint32(dx10)]
+        if !ok {
+            return nil, fmt.Errorf("dds: DXGI format %d isn't a supported block compressed format", binary.LittleEndian.Uint32(dx10))
+        }
+        if binary.LittleEndian.Uint32(dx10[4:]) != ddsDimensionTexture2D {
+            return nil, errors.New("dds: only 2D textures are supported, not 1D or 3D ones")
+        }
+        tex.Format = format
+        if binary.LittleEndian.Uint32(dx10[8:])&ddsMiscCubemap != 0 {
+            tex.Faces = 6
+        }
+        if arraySize := int(binary.LittleEndian.Uint32(dx10[12:])); arraySize > 1 {
+            tex.Layers = arraySize
+        }
+        offset = 148
+    } else {
+        format, ok := ddsFourCCs[fourCC]
+        if !ok {
+            return nil, fmt.Errorf("dds: FourCC %q isn't a supported block compressed format", fourCC)
+        }
+        if format == gl.COMPRESSED_RGBA_S3TC_DXT1_EXT && pixelFlags&ddsPixelFormatAlpha == 0 {
+            format = gl.COMPRESSED_RGB_S3TC_DXT1_EXT
+        }
+        tex.Format = format
+        if caps2&ddsCubemap != 0 {
+            if caps2&ddsCubemapAllFaces != ddsCubemapAllFaces {
+                return nil, errors.New("dds: cubemaps missing faces aren't supported")
+            }
+            tex.Faces = 6
+        }
+    }
+    if caps2&ddsVolume != 0 {
+        return nil, errors.New("dds: volume textures aren't supported")
+    }
+    levels, err := checkCompressedLayout(tex, levels)
+    if err != nil {
+        return nil, fmt.Errorf("dds: %w", err)
+    }
+
+    // Each layer face holds its whole mip chain, levels want every layer face of one size together
+    chainSize := 0
+    for level := 0; level < levels; level++ {
+        chainSize += tex.faceSize(level)
+    }
+    if offset+chainSize*tex.images() > len(data) {
+        return nil, errors.New("dds: pixel data is truncated")
+    }
+    tex.Levels = make([][]byte, levels)
+    for level := range tex.Levels {
+        tex.Levels[level] = make([]byte, 0, tex.faceSize(level)*tex.images())
+    }
+    for image := 0; image < tex.images(); image++ {
+        at := offset + image*chainSize
+        for level := range tex.Levels {
+            size := tex.faceSize(level)
+            tex.Levels[level] = append(tex.Levels[level], data[at:at+size]...)
+            at += size
+        }
+    }
+    return tex, nil
+}
+
+// KTX2 Container
+
+// Vulkan formats for the BC1-BC7 blocks
+var vulkanFormats = map[uint32]uint32{
+    131: gl.COMPRESSED_RGB_S3TC_DXT1_EXT, 132: compressedSRGBDXT1,
+    133: gl.COMPRESSED_RGBA_S3TC_DXT1_EXT, 134: compressedSRGBAlphaDXT1,
+    135: gl.COMPRESSED_RGBA_S3TC_DXT3_EXT, 136: compressedSRGBAlphaDXT3,
+    137: gl.COMPRESSED_RGBA_S3TC_DXT5_EXT, 138: compressedSRGBAlphaDXT5,
+    139: gl.COMPRESSED_RED_RGTC1, 140: gl.COMPRESSED_SIGNED_RED_RGTC1,
+    141: gl.COMPRESSED_RG_RGTC2, 142: gl.COMPRESSED_SIGNED_RG_RGTC2,
+    143: gl.COMPRESSED_RGB_BPTC_UNSIGNED_FLOAT, 144: gl.COMPRESSED_RGB_BPTC_SIGNED_FLOAT,
+    145: gl.COMPRESSED_RGBA_BPTC_UNORM, 146: gl.COMPRESSED_SRGB_ALPHA_BPTC_UNORM,
+}
+
+// KTX2 supercompression schemes, only none and zlib are decoded
+const (
+    ktx2SupercompressionNone = 0
+    ktx2SupercompressionZlib = 3
+)
+
+var ktx2Identifier = []byte{0xAB, 'K', 'T', 'X', ' ', '2', '0', 0xBB, '\r', '\n', 0x1A, '\n'}
+
+func matchKTX2(header []byte) bool {
+    return bytes.HasPrefix(header, ktx2Identifier)
+}
+
+// Decodes a BC1-BC7 KTX2 file, uncompressed or zlib supercompressed
+func decodeKTX2(data []byte) (*CompressedTexture, error) {
+    if len(data) < 80 {
+        return nil, errors.New("ktx2: header is truncated")
+    }
+    field := func(offset int) uint32 {
+        return binary.LittleEndian.Uint32(data[offset:])
+    }
+    vkFormat := field(12)
+    format, ok := vulkanFormats[vkFormat]
+    if !ok {
+        if vkFormat == 0 {
+            return nil, errors.New("ktx2: Basis Universal textures aren't supported, transcode them to BC first")
+        }
+        return nil, fmt.Errorf("ktx2: Vulkan format %d isn't a supported block compressed format", vkFormat)
+    }
+    tex := &CompressedTexture{Format: format, Width: int(field(20)), Height: int(field(24)), Layers: int(field(32)), Faces: int(field(36))}
+    if field(28) != 0 {
+        return nil, errors.New("ktx2: 3D textures aren't supported")
+    }
+    if tex.Faces != 1 && tex.Faces != 6 {
+        return nil, fmt.Errorf("ktx2: invalid face count %d", tex.Faces)
+    }
+    supercompression := field(44)
+    if supercompression != ktx2SupercompressionNone && supercompression != ktx2SupercompressionZlib {
+        return nil, fmt.Errorf("ktx2: supercompression scheme %d isn't supported", supercompression)
+    }
+    levels, err := checkCompressedLayout(tex, int(field(40)))
+    if err != nil {
+        return nil, fmt.Errorf("ktx2: %w", err)
+    }
+    if 80+levels*24 > len(data) {
+        return nil, errors.New("ktx2: level index is truncated")
+    }
+
+    // Levels are already stored with every layer face of one size together
+    tex.Levels = make([][]byte, levels)
+    for level := range tex.Levels {
+        entry := data[80+level*24:]
+        at, length := binary.LittleEndian.Uint64(entry), binary.LittleEndian.Uint64(entry[8:])
+        if at > uint64(len(data)) || length > uint64(len(data))-at {
+            return nil, fmt.Errorf("ktx2: level %d is out of range", level)
+        }
+        levelData := data[at : at+length]
+        size := tex.faceSize(level) * tex.images()
+        if supercompression == ktx2SupercompressionZlib {
+            if uncompressed := binary.LittleEndian.Uint64(entry[16:]); uncompressed != uint64(size) {
+                return nil, fmt.Errorf("ktx2: level %d inflates to %d bytes, expected %d", level, uncompressed, size)
+            }
+            reader, err := zlib.NewReader(bytes.NewReader(levelData))
+            if err != nil {
+                return nil, fmt.Errorf("ktx2: level %d: %w", level, err)
+            }
+            // The header sizes aren't trusted with an allocation, the buffer only grows as the data inflates
+            levelData, err = io.ReadAll(io.LimitReader(reader, int64(size)+1))
+            reader.Close()
+            if err != nil {
+                return nil, fmt.Errorf("ktx2: level %d: %w", level, err)
+            }
+        }
+        if len(levelData) != size {
+            return nil, fmt.Errorf("ktx2: level %d is %d bytes, expected %d", level, len(levelData), size)
+        }
+        tex.Levels[level] = levelData
+    }
+    return tex, nil
+}
@@ -0,0 +1,355 @@
+package glf
+
+import (
+	"bytes"
+	"compress/zlib"
+	"encoding/binary"
+	"strings"
+	"testing"
+
+	"github.com/go-gl/gl/v4.6-core/gl"
+)
+
+// DDS header fields for makeDDS
+type ddsHeader struct {
+    width, height   int
+    levels          int
+    pixelFlags      uint32
+    fourCC          string
+    caps2           uint32
+    dx10            []uint32 // DXGI format, dimension, misc flags, array size, nil for a legacy header
+}
+
+// Builds a DDS file from its header and pixel data
+func makeDDS(h ddsHeader, pixels []byte) []byte {
+    data := make([]byte, 128)
+    copy(data, "DDS ")
+    put := func(offset int, value uint32) {
+        binary.LittleEndian.PutUint32(data[offset:], value)
+    }
+    put(4, 124)
+    put(12, uint32(h.height))
+    put(16, uint32(h.width))
+    put(28, uint32(h.levels))
+    put(76, 32)
+    put(80, h.pixelFlags)
+    copy(data[84:88], h.fourCC)
+    put(112, h.caps2)
+    if h.dx10 != nil {
+        dx10 := make([]byte, 20)
+        for i, value := range h.dx10 {
+            binary.LittleEndian.PutUint32(dx10[4*i:], value)
+        }
+        data = append(data, dx10...)
+    }
+    return append(data, pixels...)
+}
+
+// Returns (n) bytes where byte i is (seed + i), so every block of a test texture is distinct
+func testBytes(n int, seed byte) []byte {
+    data := make([]byte, n)
+    for i := range data {
+        data[i] = seed + byte(i)
+    }
+    return data
+}
+
+func TestDecodeDDS(t *testing.T) {
+    // An 8x8 BC1 chain is 32 + 8 + 8 + 8 bytes
+    chain := testBytes(56, 0)
+    tests := []struct {
+        name    string
+        file    []byte
+        format  uint32
+        levels  int
+        layers  int
+        faces   int
+    }{
+        {"DXT1 without alpha", makeDDS(ddsHeader{width: 8, height: 8, levels: 4, pixelFlags: ddsPixelFormatFourCC, fourCC: "DXT1"}, chain),
+            gl.COMPRESSED_RGB_S3TC_DXT1_EXT, 4, 0, 1},
+        {"DXT1 with alpha", makeDDS(ddsHeader{width: 8, height: 8, levels: 4, pixelFlags: ddsPixelFormatFourCC | ddsPixelFormatAlpha, fourCC: "DXT1"}, chain),
+            gl.COMPRESSED_RGBA_S3TC_DXT1_EXT, 4, 0, 1},
+        {"no mip count", makeDDS(ddsHeader{width: 8, height: 8, pixelFlags: ddsPixelFormatFourCC, fourCC: "ATI1"}, chain[:32]),
+            gl.COMPRESSED_RED_RGTC1, 1, 0, 1},
+        {"too many levels", makeDDS(ddsHeader{width: 8, height: 8, levels: 10, pixelFlags: ddsPixelFormatFourCC, fourCC: "BC4S"}, chain),
+            gl.COMPRESSED_SIGNED_RED_RGTC1, 4, 0, 1},
+        {"DX10 BC7 sRGB", makeDDS(ddsHeader{width: 4, height: 4, levels: 1, pixelFlags: ddsPixelFormatFourCC, fourCC: "DX10", dx10: []uint32{99, ddsDimensionTexture2D, 0, 1}}, testBytes(16, 0)),
+            gl.COMPRESSED_SRGB_ALPHA_BPTC_UNORM, 1, 0, 1},
+        {"DX10 array", makeDDS(ddsHeader{width: 4, height: 4, levels: 1, pixelFlags: ddsPixelFormatFourCC, fourCC: "DX10", dx10: []uint32{98, ddsDimensionTexture2D, 0, 3}}, testBytes(48, 0)),
+            gl.COMPRESSED_RGBA_BPTC_UNORM, 1, 3, 1},
+        {"DX10 cubemap", makeDDS(ddsHeader{width: 4, height: 4, levels: 1, pixelFlags: ddsPixelFormatFourCC, fourCC: "DX10", dx10: []uint32{83, ddsDimensionTexture2D, ddsMiscCubemap, 1}}, testBytes(96, 0)),
+            gl.COMPRESSED_RG_RGTC2, 1, 0, 6},
+    }
+    for _, test := range tests {
+        tex, err := DecodeCompressedTexture(bytes.NewReader(test.file))
+        if err != nil {
+            t.Errorf("%s: %v", test.name, err)
+            continue
+        }
+        if tex.Format != test.format || len(tex.Levels) != test.levels || tex.Layers != test.layers || tex.Faces != test.faces {
+            t.Errorf("%s: got format 0x%X, %d levels, %d layers, %d faces, want 0x%X, %d, %d, %d", test.name,
+                tex.Format, len(tex.Levels), tex.Layers, tex.Faces, test.format, test.levels, test.layers, test.faces)
+        }
+        for level, data := range tex.Levels {
+            if len(data) != tex.faceSize(level)*tex.images() {
+                t.Errorf("%s: level %d is %d bytes", test.name, level, len(data))
+            }
+        }
+    }
+}
+
+// DDS stores each face's whole mip chain in turn, the levels have to hold every face of one size together
+func TestDecodeDDSReordersFaces(t *testing.T) {
+    // A 4x4 BC3 chain is 16 + 16 + 16 bytes, every face gets its own byte range
+    var pixels []byte
+    for face := 0; face < 6; face++ {
+        pixels = append(pixels, testBytes(48, byte(face*48))...)
+    }
+    file := makeDDS(ddsHeader{width: 4, height: 4, levels: 3, pixelFlags: ddsPixelFormatFourCC, fourCC: "DXT5", caps2: ddsCubemap | ddsCubemapAllFaces}, pixels)
+    tex, err := decodeDDS(file)
+    if err != nil {
+        t.Fatal(err)
+    }
+    if tex.Faces != 6 || tex.Target() != gl.TEXTURE_CUBE_MAP || len(tex.Levels) != 3 {
+        t.Fatalf("got %d faces, target 0x%X, %d levels, want a cubemap with 3 levels", tex.Faces, tex.Target(), len(tex.Levels))
+    }
+    for level, data := range tex.Levels {
+        for face := 0; face < 6; face++ {
+            want := pixels[face*48+level*16 : face*48+level*16+16]
+            if got := data[face*16 : face*16+16]; !bytes.Equal(got, want) {
+                t.Errorf("level %d face %d starts with %d, want %d", level, face, got[0], want[0])
+            }
+        }
+    }
+}
+
+func TestDecodeDDSErrors(t *testing.T) {
+    bc1 := ddsHeader{width: 4, height: 4, levels: 1, pixelFlags: ddsPixelFormatFourCC, fourCC: "DXT1"}
+    withHeader := func(change func(h *ddsHeader)) ddsHeader {
+        h := bc1
+        change(&h)
+        return h
+    }
+    tests := []struct {
+        name    string
+        file    []byte
+        want    string
+    }{
+        {"header", makeDDS(bc1, nil)[:100], "header is truncated"},
+        {"uncompressed", makeDDS(withHeader(func(h *ddsHeader) { h.pixelFlags = 0x40 }), testBytes(8, 0)), "only block compressed"},
+        {"FourCC", makeDDS(withHeader(func(h *ddsHeader) { h.fourCC = "ETC1" }), testBytes(8, 0)), `FourCC "ETC1"`},
+        {"DX10 header", makeDDS(withHeader(func(h *ddsHeader) { h.fourCC = "DX10" }), nil), "DX10 header is truncated"},
+        {"DXGI format", makeDDS(withHeader(func(h *ddsHeader) { h.fourCC = "DX10"; h.dx10 = []uint32{28, ddsDimensionTexture2D, 0, 1} }), testBytes(8, 0)), "DXGI format 28"},
+        {"1D", makeDDS(withHeader(func(h *ddsHeader) { h.fourCC = "DX10"; h.dx10 = []uint32{71, 2, 0, 1} }), testBytes(8, 0)), "only 2D textures"},
+        {"volume", makeDDS(withHeader(func(h *ddsHeader) { h.caps2 = ddsVolume }), testBytes(8, 0)), "volume textures"},
+        {"missing faces", makeDDS(withHeader(func(h *ddsHeader) { h.caps2 = ddsCubemap | 0x400 }), testBytes(48, 0)), "missing faces"},
+        {"zero size", makeDDS(withHeader(func(h *ddsHeader) { h.width = 0 }), testBytes(8, 0)), "invalid size 0x4"},
+        {"too big", makeDDS(withHeader(func(h *ddsHeader) { h.width = 1<<16 + 1 }), testBytes(8, 0)), "invalid size"},
+        {"square cubemap", makeDDS(withHeader(func(h *ddsHeader) { h.height = 8; h.caps2 = ddsCubemap | ddsCubemapAllFaces }), testBytes(96, 0)), "have to be square"},
+        {"pixels", makeDDS(withHeader(func(h *ddsHeader) { h.levels = 3 }), testBytes(15, 0)), "pixel data is truncated"},
+        {"array pixels", makeDDS(withHeader(func(h *ddsHeader) { h.fourCC = "DX10"; h.dx10 = []uint32{71, ddsDimensionTexture2D, 0, 1 << 12} }), testBytes(8, 0)), "pixel data is truncated"},
+    }
+    for _, test := range tests {
+        _, err := decodeDDS(test.file)
+        if err == nil || !strings.HasPrefix(err.Error(), "dds: ") || !strings.Contains(err.Error(), test.want) {
+            t.Errorf("%s: got error %v, want one containing %q", test.name, err, test.want)
+        }
+    }
+}
+
+// KTX2 header fields for makeKTX2
+type ktx2Header struct {
+    vkFormat            uint32
+    width, height       int
+    depth, layers       int
+    faces               int
+    supercompression    uint32
+}
+
+// Builds a KTX2 file with one level index entry per element of (levels), zlib compressing them if the
+// header says so
+func makeKTX2(h ktx2Header, levels ...[]byte) []byte {
+    data := make([]byte, 80+24*len(levels))
+    copy(data, ktx2Identifier)
+    put := func(offset int, value uint32) {
+        binary.LittleEndian.PutUint32(data[offset:], value)
+    }
+    put(12, h.vkFormat)
+    put(20, uint32(h.width))
+    put(24, uint32(h.height))
+    put(28, uint32(h.depth))
+    put(32, uint32(h.layers))
+    put(36, uint32(h.faces))
+    put(40, uint32(len(levels)))
+    put(44, h.supercompression)
+    for level, pixels := range levels {
+        stored := pixels
+        if h.supercompression == ktx2SupercompressionZlib {
+            var b bytes.Buffer
+            writer := zlib.NewWriter(&b)
+            writer.Write(pixels)
+            writer.Close()
+            stored = b.Bytes()
+        }
+        entry := data[80+level*24:]
+        binary.LittleEndian.PutUint64(entry, uint64(len(data)))
+        binary.LittleEndian.PutUint64(entry[8:], uint64(len(stored)))
+        binary.LittleEndian.PutUint64(entry[16:], uint64(len(pixels)))
+        data = append(data, stored...)
+    }
+    return data
+}
+
+func TestDecodeKTX2(t *testing.T) {
+    bc7 := ktx2Header{vkFormat: 145, width: 8, height: 8, faces: 1}
+    zlibBC7 := bc7
+    zlibBC7.supercompression = ktx2SupercompressionZlib
+    cubeArray := ktx2Header{vkFormat: 133, width: 4, height: 4, layers: 2, faces: 6}
+    tests := []struct {
+        name    string
+        file    []byte
+        format  uint32
+        target  uint32
+    }{
+        {"BC7", makeKTX2(bc7, testBytes(64, 0), testBytes(16, 1)), gl.COMPRESSED_RGBA_BPTC_UNORM, gl.TEXTURE_2D},
+        {"zlib", makeKTX2(zlibBC7, testBytes(64, 0), testBytes(16, 1)), gl.COMPRESSED_RGBA_BPTC_UNORM, gl.TEXTURE_2D},
+        {"cubemap array", makeKTX2(cubeArray, testBytes(96, 0)), gl.COMPRESSED_RGBA_S3TC_DXT1_EXT, gl.TEXTURE_CUBE_MAP_ARRAY},
+    }
+    for _, test := range tests {
+        tex, err := DecodeCompressedTexture(bytes.NewReader(test.file))
+        if err != nil {
+            t.Errorf("%s: %v", test.name, err)
+            continue
+        }
+        if tex.Format != test.format || tex.Target() != test.target {
+            t.Errorf("%s: got format 0x%X and target 0x%X, want 0x%X and 0x%X", test.name, tex.Format, tex.Target(), test.format, test.target)
+        }
+        for level, data := range tex.Levels {
+            if !bytes.Equal(data, testBytes(len(data), byte(level))) {
+                t.Errorf("%s: level %d doesn't hold the file's data", test.name, level)
+            }
+        }
+    }
+}
+
+func TestDecodeKTX2Errors(t *testing.T) {
+    bc1 := ktx2Header{vkFormat: 131, width: 4, height: 4, faces: 1}
+    withHeader := func(change func(h *ktx2Header)) ktx2Header {
+        h := bc1
+        change(&h)
+        return h
+    }
+    zlib := withHeader(func(h *ktx2Header) { h.supercompression = ktx2SupercompressionZlib })
+
+    outOfRange := makeKTX2(bc1, testBytes(8, 0))
+    binary.LittleEndian.PutUint64(outOfRange[80:], 1000)
+    tooLong := makeKTX2(bc1, testBytes(8, 0))
+    binary.LittleEndian.PutUint64(tooLong[88:], ^uint64(0))
+    wrongInflatedSize := makeKTX2(zlib, testBytes(8, 0))
+    binary.LittleEndian.PutUint64(wrongInflatedSize[96:], 1<<40)
+    notZlib := makeKTX2(bc1, testBytes(8, 0))
+    binary.LittleEndian.PutUint32(notZlib[44:], ktx2SupercompressionZlib)
+    // A header asking for 65536x65536 BC7 cubemaps in 4096 layers, which mustn't be allocated up front
+    huge := withHeader(func(h *ktx2Header) { h.vkFormat = 145; h.width, h.height, h.layers, h.faces = 1<<16, 1<<16, 1<<12, 6; h.supercompression = ktx2SupercompressionZlib })
+    hugeFile := makeKTX2(huge, testBytes(16, 0))
+    binary.LittleEndian.PutUint64(hugeFile[96:], (1<<16/4)*(1<<16/4)*16*6<<12)
+
+    tests := []struct {
+        name    string
+        file    []byte
+        want    string
+    }{
+        {"header", makeKTX2(bc1)[:60], "header is truncated"},
+        {"Basis", makeKTX2(withHeader(func(h *ktx2Header) { h.vkFormat = 0 }), testBytes(8, 0)), "Basis Universal"},
+        {"Vulkan format", makeKTX2(withHeader(func(h *ktx2Header) { h.vkFormat = 37 }), testBytes(8, 0)), "Vulkan format 37"},
+        {"3D", makeKTX2(withHeader(func(h *ktx2Header) { h.depth = 4 }), testBytes(8, 0)), "3D textures"},
+        {"faces", makeKTX2(withHeader(func(h *ktx2Header) { h.faces = 2 }), testBytes(16, 0)), "invalid face count 2"},
+        {"supercompression", makeKTX2(withHeader(func(h *ktx2Header) { h.supercompression = 1 }), testBytes(8, 0)), "supercompression scheme 1"},
+        {"layers", makeKTX2(withHeader(func(h *ktx2Header) { h.layers = 1<<12 + 1 }), testBytes(8, 0)), "invalid layer count"},
+        {"level index", makeKTX2(bc1, testBytes(8, 0))[:90], "level index is truncated"},
+        {"level offset", outOfRange, "level 0 is out of range"},
+        {"level length", tooLong, "level 0 is out of range"},
+        {"level size", makeKTX2(bc1, testBytes(7, 0)), "level 0 is 7 bytes, expected 8"},
+        {"inflated size", makeKTX2(zlib, testBytes(9, 0)), "level 0 inflates to 9 bytes, expected 8"},
+        {"inflated size field", wrongInflatedSize, "inflates to 1099511627776 bytes"},
+        {"not zlib", notZlib, "level 0: zlib"},
+        {"huge", hugeFile, "level 0 is 16 bytes"},
+    }
+    for _, test := range tests {
+        _, err := decodeKTX2(test.file)
+        if err == nil || !strings.HasPrefix(err.Error(), "ktx2: ") || !strings.Contains(err.Error(), test.want) {
+            t.Errorf("%s: got error %v, want one containing %q", test.name, err, test.want)
+        }
+    }
+}
+
+func TestDecodeCompressedTextureUnknown(t *testing.T) {
+    if _, err := DecodeCompressedTexture(strings.NewReader("\x89PNG\r\n\x1a\n")); err == nil {
+        t.Error("a PNG decoded as a compressed texture")
+    }
+    if !isCompressedTexture([]byte("DDS ")) || !isCompressedTexture(ktx2Identifier) || isCompressedTexture([]byte("DDS")) {
+        t.Error("isCompressedTexture doesn't match the DDS and KTX2 magic")
+    }
+}
+
+func TestCheckCompressedLayout(t *testing.T) {
+    tests := []struct {
+        tex     CompressedTexture
+        levels  int
+        want    int
+        err     bool
+    }{
+        {CompressedTexture{Format: gl.COMPRESSED_RGBA_BPTC_UNORM, Width: 256, Height: 64, Faces: 1}, 0, 1, false},
+        {CompressedTexture{Format: gl.COMPRESSED_RGBA_BPTC_UNORM, Width: 256, Height: 64, Faces: 1}, 5, 5, false},
+        {CompressedTexture{Format: gl.COMPRESSED_RGBA_BPTC_UNORM, Width: 256, Height: 64, Faces: 1}, 20, 9, false},
+        {CompressedTexture{Format: gl.COMPRESSED_RGBA_BPTC_UNORM, Width: 1, Height: 1, Faces: 1}, 3, 1, false},
+        {CompressedTexture{Format: gl.COMPRESSED_RGBA_BPTC_UNORM, Width: 1 << 16, Height: 1 << 16, Layers: 1 << 12, Faces: 6}, 1, 1, false},
+        {CompressedTexture{Format: gl.RGBA8, Width: 4, Height: 4, Faces: 1}, 1, 0, true},
+        {CompressedTexture{Format: gl.COMPRESSED_RGBA_BPTC_UNORM, Width: 4, Height: -4, Faces: 1}, 1, 0, true},
+        {CompressedTexture{Format: gl.COMPRESSED_RGBA_BPTC_UNORM, Width: 4, Height: 4, Layers: -1, Faces: 1}, 1, 0, true},
+        {CompressedTexture{Format: gl.COMPRESSED_RGBA_BPTC_UNORM, Width: 8, Height: 4, Faces: 6}, 1, 0, true},
+    }
+    for i, test := range tests {
+        got, err := checkCompressedLayout(&test.tex, test.levels)
+        if (err != nil) != test.err || got != test.want {
+            t.Errorf("%d: checkCompressedLayout(%dx%d, %d levels) = %d, %v, want %d, error %v", i, test.tex.Width, test.tex.Height, test.levels, got, err, test.want, test.err)
+        }
+    }
+}
+
+// Every format the containers can name needs an entry in compressedFormats for its block size
+func TestCompressedFormatTables(t *testing.T) {
+    tables := map[string]map[uint32]uint32{"DXGI": dxgiFormats, "Vulkan": vulkanFormats}
+    for code, format := range ddsFourCCs {
+        if _, ok := compressedFormats[format]; !ok {
+            t.Errorf("FourCC %q maps to 0x%X, which isn't in compressedFormats", code, format)
+        }
+    }
+    for name, table := range tables {
+        for code, format := range table {
+            if _, ok := compressedFormats[format]; !ok {
+                t.Errorf("%s format %d maps to 0x%X, which isn't in compressedFormats", name, code, format)
+            }
+        }
+    }
+
+    spot := []struct {
+        table   map[uint32]uint32
+        code    uint32
+        want    uint32
+    }{
+        {dxgiFormats, 71, gl.COMPRESSED_RGBA_S3TC_DXT1_EXT},
+        {dxgiFormats, 78, compressedSRGBAlphaDXT5},
+        {dxgiFormats, 95, gl.COMPRESSED_RGB_BPTC_UNSIGNED_FLOAT},
+        {vulkanFormats, 131, gl.COMPRESSED_RGB_S3TC_DXT1_EXT},
+        {vulkanFormats, 146, gl.COMPRESSED_SRGB_ALPHA_BPTC_UNORM},
+    }
+    for _, test := range spot {
+        if got := test.table[test.code]; got != test.want {
+            t.Errorf("format %d maps to 0x%X, want 0x%X", test.code, got, test.want)
+        }
+    }
+    if compressedFormats[gl.COMPRESSED_RGBA_S3TC_DXT1_EXT].blockSize != 8 || compressedFormats[gl.COMPRESSED_RGBA_BPTC_UNORM].blockSize != 16 {
+        t.Error("BC1 blocks are 8 bytes and BC7 blocks 16")
+    }
+}
@@ -0,0 +1,237 @@
+// Compute Context GL Helper Functions
+package glf
+
+import (
+	"errors"
+	"fmt"
+	"log"
+	"sync"
+
+	"github.com/veandco/go-sdl2/sdl"
+)
+
+// Which windowing API a ComputeContext is created with
+type ContextBackend int
+const (
+    ContextAuto ContextBackend = iota // SDL, then headless EGL if SDL fails
+    ContextSDL                        // Hidden 1x1 SDL window, needs a video driver
+    ContextEGL                        // Surfaceless or device EGL display, needs the egl build tag
+)
+
+func (backend ContextBackend) String() string {
+    switch backend {
+    case ContextSDL:
+        return "SDL"
+    case ContextEGL:
+        return "EGL"
+    }
+    return "Auto"
+}
+
+// Backend AcquireComputeContext and the InitShaderManager functions create contexts with
+var DefaultContextBackend = ContextAuto
+
+// ComputeContext is an offscreen GL context that many ShaderManagers can share
+//
+// It's reference counted, the context (and SDL for the SDL backend) is only torn down when the last
+// user releases it. There is no default framebuffer, so rendering has to go to a framebuffer object.
+type ComputeContext struct {
+    Window      *sdl.Window     // Hidden window of the SDL backend, nil for EGL
+    GLContext   sdl.GLContext   // Context of the SDL backend, 0 for EGL
+    Backend     ContextBackend  // ContextSDL or ContextEGL, never ContextAuto
+    Thread      *GLThread       // Thread that owns the context, nil if it's current on the thread that acquired it
+    egl         *eglContext
+    refs        int
+}
+
+var sharedContexts []*ComputeContext
+var contextMutex sync.Mutex
+
+// Gets a shared compute context made with DefaultContextBackend, with a reference held for the caller
+func AcquireComputeContext() (*ComputeContext, error) {
+    return AcquireComputeContextWith(DefaultContextBackend)
+}
+
+// Gets a shared compute context for (backend) with a reference held for the caller, creating it on first use
+//
+// ContextAuto reuses whatever shared context already exists. A new context is made current on the calling thread.
+func AcquireComputeContextWith(backend ContextBackend) (*ComputeContext, error) {
+    contextMutex.Lock()
+    defer contextMutex.Unlock()
+
+    for _, ctx := range sharedContexts {
+        if backend == ContextAuto || ctx.Backend == backend {
+            ctx.refs++
+            return ctx, nil
+        }
+    }
+
+    ctx, err := newComputeContext(backend)
+    if err != nil {
+        return nil, err
+    }
+    ctx.refs = 1
+    sharedContexts = append(sharedContexts, ctx)
+    return ctx, nil
+}
+
+// Creates a context with the given backend, falling back from SDL to EGL for ContextAuto
+func newComputeContext(backend ContextBackend) (*ComputeContext, error) {
+    switch backend {
+    case ContextSDL:
+        window, glContext, err := TryInitSdlNoWindow()
+        if err != nil {
+            return nil, err
+        }
+        return &ComputeContext{Window: window, GLContext: glContext, Backend: ContextSDL}, nil
+    case ContextEGL:
+        egl, err := newEGLContext()
+        if err != nil {
+            return nil, err
+        }
+        return &ComputeContext{Backend: ContextEGL, egl: egl}, nil
+    }
+
+    ctx, sdlErr := newComputeContext(ContextSDL)
+    if sdlErr == nil {
+        return ctx, nil
+    }
+    ctx, eglErr := newComputeContext(ContextEGL)
+    if eglErr == nil {
+        if Verbose {
+            fmt.Printf("%s, using a headless EGL context\n", sdlErr)
+        }
+        return ctx, nil
+    }
+    return nil, errors.Join(sdlErr, eglErr)
+}
+
+// Adds a reference to the context, every Retain needs a matching Release
+func (ctx *ComputeContext) Retain() *ComputeContext {
+    contextMutex.Lock()
+    defer contextMutex.Unlock()
+    ctx.refs++
+    return ctx
+}
+
+// Drops a reference to the context, and destroys it if it was the last one
+//
+// A context with its own GLThread is destroyed on that thread, and the thread is stopped. Releasing a
+// context that's already destroyed does nothing.
+func (ctx *ComputeContext) Release() {
+    contextMutex.Lock()
+    if ctx.refs <= 0 {
+        contextMutex.Unlock()
+        return
+    }
+    ctx.refs--
+    last := ctx.refs == 0
+    if last {
+        for i, shared := range sharedContexts {
+            if shared == ctx {
+                sharedContexts = append(sharedContexts[:i], sharedContexts[i+1:]...)
+                break
+            }
+        }
+    }
+    contextMutex.Unlock()
+
+    if !last {
+        return
+    }
+    if ctx.Thread == nil {
+        ctx.destroy()
+        return
+    }
+    ctx.Thread.Call(ctx.destroy)
+    if ctx.Thread.OnThread() {
+        go ctx.Thread.Close()
+    } else {
+        ctx.Thread.Close()
+    }
+}
+
+// Destroys the context, and quits SDL for the SDL backend
+func (ctx *ComputeContext) destroy() {
+    switch ctx.Backend {
+    case ContextSDL:
+        sdl.GLDeleteContext(ctx.GLContext)
+        ctx.Window.Destroy()
+        sdl.Quit()
+    case ContextEGL:
+        ctx.egl.destroy()
+    }
+}
+
+// Starts a GLThread owning a new, unshared compute context with one reference held for the caller
+//
+// Every ShaderManager on the context sends its GL work to the thread, so it can be used from any goroutine.
+// Other GL work can be run on it with ctx.Thread.Call or ctx.Thread.Submit.
+func NewThreadedComputeContext(backend ContextBackend) (*ComputeContext, error) {
+    var ctx *ComputeContext
+    thread, err := NewGLThread(func() error {
+        var err error
+        ctx, err = newComputeContext(backend)
+        return err
+    })
+    if err != nil {
+        return nil, err
+    }
+    ctx.Thread = thread
+    ctx.refs = 1
+    return ctx, nil
+}
+
+// Runs f on the context's GLThread if it has one, otherwise right away on the calling thread
+func (ctx *ComputeContext) Do(f func()) {
+    if ctx == nil || ctx.Thread == nil {
+        f()
+        return
+    }
+    ctx.Thread.Call(f)
+}
+
+// Makes the context current on the calling thread, the context's GLThread if it has one
+func (ctx *ComputeContext) MakeCurrent() error {
+    var err error
+    ctx.Do(func() {
+        if ctx.Backend == ContextEGL {
+            err = ctx.egl.makeCurrent()
+        } else {
+            err = ctx.Window.GLMakeCurrent(ctx.GLContext)
+        }
+    })
+    return err
+}
+
+// Compiles a compute shader on (ctx) and returns a ShaderManager holding a reference to it
+//
+// Cleanup on the ShaderManager deletes its program and releases the reference, the context stays
+// alive for the other ShaderManagers using it. Exits if the shader doesn't compile, see TryNewShaderManager.
+func NewShaderManager[T ShaderData](ctx *ComputeContext, shaderSource, sourceFile string) *ShaderManager[T] {
+    sm, err := TryNewShaderManager[T](ctx, shaderSource, sourceFile)
+    if err != nil {
+        log.Fatal(err)
+    }
+    return sm
+}
+
+// Compiles a compute shader on (ctx) like NewShaderManager, returning the compile error instead of exiting
+func TryNewShaderManager[T ShaderData](ctx *ComputeContext, shaderSource, sourceFile string) (*ShaderManager[T], error) {
+    var program uint32
+    var err error
+    ctx.Do(func() {
+        program, err = TryCreateComputeShader(shaderSource, sourceFile)
+    })
+    if err != nil {
+        return nil, err
+    }
+    ctx.Retain()
+    return &ShaderManager[T]{
+        Window:         ctx.Window,
+        GLContext:      ctx.GLContext,
+        ShaderProgram:  program,
+        Context:        ctx,
+        source:         shaderSource,
+    }, nil
+}
@@ -0,0 +1,171 @@
+// Atomic Counter and Indirect Buffer GL Helper Functions
+package glf
+
+import (
+	"github.com/go-gl/gl/v4.6-core/gl"
+)
+
+// A buffer that gets bound along with the SSBO every time a ShaderManager dispatches, see Attach
+type BoundBuffer interface {
+    Bind()
+}
+
+// AtomicCounterBuffer holds uint atomic counters for a layout(binding = n) uniform atomic_uint
+type AtomicCounterBuffer struct {
+    ID          uint32
+    Binding     uint32
+    Count       int
+}
+
+// IndirectBuffer holds dispatch or draw arguments that a shader writes for itself
+//
+// The buffer is bound as an SSBO at (Binding) so a shader can write the arguments, and to (Target)
+// when GL reads them
+type IndirectBuffer struct {
+    ID          uint32
+    Target      uint32 // gl.DISPATCH_INDIRECT_BUFFER or gl.DRAW_INDIRECT_BUFFER
+    Binding     uint32
+    Size        int    // Number of uint32 arguments
+}
+
+// Creates a buffer of (count) atomic counters for the atomic counter (binding), all set to 0
+func NewAtomicCounterBuffer(binding uint32, count int) *AtomicCounterBuffer {
+    counters := &AtomicCounterBuffer{Binding: binding, Count: count}
+    counters.ID = GenBindBuffers(gl.ATOMIC_COUNTER_BUFFER)
+    BufferData(gl.ATOMIC_COUNTER_BUFFER, make([]uint32, count), gl.DYNAMIC_COPY)
+    gl.BindBuffer(gl.ATOMIC_COUNTER_BUFFER, 0)
+    return counters
+}
+
+// Binds the counters to their atomic counter binding
+func (counters *AtomicCounterBuffer) Bind() {
+    gl.BindBufferBase(gl.ATOMIC_COUNTER_BUFFER, counters.Binding, counters.ID)
+}
+
+// Sets the counters to (values) in order, and any counters past the given values to 0
+func (counters *AtomicCounterBuffer) Reset(values ...uint32) {
+    data := make([]uint32, counters.Count)
+    copy(data, values)
+    gl.BindBuffer(gl.ATOMIC_COUNTER_BUFFER, counters.ID)
+    BufferSubData(gl.ATOMIC_COUNTER_BUFFER, 0, data)
+    gl.BindBuffer(gl.ATOMIC_COUNTER_BUFFER, 0)
+}
+
+// Reads the counters back after the dispatches that wrote them
+func (counters *AtomicCounterBuffer) Read() []uint32 {
+    data := make([]uint32, counters.Count)
+    if len(data) == 0 {
+        return data
+    }
+    gl.MemoryBarrier(gl.ATOMIC_COUNTER_BARRIER_BIT | gl.BUFFER_UPDATE_BARRIER_BIT)
+    gl.BindBuffer(gl.ATOMIC_COUNTER_BUFFER, counters.ID)
+    gl.GetBufferSubData(gl.ATOMIC_COUNTER_BUFFER, 0, counters.Count*4, gl.Ptr(data))
+    gl.BindBuffer(gl.ATOMIC_COUNTER_BUFFER, 0)
+    return data
+}
+
+// Deletes the buffer
+func (counters *AtomicCounterBuffer) Delete() {
+    gl.DeleteBuffers(1, &counters.ID)
+}
+
+// Creates a buffer for the 3 workgroup counts of glDispatchComputeIndirect, set to 1, 1, 1
+//
+// A shader writes them through the SSBO at (binding), which mustn't be 0 when used with Execute
+func NewDispatchIndirectBuffer(binding uint32) *IndirectBuffer {
+    return newIndirectBuffer(gl.DISPATCH_INDIRECT_BUFFER, binding, []uint32{1, 1, 1})
+}
+
+// Creates a buffer for (commands) draw commands of glDrawArraysIndirect, or glDrawElementsIndirect if (indexed)
+//
+// Array commands are count, instanceCount, first, baseInstance, and indexed ones are count, instanceCount,
+// firstIndex, baseVertex, baseInstance, all set to 0
+func NewDrawIndirectBuffer(binding uint32, commands int, indexed bool) *IndirectBuffer {
+    size := 4
+    if indexed {
+        size = 5
+    }
+    return newIndirectBuffer(gl.DRAW_INDIRECT_BUFFER, binding, make([]uint32, commands*size))
+}
+
+// Creates an indirect buffer for (target) holding (args)
+func newIndirectBuffer(target, binding uint32, args []uint32) *IndirectBuffer {
+    indirect := &IndirectBuffer{Target: target, Binding: binding, Size: len(args)}
+    indirect.ID = GenBindBuffers(target)
+    BufferData(target, args, gl.DYNAMIC_COPY)
+    gl.BindBuffer(target, 0)
+    return indirect
+}
+
+// Binds the arguments as an SSBO at (Binding) so a shader can write them
+func (indirect *IndirectBuffer) Bind() {
+    gl.BindBufferBase(gl.SHADER_STORAGE_BUFFER, indirect.Binding, indirect.ID)
+}
+
+// Binds the arguments to (Target) for the next indirect dispatch or draw, making shader writes to them visible first
+func (indirect *IndirectBuffer) BindCommand() {
+    gl.MemoryBarrier(gl.COMMAND_BARRIER_BIT)
+    gl.BindBuffer(indirect.Target, indirect.ID)
+}
+
+// Overwrites the arguments from the start of the buffer, any past its Size are dropped
+func (indirect *IndirectBuffer) Set(args ...uint32) {
+    gl.BindBuffer(indirect.Target, indirect.ID)
+    BufferSubData(indirect.Target, 0, args[:min(len(args), indirect.Size)])
+    gl.BindBuffer(indirect.Target, 0)
+}
+
+// Reads the arguments back after the dispatches that wrote them
+func (indirect *IndirectBuffer) Read() []uint32 {
+    args := make([]uint32, indirect.Size)
+    if len(args) == 0 {
+        return args
+    }
+    gl.MemoryBarrier(gl.BUFFER_UPDATE_BARRIER_BIT)
+    gl.BindBuffer(indirect.Target, indirect.ID)
+    gl.GetBufferSubData(indirect.Target, 0, indirect.Size*4, gl.Ptr(args))
+    gl.BindBuffer(indirect.Target, 0)
+    return args
+}
+
+// Deletes the buffer
+func (indirect *IndirectBuffer) Delete() {
+    gl.DeleteBuffers(1, &indirect.ID)
+}
+
+// Attaches buffers that get bound every time the ShaderManager dispatches, along with the data SSBO at binding 0
+func (sm *ShaderManager[T]) Attach(buffers ...BoundBuffer) {
+    sm.attached = append(sm.attached, buffers...)
+}
+
+// Removes every attached buffer, the buffers themselves aren't deleted
+func (sm *ShaderManager[T]) Detach() {
+    sm.attached = nil
+}
+
+// Binds every attached buffer
+func (sm *ShaderManager[T]) bindAttached() {
+    for _, buffer := range sm.attached {
+        buffer.Bind()
+    }
+}
+
+// DispatchIndirect runs the shader with the workgroup counts stored at byte (offset) of (args)
+//
+// Only the attached buffers are bound, the data SSBO is whatever was last bound at binding 0
+func (sm *ShaderManager[T]) DispatchIndirect(args *IndirectBuffer, offset int) {
+    if thread := sm.glThread(); thread != nil {
+        thread.Call(func() {
+            sm.DispatchIndirect(args, offset)
+        })
+        return
+    }
+    sm.Profiler.Begin("DispatchIndirect")
+    sm.bindAttached()
+    gl.UseProgram(sm.ShaderProgram)
+    args.BindCommand()
+    gl.DispatchComputeIndirect(offset)
+    gl.MemoryBarrier(gl.SHADER_STORAGE_BARRIER_BIT | gl.ATOMIC_COUNTER_BARRIER_BIT)
+    gl.BindBuffer(args.Target, 0)
+    sm.Profiler.End()
+}
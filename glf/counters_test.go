@@ -0,0 +1,71 @@
+package glf
+
+import (
+	"slices"
+	"testing"
+)
+
+// Empty buffers have nothing to upload or read, which used to take the address of an empty slice
+func TestEmptyCounterBuffers(t *testing.T) {
+    ctx, err := NewThreadedComputeContext(DefaultContextBackend)
+    if err != nil {
+        t.Skipf("no GL context could be created: %v", err)
+    }
+    defer ctx.Release()
+
+    ctx.Do(func() {
+        counters := NewAtomicCounterBuffer(1, 0)
+        defer counters.Delete()
+        counters.Reset()
+        counters.Reset(5)
+        if got := counters.Read(); len(got) != 0 {
+            t.Errorf("an empty counter buffer read %v", got)
+        }
+
+        draws := NewDrawIndirectBuffer(2, 0, true)
+        defer draws.Delete()
+        draws.Set()
+        draws.Set(1, 2, 3)
+        if got := draws.Read(); draws.Size != 0 || len(got) != 0 {
+            t.Errorf("an indirect buffer of no commands has size %d and read %v", draws.Size, got)
+        }
+    })
+}
+
+func TestCounterBuffers(t *testing.T) {
+    ctx, err := NewThreadedComputeContext(DefaultContextBackend)
+    if err != nil {
+        t.Skipf("no GL context could be created: %v", err)
+    }
+    defer ctx.Release()
+
+    ctx.Do(func() {
+        counters := NewAtomicCounterBuffer(1, 3)
+        defer counters.Delete()
+        if got := counters.Read(); !slices.Equal(got, []uint32{0, 0, 0}) {
+            t.Errorf("new counters are %v", got)
+        }
+        counters.Reset(7, 8)
+        if got := counters.Read(); !slices.Equal(got, []uint32{7, 8, 0}) {
+            t.Errorf("counters reset to 7, 8 are %v", got)
+        }
+
+        dispatch := NewDispatchIndirectBuffer(2)
+        defer dispatch.Delete()
+        if got := dispatch.Read(); !slices.Equal(got, []uint32{1, 1, 1}) {
+            t.Errorf("new dispatch arguments are %v", got)
+        }
+        // Arguments past the end of the buffer are dropped, and the rest are left alone
+        dispatch.Set(4, 5, 6, 7)
+        dispatch.Set(9)
+        if got := dispatch.Read(); !slices.Equal(got, []uint32{9, 5, 6}) {
+            t.Errorf("dispatch arguments are %v, want [9 5 6]", got)
+        }
+
+        draws := NewDrawIndirectBuffer(3, 2, false)
+        defer draws.Delete()
+        if got := draws.Read(); draws.Size != 8 || !slices.Equal(got, make([]uint32, 8)) {
+            t.Errorf("2 array draw commands have size %d and read %v", draws.Size, got)
+        }
+    })
+}
@@ -0,0 +1,266 @@
+// Cubemap and Environment Map GL Helper Functions
+package glf
+
+import (
+	"embed"
+	"errors"
+	"fmt"
+	"image"
+	"math/bits"
+	"strings"
+
+	"github.com/go-gl/gl/v4.6-core/gl"
+)
+
+//go:embed shaders
+var shaderFiles embed.FS
+
+// Step in radians between the hemisphere samples NewIrradianceMap takes for every texel
+var IrradianceSampleDelta float32 = 0.025
+
+// GGX samples NewPrefilteredMap takes for every texel
+var PrefilterSamples = 512
+
+// Creates and binds a cubemap texture ID via a uint32 ID
+func GenBindCubemap() uint32 {
+    var texID uint32
+    gl.GenTextures(1, &texID)
+    BindCubemap(texID)
+    return texID
+}
+
+// Binds a cubemap texture ID via a uint32 ID
+func BindCubemap(texID uint32) {
+    gl.BindTexture(gl.TEXTURE_CUBE_MAP, texID)
+}
+
+// Fills in the cubemap defaults, which clamp to the edge instead of repeating, then the usual ones
+func cubemapDefaults(opts TextureOptions) TextureOptions {
+    if opts.WrapS == 0 {
+        opts.WrapS = gl.CLAMP_TO_EDGE
+    }
+    if opts.WrapT == 0 {
+        opts.WrapT = gl.CLAMP_TO_EDGE
+    }
+    return opts.withDefaults()
+}
+
+// Sets the parameters of the bound cubemap, and turns on seamless filtering across its faces
+func setCubemapParameters(opts TextureOptions) {
+    setTextureParameters(gl.TEXTURE_CUBE_MAP, opts)
+    gl.TexParameteri(gl.TEXTURE_CUBE_MAP, gl.TEXTURE_WRAP_R, opts.WrapS)
+    gl.Enable(gl.TEXTURE_CUBE_MAP_SEAMLESS)
+}
+
+// Creates a cubemap from six square images of the same size, in the GL order +X, -X, +Y, -Y, +Z, -Z
+//
+// Unset wrap modes default to CLAMP_TO_EDGE, the other options work as they do for NewTextureFromImage.
+// Seamless cubemap filtering is turned on for the whole context.
+func NewCubemap(faces []image.Image, opts TextureOptions) (uint32, error) {
+    if len(faces) != 6 {
+        return 0, fmt.Errorf("a cubemap needs 6 faces, got %d", len(faces))
+    }
+    size := faces[0].Bounds().Size()
+    for i, face := range faces {
+        if face.Bounds().Size() != size || size.X != size.Y {
+            return 0, fmt.Errorf("cubemap face %d is %v, every face has to be the same square size", i, face.Bounds().Size())
+        }
+    }
+    opts = cubemapDefaults(opts)
+    if opts.NoMipmaps && isMipmapFilter(opts.MinFilter) {
+        return 0, errors.New("a mipmap min filter needs mipmaps, but NoMipmaps is set")
+    }
+
+    texture := GenBindCubemap()
+    setCubemapParameters(opts)
+    for i, face := range faces {
+        texImage2D(gl.TEXTURE_CUBE_MAP_POSITIVE_X+uint32(i), face, opts)
+    }
+    if !opts.NoMipmaps {
+        gl.GenerateMipmap(gl.TEXTURE_CUBE_MAP)
+    }
+    return texture, nil
+}
+
+// Loads six face images into a cubemap, in the order +X, -X, +Y, -Y, +Z, -Z, see NewCubemap
+func LoadCubemap(facePaths []string, opts TextureOptions) (uint32, error) {
+    faces := make([]image.Image, len(facePaths))
+    for i, facePath := range facePaths {
+        var err error
+        faces[i], _, err = DecodeTextureFile(facePath)
+        if err != nil {
+            return 0, err
+        }
+    }
+    return NewCubemap(faces, opts)
+}
+
+// Loads an equirectangular (latitude-longitude) image into a cubemap with (size) pixel faces, see NewCubemapFromEquirect
+func LoadEquirectCubemap(filePath string, size int, opts TextureOptions) (uint32, error) {
+    img, _, err := DecodeTextureFile(filePath)
+    if err != nil {
+        return 0, err
+    }
+    return NewCubemapFromEquirect(img, size, opts)
+}
+
+// Projects an equirectangular image, usually an HDR panorama, onto a cubemap with (size) pixel faces
+//
+// The projection runs as a compute pass, so the internal format has to be gl.RGBA16F, the default, or
+// gl.RGBA32F. The top row of the image is straight up (+Y), and its horizontal center faces +X.
+func NewCubemapFromEquirect(img image.Image, size int, opts TextureOptions) (uint32, error) {
+    opts = cubemapDefaults(opts)
+    format, err := cubemapImageFormat(opts.InternalFormat)
+    if err != nil {
+        return 0, err
+    }
+    if size <= 0 {
+        return 0, fmt.Errorf("invalid cubemap size %d", size)
+    }
+    program, err := cubemapProgram("equirect.comp", format)
+    if err != nil {
+        return 0, err
+    }
+    defer gl.DeleteProgram(program)
+
+    // Internal format float to keep HDR values, repeating horizontally so the seam filters across
+    source, err := NewTextureFromImage(img, TextureOptions{
+        InternalFormat: gl.RGBA32F,
+        WrapS:          gl.REPEAT,
+        WrapT:          gl.CLAMP_TO_EDGE,
+        NoMipmaps:      true,
+    })
+    if err != nil {
+        return 0, err
+    }
+    defer gl.DeleteTextures(1, &source)
+
+    levels := 1
+    if !opts.NoMipmaps {
+        levels = bits.Len(uint(size))
+    }
+    texture := newCubemapStorage(size, levels, opts)
+
+    gl.ActiveTexture(gl.TEXTURE0)
+    BindTexture(source)
+    runCubemapPass(program, texture, 0, size, opts.InternalFormat)
+    BindTexture(0)
+
+    if !opts.NoMipmaps {
+        BindCubemap(texture)
+        gl.GenerateMipmap(gl.TEXTURE_CUBE_MAP)
+    }
+    return texture, nil
+}
+
+// Creates a diffuse irradiance cubemap with (size) pixel faces from an environment cubemap
+//
+// Each texel holds the cosine weighted light arriving from the hemisphere around its direction, to be
+// sampled by surface normal. 32 pixels is plenty, irradiance has no fine detail.
+func NewIrradianceMap(environment uint32, size int) (uint32, error) {
+    if size <= 0 {
+        return 0, fmt.Errorf("invalid cubemap size %d", size)
+    }
+    program, err := cubemapProgram("irradiance.comp", "rgba16f")
+    if err != nil {
+        return 0, err
+    }
+    defer gl.DeleteProgram(program)
+
+    opts := cubemapDefaults(TextureOptions{NoMipmaps: true})
+    texture := newCubemapStorage(size, 1, opts)
+
+    gl.UseProgram(program)
+    gl.Uniform1f(gl.GetUniformLocation(program, gl.Str("uSampleDelta\x00")), max(IrradianceSampleDelta, 0.001))
+    gl.ActiveTexture(gl.TEXTURE0)
+    BindCubemap(environment)
+    runCubemapPass(program, texture, 0, size, opts.InternalFormat)
+    BindCubemap(0)
+    return texture, nil
+}
+
+// Creates a prefiltered specular cubemap from an environment cubemap for split sum image based lighting
+//
+// Mip level n is the environment blurred by GGX with roughness n / (levels - 1), so level 0 is a sharp
+// copy and the last level is fully rough. The environment should have mipmaps and a mipmap min filter,
+// rough levels sample its lower mips to stay free of noise.
+func NewPrefilteredMap(environment uint32, size, levels int) (uint32, error) {
+    if size <= 0 || levels <= 0 || levels > bits.Len(uint(size)) {
+        return 0, fmt.Errorf("invalid prefiltered cubemap size %d with %d levels", size, levels)
+    }
+    program, err := cubemapProgram("prefilter.comp", "rgba16f")
+    if err != nil {
+        return 0, err
+    }
+    defer gl.DeleteProgram(program)
+
+    opts := cubemapDefaults(TextureOptions{MinFilter: gl.LINEAR_MIPMAP_LINEAR})
+    texture := newCubemapStorage(size, levels, opts)
+
+    var environmentSize int32
+    gl.ActiveTexture(gl.TEXTURE0)
+    BindCubemap(environment)
+    gl.GetTexLevelParameteriv(gl.TEXTURE_CUBE_MAP_POSITIVE_X, 0, gl.TEXTURE_WIDTH, &environmentSize)
+
+    gl.UseProgram(program)
+    gl.Uniform1ui(gl.GetUniformLocation(program, gl.Str("uSamples\x00")), uint32(max(PrefilterSamples, 1)))
+    gl.Uniform1f(gl.GetUniformLocation(program, gl.Str("uEnvironmentSize\x00")), float32(environmentSize))
+    roughnessLocation := gl.GetUniformLocation(program, gl.Str("uRoughness\x00"))
+    for level := 0; level < levels; level++ {
+        roughness := float32(0)
+        if levels > 1 {
+            roughness = float32(level) / float32(levels-1)
+        }
+        gl.UseProgram(program)
+        gl.Uniform1f(roughnessLocation, roughness)
+        runCubemapPass(program, texture, level, max(size>>level, 1), opts.InternalFormat)
+    }
+    BindCubemap(0)
+    return texture, nil
+}
+
+// Returns the GLSL image format for a compute written cubemap's internal format
+func cubemapImageFormat(internalFormat int32) (string, error) {
+    switch internalFormat {
+    case gl.RGBA16F:
+        return "rgba16f", nil
+    case gl.RGBA32F:
+        return "rgba32f", nil
+    }
+    return "", errors.New("cubemaps made by a compute pass have to be gl.RGBA16F or gl.RGBA32F")
+}
+
+// Loads an embedded cubemap kernel, puts the shared functions and its output (format) after its #version line, and compiles it
+func cubemapProgram(name, format string) (uint32, error) {
+    source, err := shaderFiles.ReadFile("shaders/" + name)
+    if err != nil {
+        return 0, err
+    }
+    common, err := shaderFiles.ReadFile("shaders/cubemap.glsl")
+    if err != nil {
+        return 0, err
+    }
+    version, body, _ := strings.Cut(string(source), "\n")
+    defines := "#define IMAGE_FORMAT " + format + "\n"
+    return TryCreateComputeShader(version+"\n"+defines+string(common)+body, name)
+}
+
+// Creates an immutable cubemap with (levels) mip levels and sets its parameters, it's left bound
+func newCubemapStorage(size, levels int, opts TextureOptions) uint32 {
+    texture := GenBindCubemap()
+    gl.TexStorage2D(gl.TEXTURE_CUBE_MAP, int32(levels), uint32(opts.InternalFormat), int32(size), int32(size))
+    gl.TexParameteri(gl.TEXTURE_CUBE_MAP, gl.TEXTURE_MAX_LEVEL, int32(levels-1))
+    setCubemapParameters(opts)
+    BindCubemap(0)
+    return texture
+}
+
+// Runs (program) over every texel of mip (level) of (cubemap), which is (size) pixels across, and waits for the writes
+func runCubemapPass(program, cubemap uint32, level, size int, internalFormat int32) {
+    gl.UseProgram(program)
+    gl.BindImageTexture(0, cubemap, int32(level), true, 0, gl.WRITE_ONLY, uint32(internalFormat))
+    groups := uint32((size + 7) / 8)
+    gl.DispatchCompute(groups, groups, 6)
+    gl.MemoryBarrier(gl.SHADER_IMAGE_ACCESS_BARRIER_BIT | gl.TEXTURE_FETCH_BARRIER_BIT | gl.TEXTURE_UPDATE_BARRIER_BIT)
+    gl.BindImageTexture(0, 0, 0, false, 0, gl.WRITE_ONLY, uint32(internalFormat))
+}
@@ -0,0 +1,200 @@
+//go:build egl
+
+// EGL Headless Context GL Helper Functions
+//
+// Only built with the egl build tag, which also makes go-gl load GL through eglGetProcAddress.
+package glf
+
+/*
+#cgo pkg-config: egl
+#include <EGL/egl.h>
+#include <EGL/eglext.h>
+#include <string.h>
+
+typedef struct {
+    EGLDisplay display;
+    EGLContext context;
+    EGLSurface surface;
+} glfEGL;
+
+// Checks for a whole word match in a space separated EGL extension list
+static int glfHasExtension(const char *list, const char *name) {
+    size_t length = strlen(name);
+    const char *at = list;
+    while (list != NULL && (at = strstr(at, name)) != NULL) {
+        if ((at == list || at[-1] == ' ') && (at[length] == ' ' || at[length] == '\0')) {
+            return 1;
+        }
+        at += length;
+    }
+    return 0;
+}
+
+// Gets an initialized display without a window system, trying Mesa's surfaceless platform and then each EGL device
+static EGLDisplay glfHeadlessDisplay(void) {
+    const char *client = eglQueryString(EGL_NO_DISPLAY, EGL_EXTENSIONS);
+    PFNEGLGETPLATFORMDISPLAYEXTPROC getPlatformDisplay =
+        (PFNEGLGETPLATFORMDISPLAYEXTPROC)eglGetProcAddress("eglGetPlatformDisplayEXT");
+    if (client == NULL || getPlatformDisplay == NULL) {
+        return EGL_NO_DISPLAY;
+    }
+
+    if (glfHasExtension(client, "EGL_MESA_platform_surfaceless")) {
+        EGLDisplay display = getPlatformDisplay(EGL_PLATFORM_SURFACELESS_MESA, EGL_DEFAULT_DISPLAY, NULL);
+        if (display != EGL_NO_DISPLAY && eglInitialize(display, NULL, NULL)) {
+            return display;
+        }
+    }
+
+    if (glfHasExtension(client, "EGL_EXT_platform_device")) {
+        PFNEGLQUERYDEVICESEXTPROC queryDevices = (PFNEGLQUERYDEVICESEXTPROC)eglGetProcAddress("eglQueryDevicesEXT");
+        EGLDeviceEXT devices[16];
+        EGLint count = 0;
+        if (queryDevices != NULL && queryDevices(16, devices, &count)) {
+            for (EGLint i = 0; i < count; i++) {
+                EGLDisplay display = getPlatformDisplay(EGL_PLATFORM_DEVICE_EXT, devices[i], NULL);
+                if (display != EGL_NO_DISPLAY && eglInitialize(display, NULL, NULL)) {
+                    return display;
+                }
+            }
+        }
+    }
+    return EGL_NO_DISPLAY;
+}
+
+// Creates a core profile context of the given version and makes it current, returns an error message or NULL
+//
+// The display is left initialized either way, eglTerminate is up to the caller since other contexts may share it
+static const char *glfCreateEGL(glfEGL *out, int major, int minor) {
+    out->display = glfHeadlessDisplay();
+    out->context = EGL_NO_CONTEXT;
+    out->surface = EGL_NO_SURFACE;
+    if (out->display == EGL_NO_DISPLAY) {
+        return "no surfaceless or device EGL platform is available";
+    }
+    if (!eglBindAPI(EGL_OPENGL_API)) {
+        return "EGL display doesn't support desktop OpenGL";
+    }
+
+    const char *extensions = eglQueryString(out->display, EGL_EXTENSIONS);
+    EGLConfig config = EGL_NO_CONFIG_KHR;
+    if (!glfHasExtension(extensions, "EGL_KHR_no_config_context")) {
+        EGLint configAttribs[] = {
+            EGL_SURFACE_TYPE, EGL_PBUFFER_BIT,
+            EGL_RENDERABLE_TYPE, EGL_OPENGL_BIT,
+            EGL_NONE,
+        };
+        EGLint count = 0;
+        if (!eglChooseConfig(out->display, configAttribs, &config, 1, &count) || count == 0) {
+                return "no EGL config supports OpenGL pbuffers";
+        }
+    }
+
+    EGLint contextAttribs[] = {
+        EGL_CONTEXT_MAJOR_VERSION, major,
+        EGL_CONTEXT_MINOR_VERSION, minor,
+        EGL_CONTEXT_OPENGL_PROFILE_MASK, EGL_CONTEXT_OPENGL_CORE_PROFILE_BIT,
+        EGL_NONE,
+    };
+    out->context = eglCreateContext(out->display, config, EGL_NO_CONTEXT, contextAttribs);
+    if (out->context == EGL_NO_CONTEXT) {
+        return "failed to create the EGL OpenGL context";
+    }
+
+    // Without surfaceless contexts a 1x1 pbuffer has to be current along with the context
+    if (!glfHasExtension(extensions, "EGL_KHR_surfaceless_context")) {
+        EGLint pbufferAttribs[] = {EGL_WIDTH, 1, EGL_HEIGHT, 1, EGL_NONE};
+        out->surface = eglCreatePbufferSurface(out->display, config, pbufferAttribs);
+        if (out->surface == EGL_NO_SURFACE) {
+            eglDestroyContext(out->display, out->context);
+                return "failed to create the EGL pbuffer surface";
+        }
+    }
+
+    if (!eglMakeCurrent(out->display, out->surface, out->surface, out->context)) {
+        if (out->surface != EGL_NO_SURFACE) {
+            eglDestroySurface(out->display, out->surface);
+        }
+        eglDestroyContext(out->display, out->context);
+        return "failed to make the EGL context current";
+    }
+    return NULL;
+}
+
+static int glfMakeCurrentEGL(glfEGL *egl) {
+    return eglMakeCurrent(egl->display, egl->surface, egl->surface, egl->context);
+}
+
+// Destroys the surface and context, the display stays initialized
+static void glfDestroyEGL(glfEGL *egl) {
+    eglMakeCurrent(egl->display, EGL_NO_SURFACE, EGL_NO_SURFACE, EGL_NO_CONTEXT);
+    if (egl->surface != EGL_NO_SURFACE) {
+        eglDestroySurface(egl->display, egl->surface);
+    }
+    eglDestroyContext(egl->display, egl->context);
+}
+*/
+import "C"
+
+import (
+	"fmt"
+	"sync"
+
+	"github.com/go-gl/gl/v4.6-core/gl"
+)
+
+// A headless EGL display and GL context
+type eglContext struct {
+    handles C.glfEGL
+}
+
+// eglGetPlatformDisplay gives every context on a device the same display, and eglTerminate on it breaks all
+// of them, so displays are only terminated once the last context using them is destroyed
+var eglDisplays = struct {
+    sync.Mutex
+    refs map[C.EGLDisplay]int
+}{refs: make(map[C.EGLDisplay]int)}
+
+// Terminates (display) if no context holds a reference to it, eglDisplays has to be locked
+func releaseEGLDisplay(display C.EGLDisplay) {
+    if display != C.EGLDisplay(C.EGL_NO_DISPLAY) && eglDisplays.refs[display] == 0 {
+        delete(eglDisplays.refs, display)
+        C.eglTerminate(display)
+    }
+}
+
+// Creates a GL 4.3 core context on a surfaceless or device EGL display, makes it current, and initializes GL
+func newEGLContext() (*eglContext, error) {
+    ctx := &eglContext{}
+    eglDisplays.Lock()
+    defer eglDisplays.Unlock()
+    if msg := C.glfCreateEGL(&ctx.handles, 4, 3); msg != nil {
+        err := fmt.Errorf("Failed to create EGL context: %s (EGL error 0x%X)", C.GoString(msg), int(C.eglGetError()))
+        releaseEGLDisplay(ctx.handles.display)
+        return nil, err
+    }
+    if err := gl.Init(); err != nil {
+        C.glfDestroyEGL(&ctx.handles)
+        releaseEGLDisplay(ctx.handles.display)
+        return nil, fmt.Errorf("Failed to initialize OpenGL: %v", err)
+    }
+    eglDisplays.refs[ctx.handles.display]++
+    return ctx, nil
+}
+
+// Makes the context current on the calling thread
+func (ctx *eglContext) makeCurrent() error {
+    if C.glfMakeCurrentEGL(&ctx.handles) == C.EGL_FALSE {
+        return fmt.Errorf("Failed to make EGL context current (EGL error 0x%X)", int(C.eglGetError()))
+    }
+    return nil
+}
+
+// Destroys the context, and terminates the display if no other context uses it
+func (ctx *eglContext) destroy() {
+    eglDisplays.Lock()
+    defer eglDisplays.Unlock()
+    C.glfDestroyEGL(&ctx.handles)
+    eglDisplays.refs[ctx.handles.display]--
+    releaseEGLDisplay(ctx.handles.display)
+}
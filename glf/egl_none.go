@@ -0,0 +1,22 @@
+//go:build !egl
+
+// EGL Headless Context stand in for builds without the egl build tag
+package glf
+
+import (
+	"errors"
+)
+
+// Stands in for the EGL context when glf is built without the egl tag
+type eglContext struct{}
+
+// Always fails, EGL needs the egl build tag
+func newEGLContext() (*eglContext, error) {
+    return nil, errors.New("EGL contexts need glf to be built with -tags egl")
+}
+
+func (ctx *eglContext) makeCurrent() error {
+    return errors.New("EGL contexts need glf to be built with -tags egl")
+}
+
+func (ctx *eglContext) destroy() {}
@@ -0,0 +1,51 @@
+//go:build egl
+
+package glf
+
+import (
+	"testing"
+
+	"github.com/go-gl/gl/v4.6-core/gl"
+)
+
+// Both contexts get the same EGL display, destroying one mustn't terminate it under the other
+func TestEGLContextsShareTheDisplay(t *testing.T) {
+    first, err := NewThreadedComputeContext(ContextEGL)
+    if err != nil {
+        t.Skipf("no EGL context could be created: %v", err)
+    }
+    second, err := NewThreadedComputeContext(ContextEGL)
+    if err != nil {
+        first.Release()
+        t.Fatal(err)
+    }
+    defer second.Release()
+    if first.egl.handles.display != second.egl.handles.display {
+        first.Release()
+        t.Skip("the contexts are on different displays")
+    }
+    first.Release()
+    eglDisplays.Lock()
+    refs := eglDisplays.refs[second.egl.handles.display]
+    eglDisplays.Unlock()
+    if refs != 1 {
+        t.Errorf("the display has %d references after destroying one of two contexts, want 1", refs)
+    }
+
+    if err := second.MakeCurrent(); err != nil {
+        t.Fatalf("the second context broke when the first was destroyed: %v", err)
+    }
+    var data uint32 = 7
+    second.Do(func() {
+        var buffer uint32
+        gl.GenBuffers(1, &buffer)
+        gl.BindBuffer(gl.ARRAY_BUFFER, buffer)
+        gl.BufferData(gl.ARRAY_BUFFER, 4, gl.Ptr(&data), gl.STATIC_DRAW)
+        data = 0
+        gl.GetBufferSubData(gl.ARRAY_BUFFER, 0, 4, gl.Ptr(&data))
+        gl.DeleteBuffers(1, &buffer)
+    })
+    if data != 7 {
+        t.Errorf("read back %d from the second context, want 7", data)
+    }
+}
@@ -0,0 +1,337 @@
+// OpenEXR Texture Decoder
+package glf
+
+import (
+	"bytes"
+	"compress/zlib"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"image"
+	"io"
+	"math"
+	"sort"
+)
+
+// OpenEXR compression types, only the ones up to ZIP are decoded
+const (
+    exrNone = 0
+    exrRLE  = 1
+    exrZIPS = 2
+    exrZIP  = 3
+)
+
+// OpenEXR channel pixel types
+const (
+    exrUint  = 0
+    exrHalf  = 1
+    exrFloat = 2
+)
+
+type exrChannel struct {
+    name        string
+    pixelType   int32
+    size        int // Bytes per value
+}
+
+func matchEXR(header []byte) bool {
+    return bytes.HasPrefix(header, []byte{0x76, 0x2f, 0x31, 0x01})
+}
+
+// Decodes a single part scanline OpenEXR file into a *FloatImage
+//
+// Uncompressed, RLE, ZIPS and ZIP files are supported, PIZ, PXR24, B44 and DWA compression, tiled,
+// multi part and deep files aren't. R, G, B and A channels are used, or Y for grayscale, and layered
+// channels like diffuse.R are ignored.
+func decodeEXR(r io.Reader) (image.Image, error) {
+    data, err := io.ReadAll(r)
+    if err != nil {
+        return nil, err
+    }
+    if len(data) < 8 {
+        return nil, errors.New("exr: file is truncated")
+    }
+    flags := binary.LittleEndian.Uint32(data[4:])
+    if flags&0xff != 2 {
+        return nil, fmt.Errorf("exr: unsupported version %d", flags&0xff)
+    }
+    if flags&0x200 != 0 {
+        return nil, errors.New("exr: tiled files aren't supported")
+    }
+    if flags&0x1800 != 0 {
+        return nil, errors.New("exr: deep and multi part files aren't supported")
+    }
+
+    // Header attributes, each a name, a type name, a size and a value
+    var channels []exrChannel
+    compression := -1
+    var window [4]int32
+    hasWindow := false
+    offset := 8
+    readString := func() (string, error) {
+        end := bytes.IndexByte(data[offset:], 0)
+        if end < 0 {
+            return "", errors.New("exr: header is truncated")
+        }
+        s := string(data[offset : offset+end])
+        offset += end + 1
+        return s, nil
+    }
+    for {
+        name, err := readString()
+        if err != nil {
+            return nil, err
+        }
+        if name == "" {
+            break
+        }
+        if _, err := readString(); err != nil {
+            return nil, err
+        }
+        if offset+4 > len(data) {
+            return nil, errors.New("exr: header is truncated")
+        }
+        size := int(binary.LittleEndian.Uint32(data[offset:]))
+        offset += 4
+        if size < 0 || offset+size > len(data) {
+            return nil, errors.New("exr: header is truncated")
+        }
+        value := data[offset : offset+size]
+        offset += size
+
+        switch name {
+        case "channels":
+            channels, err = parseEXRChannels(value)
+            if err != nil {
+                return nil, err
+            }
+        case "compression":
+            if len(value) < 1 {
+                return nil, errors.New("exr: invalid compression attribute")
+            }
+            compression = int(value[0])
+        case "dataWindow":
+            if len(value) < 16 {
+                return nil, errors.New("exr: invalid dataWindow attribute")
+            }
+            for i := range window {
+                window[i] = int32(binary.LittleEndian.Uint32(value[i*4:]))
+            }
+            hasWindow = true
+        }
+    }
+    if channels == nil || !hasWindow || compression < 0 {
+        return nil, errors.New("exr: header is missing channels, compression or dataWindow")
+    }
+
+    linesPerChunk := 1
+    switch compression {
+    case exrNone, exrRLE, exrZIPS:
+    case exrZIP:
+        linesPerChunk = 16
+    default:
+        return nil, fmt.Errorf("exr: compression type %d isn't supported", compression)
+    }
+
+    width := int(window[2]) - int(window[0]) + 1
+    height := int(window[3]) - int(window[1]) + 1
+    if width <= 0 || height <= 0 || width > 1<<16 || height > 1<<16 {
+        return nil, fmt.Errorf("exr: invalid size %dx%d", width, height)
+    }
+    lineSize := 0
+    for _, channel := range channels {
+        lineSize += width * channel.size
+    }
+
+    // Where each channel goes in the RGBA pixel, -1 for ones that aren't used
+    targets := make([]int, len(channels))
+    gray := true
+    for i, channel := range channels {
+        targets[i] = -1
+        switch channel.name {
+        case "R":
+            targets[i], gray = 0, false
+        case "G":
+            targets[i], gray = 1, false
+        case "B":
+            targets[i], gray = 2, false
+        case "A":
+            targets[i] = 3
+        }
+    }
+    hasAlpha := false
+    for i, channel := range channels {
+        if channel.name == "Y" && gray {
+            targets[i] = 4
+        }
+        hasAlpha = hasAlpha || targets[i] == 3
+    }
+
+    img := NewFloatImage(image.Rect(0, 0, width, height))
+    if !hasAlpha {
+        for i := 3; i < len(img.Pix); i += 4 {
+            img.Pix[i] = 1
+        }
+    }
+
+    numChunks := (height + linesPerChunk - 1) / linesPerChunk
+    if offset+numChunks*8 > len(data) {
+        return nil, errors.New("exr: offset table is truncated")
+    }
+    for chunk := 0; chunk < numChunks; chunk++ {
+        at := int(binary.LittleEndian.Uint64(data[offset+chunk*8:]))
+        if at < 0 || at+8 > len(data) {
+            return nil, errors.New("exr: chunk offset is out of range")
+        }
+        firstLine := int(int32(binary.LittleEndian.Uint32(data[at:]))) - int(window[1])
+        size := int(binary.LittleEndian.Uint32(data[at+4:]))
+        if firstLine < 0 || firstLine >= height || size < 0 || at+8+size > len(data) {
+            return nil, errors.New("exr: invalid chunk")
+        }
+        lines := min(linesPerChunk, height-firstLine)
+        block, err := decompressEXR(data[at+8:at+8+size], compression, lines*lineSize)
+        if err != nil {
+            return nil, err
+        }
+
+        // Each line holds every value of the first channel, then every value of the next, and so on
+        for line := 0; line < lines; line++ {
+            values := block[line*lineSize:]
+            out := img.Pix[(firstLine+line)*img.Stride:]
+            for i, channel := range channels {
+                for x := 0; x < width; x++ {
+                    var value float32
+                    switch channel.pixelType {
+                    case exrUint:
+                        value = float32(binary.LittleEndian.Uint32(values[x*4:]))
+                    case exrHalf:
+                        value = halfToFloat32(binary.LittleEndian.Uint16(values[x*2:]))
+                    case exrFloat:
+                        value = math.Float32frombits(binary.LittleEndian.Uint32(values[x*4:]))
+                    }
+                    switch target := targets[i]; target {
+                    case -1:
+                    case 4:
+                        out[x*4], out[x*4+1], out[x*4+2] = value, value, value
+                    default:
+                        out[x*4+target] = value
+                    }
+                }
+                values = values[width*channel.size:]
+            }
+        }
+    }
+    return img, nil
+}
+
+// Parses a chlist attribute, the channels come sorted by name
+func parseEXRChannels(value []byte) ([]exrChannel, error) {
+    var channels []exrChannel
+    for len(value) > 0 && value[0] != 0 {
+        end := bytes.IndexByte(value, 0)
+        if end < 0 || end+17 > len(value) {
+            return nil, errors.New("exr: invalid channels attribute")
+        }
+        channel := exrChannel{name: string(value[:end])}
+        fields := value[end+1:]
+        channel.pixelType = int32(binary.LittleEndian.Uint32(fields))
+        xSampling := binary.LittleEndian.Uint32(fields[8:])
+        ySampling := binary.LittleEndian.Uint32(fields[12:])
+        switch channel.pixelType {
+        case exrUint, exrFloat:
+            channel.size = 4
+        case exrHalf:
+            channel.size = 2
+        default:
+            return nil, fmt.Errorf("exr: channel %s has unknown pixel type %d", channel.name, channel.pixelType)
+        }
+        if xSampling != 1 || ySampling != 1 {
+            return nil, fmt.Errorf("exr: channel %s is subsampled, which isn't supported", channel.name)
+        }
+        channels = append(channels, channel)
+        value = fields[16:]
+    }
+    sort.Slice(channels, func(i, j int) bool {
+        return channels[i].name < channels[j].name
+    })
+    return channels, nil
+}
+
+// Decompresses a chunk to (size) bytes, chunks that didn't get smaller are stored as they are
+func decompressEXR(data []byte, compression, size int) ([]byte, error) {
+    if compression == exrNone || len(data) == size {
+        if len(data) < size {
+            return nil, errors.New("exr: chunk is truncated")
+        }
+        return data, nil
+    }
+
+    var packed []byte
+    switch compression {
+    case exrRLE:
+        packed = make([]byte, 0, size)
+        for i := 0; i < len(data) && len(packed) < size; {
+            count := int(int8(data[i]))
+            i++
+            if count < 0 {
+                end := min(i-count, len(data))
+                packed = append(packed, data[i:end]...)
+                i = end
+            } else if i < len(data) {
+                packed = append(packed, bytes.Repeat(data[i:i+1], count+1)...)
+                i++
+            }
+        }
+    case exrZIPS, exrZIP:
+        reader, err := zlib.NewReader(bytes.NewReader(data))
+        if err != nil {
+            return nil, fmt.Errorf("exr: %w", err)
+        }
+        packed = make([]byte, size)
+        _, err = io.ReadFull(reader, packed)
+        reader.Close()
+        if err != nil {
+            return nil, fmt.Errorf("exr: %w", err)
+        }
+    }
+    if len(packed) < size {
+        return nil, errors.New("exr: chunk is truncated")
+    }
+
+    // Undo the delta predictor, then interleave the two halves the bytes were split into
+    for i := 1; i < size; i++ {
+        packed[i] = packed[i-1] + packed[i] - 128
+    }
+    out := make([]byte, size)
+    half := (size + 1) / 2
+    for i := 0; i < size; i++ {
+        if i%2 == 0 {
+            out[i] = packed[i/2]
+        } else {
+            out[i] = packed[half+i/2]
+        }
+    }
+    return out, nil
+}
+
+// Converts an IEEE 754 half precision float to a float32
+func halfToFloat32(half uint16) float32 {
+    sign := uint32(half>>15) << 31
+    exponent := uint32(half>>10) & 0x1f
+    mantissa := uint32(half) & 0x3ff
+    switch {
+    case exponent == 0 && mantissa == 0:
+        return math.Float32frombits(sign)
+    case exponent == 0:
+        // Subnormal, normalize it for float32
+        for mantissa&0x400 == 0 {
+            mantissa <<= 1
+            exponent--
+        }
+        exponent++
+        mantissa &= 0x3ff
+    case exponent == 0x1f:
+        return math.Float32frombits(sign | 0xff<<23 | mantissa<<13)
+    }
+    return math.Float32frombits(sign | (exponent+127-15)<<23 | mantissa<<13)
+}
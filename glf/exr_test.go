@@ -0,0 +1,241 @@
+package glf
+
+import (
+	"bytes"
+	"compress/zlib"
+	"encoding/binary"
+	"math"
+	"strings"
+	"testing"
+)
+
+// A channel for makeEXR
+type exrTestChannel struct {
+    name        string
+    pixelType   int32
+    sampling    int32
+}
+
+// Builds a single part scanline OpenEXR file, (lines) holds the raw bytes of each line, compressed here
+// as (compression) asks
+func makeEXR(channels []exrTestChannel, window [4]int32, compression byte, lines [][]byte) []byte {
+    data := []byte{0x76, 0x2f, 0x31, 0x01, 2, 0, 0, 0}
+    attribute := func(name, kind string, value []byte) {
+        data = append(data, name+"\x00"+kind+"\x00"...)
+        data = binary.LittleEndian.AppendUint32(data, uint32(len(value)))
+        data = append(data, value...)
+    }
+    var chlist []byte
+    for _, channel := range channels {
+        chlist = append(chlist, channel.name+"\x00"...)
+        chlist = binary.LittleEndian.AppendUint32(chlist, uint32(channel.pixelType))
+        chlist = append(chlist, 0, 0, 0, 0)
+        sampling := max(channel.sampling, 1)
+        chlist = binary.LittleEndian.AppendUint32(chlist, uint32(sampling))
+        chlist = binary.LittleEndian.AppendUint32(chlist, uint32(sampling))
+    }
+    attribute("channels", "chlist", append(chlist, 0))
+    attribute("compression", "compression", []byte{compression})
+    var box []byte
+    for _, value := range window {
+        box = binary.LittleEndian.AppendUint32(box, uint32(value))
+    }
+    attribute("dataWindow", "box2i", box)
+    attribute("displayWindow", "box2i", box)
+    data = append(data, 0)
+
+    linesPerChunk := 1
+    if compression == exrZIP {
+        linesPerChunk = 16
+    }
+    var chunks [][]byte
+    for first := 0; first < len(lines); first += linesPerChunk {
+        block := bytes.Join(lines[first:min(first+linesPerChunk, len(lines))], nil)
+        chunk := binary.LittleEndian.AppendUint32(nil, uint32(window[1]+int32(first)))
+        packed := compressEXR(block, compression)
+        chunk = binary.LittleEndian.AppendUint32(chunk, uint32(len(packed)))
+        chunks = append(chunks, append(chunk, packed...))
+    }
+    at := len(data) + 8*len(chunks)
+    for _, chunk := range chunks {
+        data = binary.LittleEndian.AppendUint64(data, uint64(at))
+        at += len(chunk)
+    }
+    return append(data, bytes.Join(chunks, nil)...)
+}
+
+// The inverse of decompressEXR, splits the bytes into halves, applies the delta predictor, then RLE or zlib
+func compressEXR(block []byte, compression byte) []byte {
+    if compression == exrNone {
+        return block
+    }
+    split := make([]byte, len(block))
+    half := (len(block) + 1) / 2
+    for i, value := range block {
+        if i%2 == 0 {
+            split[i/2] = value
+        } else {
+            split[half+i/2] = value
+        }
+    }
+    packed := make([]byte, len(split))
+    for i := range split {
+        packed[i] = split[i]
+        if i > 0 {
+            packed[i] = split[i] - split[i-1] + 128
+        }
+    }
+    var out bytes.Buffer
+    if compression == exrRLE {
+        // Literal runs only, which always comes out bigger, so the decoder can't take it for stored data
+        for len(packed) > 0 {
+            n := min(len(packed), 127)
+            out.WriteByte(byte(-int8(n)))
+            out.Write(packed[:n])
+            packed = packed[n:]
+        }
+        return out.Bytes()
+    }
+    writer := zlib.NewWriter(&out)
+    writer.Write(packed)
+    writer.Close()
+    return out.Bytes()
+}
+
+// Appends float16 (values) to (line)
+func appendHalves(line []byte, values ...uint16) []byte {
+    for _, value := range values {
+        line = binary.LittleEndian.AppendUint16(line, value)
+    }
+    return line
+}
+
+// Appends float32 (values) to (line)
+func appendFloats(line []byte, values ...float32) []byte {
+    for _, value := range values {
+        line = binary.LittleEndian.AppendUint32(line, math.Float32bits(value))
+    }
+    return line
+}
+
+// Half float bits of 0, 0.25, 0.5, 1 and 2
+const (
+    half0   = 0x0000
+    halfQ   = 0x3400
+    halfH   = 0x3800
+    half1   = 0x3c00
+    half2   = 0x4000
+)
+
+func TestDecodeEXR(t *testing.T) {
+    rgb := []exrTestChannel{{"B", exrHalf, 1}, {"G", exrHalf, 1}, {"R", exrHalf, 1}}
+    // Each line holds every B value, then every G value, then every R value
+    rgbLines := [][]byte{
+        appendHalves(nil, half0, halfQ, half0, halfH, half2, half1),
+        appendHalves(nil, half1, half1, half1, half1, half1, half1),
+    }
+    rgbWant := []float32{2, 0, 0, 1, 1, 0.5, 0.25, 1, 1, 1, 1, 1, 1, 1, 1, 1}
+    tests := []struct {
+        name    string
+        file    []byte
+        width   int
+        want    []float32
+    }{
+        {"uncompressed", makeEXR(rgb, [4]int32{0, 0, 1, 1}, exrNone, rgbLines), 2, rgbWant},
+        {"RLE", makeEXR(rgb, [4]int32{0, 0, 1, 1}, exrRLE, rgbLines), 2, rgbWant},
+        {"ZIPS", makeEXR(rgb, [4]int32{0, 0, 1, 1}, exrZIPS, rgbLines), 2, rgbWant},
+        {"ZIP", makeEXR(rgb, [4]int32{0, 0, 1, 1}, exrZIP, rgbLines), 2, rgbWant},
+        {"data window origin", makeEXR(rgb, [4]int32{-5, 10, -4, 11}, exrZIP, rgbLines), 2, rgbWant},
+        {"float with alpha", makeEXR([]exrTestChannel{{"A", exrFloat, 1}, {"B", exrFloat, 1}, {"G", exrFloat, 1}, {"R", exrFloat, 1}}, [4]int32{0, 0, 0, 0}, exrNone,
+            [][]byte{appendFloats(nil, 0.5, -1, 100, 3.5)}), 1, []float32{3.5, 100, -1, 0.5}},
+        {"gray", makeEXR([]exrTestChannel{{"Y", exrHalf, 1}}, [4]int32{0, 0, 1, 0}, exrNone,
+            [][]byte{appendHalves(nil, halfH, half2)}), 2, []float32{0.5, 0.5, 0.5, 1, 2, 2, 2, 1}},
+        {"uint", makeEXR([]exrTestChannel{{"R", exrUint, 1}}, [4]int32{0, 0, 0, 0}, exrNone,
+            [][]byte{binary.LittleEndian.AppendUint32(nil, 7)}), 1, []float32{7, 0, 0, 1}},
+        // Layered channels are skipped, and Y is only used when there's no color
+        {"layers", makeEXR([]exrTestChannel{{"R", exrHalf, 1}, {"Y", exrHalf, 1}, {"diffuse.R", exrHalf, 1}}, [4]int32{0, 0, 0, 0}, exrNone,
+            [][]byte{appendHalves(nil, half1, halfH, half2)}), 1, []float32{1, 0, 0, 1}},
+    }
+    for _, test := range tests {
+        if !matchEXR(test.file) {
+            t.Errorf("%s: matchEXR is false", test.name)
+        }
+        img, err := decodeEXR(bytes.NewReader(test.file))
+        if err != nil {
+            t.Errorf("%s: %v", test.name, err)
+            continue
+        }
+        expectFloatImage(t, test.name, img, test.width, test.want)
+    }
+}
+
+func TestDecodeEXRErrors(t *testing.T) {
+    red := []exrTestChannel{{"R", exrHalf, 1}}
+    line := [][]byte{appendHalves(nil, half1)}
+    valid := makeEXR(red, [4]int32{0, 0, 0, 0}, exrNone, line)
+    withByte := func(file []byte, at int, value byte) []byte {
+        file = append([]byte(nil), file...)
+        file[at] = value
+        return file
+    }
+    // The offset table is the 8 bytes before the only chunk, which is a y, a size and 2 bytes of data
+    table := len(valid) - 10 - 8
+
+    tests := []struct {
+        name    string
+        file    []byte
+        want    string
+    }{
+        {"file", valid[:6], "file is truncated"},
+        {"version", withByte(valid, 4, 1), "unsupported version 1"},
+        {"tiled", withByte(valid, 5, 0x02), "tiled files"},
+        {"multi part", withByte(valid, 5, 0x10), "multi part"},
+        {"header", valid[:40], "header is truncated"},
+        {"attributes", append(append([]byte(nil), valid[:8]...), 0), "header is missing"},
+        {"compression", makeEXR(red, [4]int32{0, 0, 0, 0}, 4, line), "compression type 4"},
+        {"size", makeEXR(red, [4]int32{0, 0, -1, 0}, exrNone, line), "invalid size 0x1"},
+        {"offset table", valid[:table+4], "offset table is truncated"},
+        {"chunk offset", withByte(valid, table, 0xff), "chunk offset is out of range"},
+        {"chunk line", withByte(valid, table+8, 5), "invalid chunk"},
+        {"chunk size", withByte(valid, table+12, 3), "invalid chunk"},
+        {"chunk data", withByte(withByte(valid, table+12, 1), len(valid)-1, 0)[:len(valid)-1], "chunk is truncated"},
+        {"pixel type", makeEXR([]exrTestChannel{{"R", 3, 1}}, [4]int32{0, 0, 0, 0}, exrNone, line), "unknown pixel type 3"},
+        {"subsampled", makeEXR([]exrTestChannel{{"R", exrHalf, 2}}, [4]int32{0, 0, 0, 0}, exrNone, line), "subsampled"},
+        {"zlib", withByte(makeEXR(red, [4]int32{0, 0, 0, 0}, exrZIPS, [][]byte{appendHalves(nil, half1, half1, half1, half1)}), table+16, 0), "exr: "},
+    }
+    for _, test := range tests {
+        _, err := decodeEXR(bytes.NewReader(test.file))
+        if err == nil || !strings.HasPrefix(err.Error(), "exr: ") || !strings.Contains(err.Error(), test.want) {
+            t.Errorf("%s: got error %v, want one containing %q", test.name, err, test.want)
+        }
+    }
+}
+
+func TestHalfToFloat32(t *testing.T) {
+    tests := []struct {
+        half    uint16
+        want    float32
+    }{
+        {0x0000, 0},
+        {0x3c00, 1},
+        {0xc000, -2},
+        {0x3555, 0.333251953125},
+        {0x7bff, 65504},
+        {0x0001, float32(math.Ldexp(1, -24))},
+        {0x03ff, float32(math.Ldexp(1023, -24))},
+        {0x0400, float32(math.Ldexp(1, -14))},
+        {0x7c00, float32(math.Inf(1))},
+        {0xfc00, float32(math.Inf(-1))},
+    }
+    for _, test := range tests {
+        if got := halfToFloat32(test.half); got != test.want {
+            t.Errorf("halfToFloat32(0x%04X) = %v, want %v", test.half, got, test.want)
+        }
+    }
+    if negativeZero := halfToFloat32(0x8000); negativeZero != 0 || !math.Signbit(float64(negativeZero)) {
+        t.Errorf("halfToFloat32(0x8000) = %v, want -0", negativeZero)
+    }
+    if nan := halfToFloat32(0x7e00); nan == nan {
+        t.Errorf("halfToFloat32(0x7E00) = %v, want NaN", nan)
+    }
+}
@@ -0,0 +1,161 @@
+// Double Precision GL Helper Functions
+package glf
+
+import (
+	"fmt"
+	"regexp"
+
+	"github.com/go-gl/gl/v4.6-core/gl"
+)
+
+// What InitShaderManagerFloatMode does with a float64 shader
+type Float64Mode int
+const (
+    Float64Native    Float64Mode = iota // Run the shader as written, fail if the driver has no double support
+    Float64Fallback                     // Run as written if doubles are supported, otherwise like Float64AsFloat32
+    Float64AsFloat32                    // Rewrite the shader to float and convert the data to float32 and back
+)
+
+func (mode Float64Mode) String() string {
+    switch mode {
+    case Float64Fallback:
+        return "Fallback"
+    case Float64AsFloat32:
+        return "AsFloat32"
+    }
+    return "Native"
+}
+
+// Returns true if the current context can run double precision shaders
+//
+// Doubles are core from GL 4.0, so any 4.x context has them whether it lists ARB_gpu_shader_fp64 or not,
+// older contexts need the extension
+func HasFloat64Support() bool {
+    var major int32
+    gl.GetIntegerv(gl.MAJOR_VERSION, &major)
+    if major >= 4 {
+        return true
+    }
+    return HasExtension("GL_ARB_gpu_shader_fp64")
+}
+
+var storageBlockPattern = regexp.MustCompile(`(?:layout\s*\(([^)]*)\)\s*)?(?:(?:readonly|writeonly|coherent|volatile|restrict)\s+)*\bbuffer\s+\w+\s*\{([^}]*)\}`)
+var bindingPattern = regexp.MustCompile(`\bbinding\s*=\s*(\d+)\b`)
+var doubleTypePattern = regexp.MustCompile(`\b(?:double|dvec[234]|dmat[234](?:x[234])?)\b`)
+
+// Checks the storage block at binding 0 holds doubles, so Execute doesn't hand float64 data to a float shader
+//
+// A block without a binding qualifier gets binding 0, like GL gives it
+func checkFloat64Source(shaderSource, sourceFile string) error {
+    for _, block := range storageBlockPattern.FindAllStringSubmatch(shaderSource, -1) {
+        if binding := bindingPattern.FindStringSubmatch(block[1]); binding != nil && binding[1] != "0" {
+            continue
+        }
+        if !doubleTypePattern.MatchString(block[2]) {
+            return fmt.Errorf("%s: the buffer at binding 0 has no double members, it can't take float64 data", sourceFile)
+        }
+        return nil
+    }
+    return fmt.Errorf("%s: no buffer is declared at binding 0", sourceFile)
+}
+
+var fp64ExtensionPattern = regexp.MustCompile(`(?m)^[ \t]*#extension\s+GL_ARB_gpu_shader_fp64\s*:.*$`)
+var doubleLiteralPattern = regexp.MustCompile(`\b((?:\d+\.\d*|\.\d+|\d+)(?:[eE][+-]?\d+)?)(?:lf|LF)\b`)
+var dvecPattern = regexp.MustCompile(`\bdvec([234])\b`)
+var dmatPattern = regexp.MustCompile(`\bdmat([234](?:x[234])?)\b`)
+var doublePattern = regexp.MustCompile(`\bdouble\b`)
+
+// Rewrites a double precision shader to single precision
+//
+// Only the types, lf literals and the fp64 extension line are changed, double only builtins like
+// packDouble2x32 won't compile afterwards
+func float64ToFloat32Source(shaderSource string) string {
+    shaderSource = fp64ExtensionPattern.ReplaceAllString(shaderSource, "")
+    shaderSource = doubleLiteralPattern.ReplaceAllString(shaderSource, "$1")
+    shaderSource = dvecPattern.ReplaceAllString(shaderSource, "vec$1")
+    shaderSource = dmatPattern.ReplaceAllString(shaderSource, "mat$1")
+    return doublePattern.ReplaceAllString(shaderSource, "float")
+}
+
+// InitShaderManagerFloatMode compiles a float64 shader on the shared ComputeContext, initializing SDL and OpenGL if needed
+//
+// The shader's buffer at binding 0 has to hold doubles. Without double support Float64Native returns an
+// error, and the other modes run a float copy of the shader with the data converted to float32 and back.
+func InitShaderManagerFloatMode(shaderSource, sourceFile string, mode Float64Mode) (*ShaderManager[float64], error) {
+    if err := checkFloat64Source(shaderSource, sourceFile); err != nil {
+        return nil, err
+    }
+    ctx, err := AcquireComputeContext()
+    if err != nil {
+        if sm := cpuFallback[float64](shaderSource, sourceFile, err); sm != nil {
+            return sm, nil
+        }
+        return nil, err
+    }
+    defer ctx.Release()
+
+    return NewShaderManagerFloat(ctx, shaderSource, sourceFile, mode)
+}
+
+// Compiles a float64 shader on (ctx) according to (mode), see InitShaderManagerFloatMode
+func NewShaderManagerFloat(ctx *ComputeContext, shaderSource, sourceFile string, mode Float64Mode) (*ShaderManager[float64], error) {
+    if err := checkFloat64Source(shaderSource, sourceFile); err != nil {
+        return nil, err
+    }
+    return newShaderManagerFloat(ctx, shaderSource, sourceFile, mode)
+}
+
+// NewShaderManagerFloat without checking the source
+func newShaderManagerFloat(ctx *ComputeContext, shaderSource, sourceFile string, mode Float64Mode) (*ShaderManager[float64], error) {
+    supported := false
+    ctx.Do(func() {
+        supported = HasFloat64Support()
+    })
+
+    if mode == Float64AsFloat32 || (mode == Float64Fallback && !supported) {
+        if Verbose && mode == Float64Fallback {
+            fmt.Printf("%s: no double precision support, running it as float\n", sourceFile)
+        }
+        sm, err := TryNewShaderManager[float64](ctx, float64ToFloat32Source(shaderSource), sourceFile)
+        if err != nil {
+            return nil, err
+        }
+        sm.convert32 = true
+        return sm, nil
+    }
+    if !supported {
+        return nil, fmt.Errorf("%s: the driver doesn't support double precision shaders (GL 4.0 or GL_ARB_gpu_shader_fp64)", sourceFile)
+    }
+    return TryNewShaderManager[float64](ctx, shaderSource, sourceFile)
+}
+
+// Converts (data) to float32, runs (execute) with a float32 view of the ShaderManager, and converts the results back
+//
+// T is always float64 here, convert32 is only set by NewShaderManagerFloat
+func (sm *ShaderManager[T]) executeAsFloat32(data []T, execute func(inner *ShaderManager[float32], data32 []float32)) []T {
+    doubles := any(data).([]float64)
+    if len(doubles) == 0 {
+        return data
+    }
+    singles := make([]float32, len(doubles))
+    for i, value := range doubles {
+        singles[i] = float32(value)
+    }
+
+    inner := &ShaderManager[float32]{
+        Window:         sm.Window,
+        GLContext:      sm.GLContext,
+        ShaderProgram:  sm.ShaderProgram,
+        Context:        sm.Context,
+        Profiler:       sm.Profiler,
+        source:         sm.source,
+        attached:       sm.attached,
+    }
+    execute(inner, singles)
+
+    for i, value := range singles {
+        doubles[i] = float64(value)
+    }
+    return data
+}
+
@@ -0,0 +1,28 @@
+package glf
+
+import "testing"
+
+func TestCheckFloat64Source(t *testing.T) {
+    tests := []struct {
+        name    string
+        source  string
+        ok      bool
+    }{
+        {"explicit binding", "layout(std430, binding = 0) buffer B { double d[]; };", true},
+        {"implicit binding", "layout(std430) buffer B { double d[]; };", true},
+        {"no layout", "buffer B { dvec4 d[]; };", true},
+        {"qualifiers", "layout(std430) restrict readonly buffer B { dmat4 d[]; };", true},
+        {"other binding first", "layout(binding = 1) buffer A { float f[]; };\nlayout(std430) buffer B { double d[]; };", true},
+        {"floats", "layout(std430) buffer B { float d[]; };", false},
+        {"floats at binding 0", "layout(std430, binding=0) buffer B { vec4 d[]; };", false},
+        {"only other bindings", "layout(std430, binding = 1) buffer B { double d[]; };", false},
+        {"binding 10", "layout(std430, binding = 10) buffer B { double d[]; };", false},
+        {"no buffer", "void main() {}", false},
+    }
+    for _, test := range tests {
+        err := checkFloat64Source(test.source, "test.comp")
+        if (err == nil) != test.ok {
+            t.Errorf("%s: checkFloat64Source gave %v, want ok %v", test.name, err, test.ok)
+        }
+    }
+}
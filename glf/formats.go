@@ -0,0 +1,157 @@
+// Texture Format Registry GL Helper Functions
+package glf
+
+import (
+	"bufio"
+	"fmt"
+	"image"
+	"image/color"
+	"io"
+	"os"
+	"sync"
+
+	_ "image/gif"
+	_ "image/jpeg"
+	_ "image/png"
+)
+
+// TextureFormat is a decoder LoadTexture can use, picked by the content of the file rather than its extension
+type TextureFormat struct {
+    Name    string
+    Match   func(header []byte) bool                // Gets the first 512 bytes, or the whole file if it's shorter
+    Decode  func(r io.Reader) (image.Image, error)  // Returns a *FloatImage for HDR data to keep it as floats
+}
+
+var textureFormats []TextureFormat
+var textureFormatsMutex sync.RWMutex
+
+func init() {
+    // The TGA header has no magic number, so it goes first and is checked last
+    RegisterTextureFormat(TextureFormat{"tga", matchTGA, decodeTGA})
+    RegisterTextureFormat(TextureFormat{"bmp", matchBMP, decodeBMP})
+    RegisterTextureFormat(TextureFormat{"hdr", matchHDR, decodeHDR})
+    RegisterTextureFormat(TextureFormat{"exr", matchEXR, decodeEXR})
+}
+
+// Adds a texture format decoder
+//
+// Formats registered later are checked first, so a decoder can replace a built in one. Anything registered
+// with image.RegisterFormat also works, it's tried when none of these match. PNG, JPEG and GIF come from
+// the standard library, BMP, TGA, Radiance HDR and OpenEXR are built in.
+func RegisterTextureFormat(format TextureFormat) {
+    textureFormatsMutex.Lock()
+    defer textureFormatsMutex.Unlock()
+    textureFormats = append(textureFormats, format)
+}
+
+// Decodes an image in any registered texture format, returning the image and the format name
+func DecodeTexture(r io.Reader) (image.Image, string, error) {
+    reader := bufio.NewReaderSize(r, 4096)
+    header, err := reader.Peek(512)
+    if err != nil && err != io.EOF {
+        return nil, "", err
+    }
+
+    textureFormatsMutex.RLock()
+    formats := textureFormats
+    textureFormatsMutex.RUnlock()
+
+    for i := len(formats) - 1; i >= 0; i-- {
+        if formats[i].Match(header) {
+            img, err := formats[i].Decode(reader)
+            return img, formats[i].Name, err
+        }
+    }
+    img, name, err := image.Decode(reader)
+    if err == image.ErrFormat {
+        return nil, "", fmt.Errorf("unknown texture format")
+    }
+    return img, name, err
+}
+
+// Opens and decodes a texture file, see DecodeTexture
+func DecodeTextureFile(filePath string) (image.Image, string, error) {
+    file, err := os.Open(filePath)
+    if err != nil {
+        return nil, "", err
+    }
+    defer file.Close()
+
+    img, name, err := DecodeTexture(file)
+    if err != nil {
+        return nil, name, fmt.Errorf("%s: %w", filePath, err)
+    }
+    return img, name, nil
+}
+
+// FloatImage is an image of float32 RGBA pixels with straight alpha, what HDR formats decode to
+//
+// Values aren't clamped, so they can go past 1 and below 0.
+type FloatImage struct {
+    Pix     []float32   // R, G, B, A for each pixel, rows from the top
+    Stride  int         // Floats between vertically adjacent pixels
+    Rect    image.Rectangle
+}
+
+// FloatColor is one pixel of a FloatImage
+type FloatColor struct {
+    R, G, B, A float32
+}
+
+// Returns the color clamped to 0-1 and premultiplied, as image/color expects
+func (c FloatColor) RGBA() (r, g, b, a uint32) {
+    clamp := func(v float32) float32 {
+        return min(max(v, 0), 1)
+    }
+    alpha := clamp(c.A)
+    return uint32(clamp(c.R)*alpha*0xffff + 0.5), uint32(clamp(c.G)*alpha*0xffff + 0.5),
+        uint32(clamp(c.B)*alpha*0xffff + 0.5), uint32(alpha*0xffff + 0.5)
+}
+
+// Color model that converts any color to a FloatColor
+var FloatColorModel = color.ModelFunc(func(c color.Color) color.Color {
+    if c, ok := c.(FloatColor); ok {
+        return c
+    }
+    r, g, b, a := c.RGBA()
+    if a == 0 {
+        return FloatColor{}
+    }
+    return FloatColor{float32(r) / float32(a), float32(g) / float32(a), float32(b) / float32(a), float32(a) / 0xffff}
+})
+
+// Creates a FloatImage with every pixel 0
+func NewFloatImage(r image.Rectangle) *FloatImage {
+    return &FloatImage{Pix: make([]float32, 4*r.Dx()*r.Dy()), Stride: 4 * r.Dx(), Rect: r}
+}
+
+func (img *FloatImage) ColorModel() color.Model {
+    return FloatColorModel
+}
+
+func (img *FloatImage) Bounds() image.Rectangle {
+    return img.Rect
+}
+
+func (img *FloatImage) At(x, y int) color.Color {
+    if !(image.Point{x, y}.In(img.Rect)) {
+        return FloatColor{}
+    }
+    i := img.PixOffset(x, y)
+    return FloatColor{img.Pix[i], img.Pix[i+1], img.Pix[i+2], img.Pix[i+3]}
+}
+
+// Returns the index of the first float of the pixel at (x, y) in Pix
+func (img *FloatImage) PixOffset(x, y int) int {
+    return (y-img.Rect.Min.Y)*img.Stride + (x-img.Rect.Min.X)*4
+}
+
+// Sets the pixel at (x, y), ignoring points outside the image
+func (img *FloatImage) Set(x, y int, c color.Color) {
+    if !(image.Point{x, y}.In(img.Rect)) {
+        return
+    }
+    i := img.PixOffset(x, y)
+    float := FloatColorModel.Convert(c).(FloatColor)
+    img.Pix[i], img.Pix[i+1], img.Pix[i+2], img.Pix[i+3] = float.R, float.G, float.B, float.A
+}
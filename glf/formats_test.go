@@ -0,0 +1,96 @@
+package glf
+
+import (
+	"bytes"
+	"errors"
+	"image"
+	"image/color"
+	"image/png"
+	"io"
+	"testing"
+)
+
+func TestDecodeTexture(t *testing.T) {
+    var pngFile bytes.Buffer
+    png.Encode(&pngFile, image.NewNRGBA(image.Rect(0, 0, 1, 1)))
+    tests := []struct {
+        name    string
+        file    []byte
+        want    string
+    }{
+        {"bmp", makeBMP(bmpHeader{width: 1, height: 1, bitCount: 24}, []byte{0, 0, 255, 0}), "bmp"},
+        {"tga", makeTGA(tgaHeader{imageType: tgaTrueColor, width: 1, height: 1, depth: 24}, []byte{0, 0, 255}), "tga"},
+        {"hdr", makeHDR("", "-Y 1 +X 1", []byte{128, 64, 32, 129}), "hdr"},
+        {"exr", makeEXR([]exrTestChannel{{"R", exrHalf, 1}}, [4]int32{0, 0, 0, 0}, exrNone, [][]byte{appendHalves(nil, half1)}), "exr"},
+        {"png from the image package", pngFile.Bytes(), "png"},
+    }
+    for _, test := range tests {
+        img, name, err := DecodeTexture(bytes.NewReader(test.file))
+        if err != nil || name != test.want {
+            t.Errorf("%s: DecodeTexture gave format %q and error %v, want %q", test.name, name, err, test.want)
+            continue
+        }
+        if img.Bounds() != image.Rect(0, 0, 1, 1) {
+            t.Errorf("%s: decoded to %v, want 1x1", test.name, img.Bounds())
+        }
+    }
+
+    if _, _, err := DecodeTexture(bytes.NewReader([]byte("not an image at all"))); err == nil {
+        t.Error("DecodeTexture took text for an image")
+    }
+    if _, _, err := DecodeTexture(bytes.NewReader(nil)); err == nil {
+        t.Error("DecodeTexture decoded an empty file")
+    }
+}
+
+// Formats registered later are checked first, so one can take over a built in format
+func TestRegisterTextureFormat(t *testing.T) {
+    textureFormatsMutex.Lock()
+    saved := textureFormats
+    textureFormatsMutex.Unlock()
+    defer func() {
+        textureFormatsMutex.Lock()
+        textureFormats = saved
+        textureFormatsMutex.Unlock()
+    }()
+
+    replaced := errors.New("decoded by the replacement")
+    RegisterTextureFormat(TextureFormat{
+        Name:  "bmp2",
+        Match: func(header []byte) bool { return matchBMP(header) },
+        Decode: func(r io.Reader) (image.Image, error) {
+            return nil, replaced
+        },
+    })
+    RegisterTextureFormat(TextureFormat{
+        Name:  "solid",
+        Match: func(header []byte) bool { return bytes.HasPrefix(header, []byte("SOLID")) },
+        Decode: func(r io.Reader) (image.Image, error) {
+            img := image.NewNRGBA(image.Rect(0, 0, 1, 1))
+            img.SetNRGBA(0, 0, color.NRGBA{1, 2, 3, 4})
+            return img, nil
+        },
+    })
+
+    if _, name, err := DecodeTexture(bytes.NewReader(makeBMP(bmpHeader{width: 1, height: 1, bitCount: 24}, make([]byte, 4)))); name != "bmp2" || err != replaced {
+        t.Errorf("a BMP went to format %q with error %v, want the replacement", name, err)
+    }
+    img, name, err := DecodeTexture(bytes.NewReader([]byte("SOLID")))
+    if err != nil || name != "solid" || img.At(0, 0) != (color.NRGBA{1, 2, 3, 4}) {
+        t.Errorf("the new format gave %q, %v", name, err)
+    }
+    // The Match function sees the whole file when it's under 512 bytes, and exactly 512 bytes otherwise
+    var seen int
+    RegisterTextureFormat(TextureFormat{
+        Name:  "size",
+        Match: func(header []byte) bool { seen = len(header); return false },
+    })
+    DecodeTexture(bytes.NewReader(make([]byte, 100)))
+    if seen != 100 {
+        t.Errorf("Match got %d bytes of a 100 byte file", seen)
+    }
+    DecodeTexture(bytes.NewReader(make([]byte, 2000)))
+    if seen != 512 {
+        t.Errorf("Match got %d bytes of a 2000 byte file, want 512", seen)
+    }
+}
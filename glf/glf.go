@@ -4,10 +4,9 @@ package glf
 import (
 	"errors"
 	"fmt"
-	"image/png"
 	"log"
 	"math"
-	"os"
+	"reflect"
 	"strings"
 	"unsafe"
 
@@ -20,11 +19,34 @@ import (
 
 var Verbose bool = ghf.Verbose
 
+// Element types a ShaderManager can run a compute shader on
+type ShaderData interface {
+    int32 | float32 | float64 | uint32 | mgl32.Vec4
+}
+
 // ShaderManager holds reusable resources for compute shader execution
-type ShaderManager[T int32 | float64 | uint32 | mgl32.Vec4] struct {
+type ShaderManager[T ShaderData] struct {
 	Window         *sdl.Window
 	GLContext      sdl.GLContext
 	ShaderProgram  uint32
+	Backend        ComputeBackend[T] // Runs Execute instead of GL when set, e.g. a CPUBackend
+	Context        *ComputeContext   // Shared context the program lives on, released by Cleanup
+	Profiler       *Profiler         // Times the upload, dispatch and readback of Execute when set
+	source         string
+	attached       []BoundBuffer
+	convert32      bool // Data is float64 but the shader was rewritten to use float, see Float64AsFloat32
+}
+
+// Returns true if the current context lists the named extension, e.g. "GL_ARB_gpu_shader_fp64"
+func HasExtension(name string) bool {
+    var count int32
+    gl.GetIntegerv(gl.NUM_EXTENSIONS, &count)
+    for i := uint32(0); i < uint32(count); i++ {
+        if gl.GoStr(gl.GetStringi(gl.EXTENSIONS, i)) == name {
+            return true
+        }
+    }
+    return false
 }
 
 // Prints OpenGL version information
@@ -36,48 +58,16 @@ func PrintVersionGL() {
 }
 
 // Load a RGBA texture file via path, and returns a uint32 as texture ID
+//
+// The format is worked out from the file's content, see RegisterTextureFormat for the supported ones.
+// Images are loaded as RGBA16F, so HDR files keep their float values, with REPEAT wrapping, LINEAR
+// filtering and mipmaps. BC compressed DDS and KTX2 files keep their own format and the mip levels they
+// hold. See LoadTextureWithOptions for anything else.
 func LoadTexture(filePath string) uint32 {
-	infile, err := os.Open(filePath)
-	if err != nil {
-		panic(err)
-	}
-	defer infile.Close()
-
-	img, err := png.Decode(infile)
+	texture, err := LoadTextureWithOptions(filePath, TextureOptions{})
 	if err != nil {
 		panic(err)
 	}
-
-	w := img.Bounds().Max.X
-	h := img.Bounds().Max.Y
-
-	pixels := make([]float32, w*h*4)
-	bIndex := 0
-	for y := 0; y < h; y++ {
-		for x := 0; x < w; x++ {
-			r, g, b, a := img.At(x, y).RGBA()
-			pixels[bIndex] = float32(r) / 65535.0
-			bIndex++
-			pixels[bIndex] = float32(g) / 65535.0
-			bIndex++
-			pixels[bIndex] = float32(b) / 65535.0
-			bIndex++
-			pixels[bIndex] = float32(a) / 65535.0
-			bIndex++
-		}
-	}
-
-    texture := GenBindTexture()
-
-    gl.TexParameteri(gl.TEXTURE_2D, gl.TEXTURE_WRAP_S, gl.REPEAT)
-    gl.TexParameteri(gl.TEXTURE_2D, gl.TEXTURE_WRAP_T, gl.REPEAT)
-    gl.TexParameteri(gl.TEXTURE_2D, gl.TEXTURE_MIN_FILTER, gl.LINEAR)
-    gl.TexParameteri(gl.TEXTURE_2D, gl.TEXTURE_MAG_FILTER, gl.LINEAR)
-    
-    gl.TexImage2D(gl.TEXTURE_2D, 0, gl.RGBA16F, int32(w), int32(h), 0, gl.RGBA, gl.FLOAT, gl.Ptr(pixels))
-
-    gl.GenerateMipmap(gl.TEXTURE_2D)
-
 	return texture
 }
 
@@ -171,17 +161,60 @@ func CreateShader(ShaderSource string, ShaderType uint32) (uint32, error) {
     return ShaderID, nil 
 }
 
-// Create and initialize buffer via generic slice (only takes float32 for now)
-func BufferData[T float32](target uint32, data []T, usage uint32) {
-    // switch any(data).(type) {
-    // case []float32:
-    //     byteSize = 4
-    // case []float64:
-    //     byteSize = 8
-    // default:
-    //     panic("unsupported type for BufferData")
-    // }
-    gl.BufferData(target, len(data)*4, gl.Ptr(data), usage)
+// Create and initialize the buffer bound to (target) via generic slice
+//
+// Any fixed size element type works, e.g. float32 vertices, uint16 or uint32 indices, integer attributes
+// or a vertex struct, the size is len(data) times unsafe.Sizeof an element. Structs go up with Go's
+// layout, padding included, so the attribute offsets come from unsafe.Offsetof. An empty slice makes a
+// zero size store. Panics for element types holding pointers, slices, strings, maps or interfaces, which
+// is checked at run time since a type constraint can't describe arrays and structs of numbers.
+func BufferData[T any](target uint32, data []T, usage uint32) {
+    size := bufferElementSize[T]()
+    if len(data) == 0 {
+        gl.BufferData(target, 0, nil, usage)
+        return
+    }
+    gl.BufferData(target, len(data)*size, unsafe.Pointer(&data[0]), usage)
+}
+
+// Uploads (data) into the buffer bound to (target), starting (offset) elements of T from its start
+//
+// The buffer has to be big enough already, see BufferData. An empty slice uploads nothing.
+func BufferSubData[T any](target uint32, offset int, data []T) {
+    size := bufferElementSize[T]()
+    if len(data) == 0 {
+        return
+    }
+    gl.BufferSubData(target, offset*size, len(data)*size, unsafe.Pointer(&data[0]))
+}
+
+// Returns the size in bytes of a T, panicking if it isn't plain data GL can use
+func bufferElementSize[T any]() int {
+    var element T
+    if !isPlainData(reflect.TypeOf(&element).Elem()) {
+        panic(fmt.Sprintf("BufferData: unsupported type %T, elements can't hold pointers", element))
+    }
+    return int(unsafe.Sizeof(element))
+}
+
+// Returns true for types made only of numbers and bools, in arrays and structs
+func isPlainData(t reflect.Type) bool {
+    switch t.Kind() {
+    case reflect.Bool, reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+        reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64,
+        reflect.Float32, reflect.Float64:
+        return true
+    case reflect.Array:
+        return isPlainData(t.Elem())
+    case reflect.Struct:
+        for i := 0; i < t.NumField(); i++ {
+            if !isPlainData(t.Field(i).Type) {
+                return false
+            }
+        }
+        return true
+    }
+    return false
 }
 
 // Generate and bind buffers, and return the ID as a uint32
@@ -256,13 +289,25 @@ func SetUBO[T mgl64.Mat4 | mgl64.Vec3](data []T, UBOn uint32) {
     }
 }
 
+// Compiles and links a compute shader program, exits if either fails
+//
+// Use TryCreateComputeShader to get the error instead
 func CreateComputeShader(source, sourceFile string) uint32 {
-	var program uint32
+    program, err := TryCreateComputeShader(source, sourceFile)
+    if err != nil {
+        log.Fatal(err)
+    }
+    return program
+}
+
+// Compiles and links a compute shader program, returning the compile or link log as an error
+func TryCreateComputeShader(source, sourceFile string) (uint32, error) {
     // Compile the shader
     shader := gl.CreateShader(gl.COMPUTE_SHADER)
     if shader == 0 {
-        log.Fatalf("Failed to create shader")
+        return 0, fmt.Errorf("Failed to create shader")
     }
+    defer gl.DeleteShader(shader)
 
     sourceCString, free := gl.Strs(source + "\x00")
     defer free()
@@ -276,14 +321,14 @@ func CreateComputeShader(source, sourceFile string) uint32 {
         var logLength int32
         gl.GetShaderiv(shader, gl.INFO_LOG_LENGTH, &logLength)
 
-        logMsg := make([]byte, logLength)
+        logMsg := make([]byte, logLength+1)
         gl.GetShaderInfoLog(shader, logLength, nil, &logMsg[0])
-        log.Fatalf("Shader compilation failed: %s", string(logMsg))
+        return 0, fmt.Errorf("Shader compilation failed (%s): %s", sourceFile, gl.GoStr(&logMsg[0]))
     }
 
-    program = gl.CreateProgram()
+    program := gl.CreateProgram()
     if program == 0 {
-        log.Fatalf("Failed to create program")
+        return 0, fmt.Errorf("Failed to create program")
     }
     gl.AttachShader(program, shader)
     gl.LinkProgram(program)
@@ -295,131 +340,190 @@ func CreateComputeShader(source, sourceFile string) uint32 {
         var logLength int32
         gl.GetProgramiv(program, gl.INFO_LOG_LENGTH, &logLength)
 
-        logMsg := make([]byte, logLength)
+        logMsg := make([]byte, logLength+1)
         gl.GetProgramInfoLog(program, logLength, nil, &logMsg[0])
-        log.Fatalf("Program linking failed: %s", string(logMsg))
+        gl.DeleteProgram(program)
+        return 0, fmt.Errorf("Program linking failed (%s): %s", sourceFile, gl.GoStr(&logMsg[0]))
     }
 
-    gl.DeleteShader(shader)
-
-    return program
+    return program, nil
 }
 
+// Creates a hidden 1x1 SDL window with a GL 4.3 core context made current and GL initialized
+//
+// Exits if anything fails, use TryInitSdlNoWindow to get the error instead
 func InitSdlNoWindow() (*sdl.Window, sdl.GLContext) {
+    window, glContext, err := TryInitSdlNoWindow()
+    if err != nil {
+        log.Fatal(err)
+    }
+    return window, glContext
+}
+
+// Same as InitSdlNoWindow, but returns an error and leaves SDL shut down if the context can't be made
+func TryInitSdlNoWindow() (*sdl.Window, sdl.GLContext, error) {
 	// Initialize SDL2
 	if err := sdl.Init(sdl.INIT_VIDEO); err != nil {
-		log.Fatalf("Failed to initialize SDL: %v", err)
+		return nil, 0, fmt.Errorf("Failed to initialize SDL: %v", err)
 	}
 	if err := sdl.GLSetAttribute(sdl.GL_CONTEXT_MAJOR_VERSION, 4); err != nil {
-		log.Fatalf("Failed to set OpenGL major version: %v", err)
+		sdl.Quit()
+		return nil, 0, fmt.Errorf("Failed to set OpenGL major version: %v", err)
 	}
 	if err := sdl.GLSetAttribute(sdl.GL_CONTEXT_MINOR_VERSION, 3); err != nil {
-		log.Fatalf("Failed to set OpenGL minor version: %v", err)
+		sdl.Quit()
+		return nil, 0, fmt.Errorf("Failed to set OpenGL minor version: %v", err)
 	}
 	if err := sdl.GLSetAttribute(sdl.GL_CONTEXT_PROFILE_MASK, sdl.GL_CONTEXT_PROFILE_CORE); err != nil {
-		log.Fatalf("Failed to set OpenGL core profile: %v", err)
+		sdl.Quit()
+		return nil, 0, fmt.Errorf("Failed to set OpenGL core profile: %v", err)
 	}
 
 	window, err := sdl.CreateWindow("Compute Shader", 0, 0, 1, 1, sdl.WINDOW_OPENGL|sdl.WINDOW_HIDDEN)
 	if err != nil {
-		log.Fatalf("Failed to create SDL window: %v", err)
+		sdl.Quit()
+		return nil, 0, fmt.Errorf("Failed to create SDL window: %v", err)
 	}
 
 	glContext, err := window.GLCreateContext()
 	if err != nil {
-		log.Fatalf("Failed to create OpenGL context: %v", err)
+		window.Destroy()
+		sdl.Quit()
+		return nil, 0, fmt.Errorf("Failed to create OpenGL context: %v", err)
 	}
 
 	if err := gl.Init(); err != nil {
-		log.Fatalf("Failed to initialize OpenGL: %v", err)
+		sdl.GLDeleteContext(glContext)
+		window.Destroy()
+		sdl.Quit()
+		return nil, 0, fmt.Errorf("Failed to initialize OpenGL: %v", err)
 	}
 
-    return window, glContext
+    return window, glContext, nil
 }
 
-// InitShaderManager initializes SDL, OpenGL, and compiles the shader
-func InitShaderManager(shaderSource, sourceFile string) *ShaderManager[int32] {
-	window, GLContext := InitSdlNoWindow()
-	if err := gl.Init(); err != nil {
-		log.Fatalln("Failed to initialize OpenGL:", err)
-	}
-
-	shaderProgram := CreateComputeShader(shaderSource, sourceFile)
+// Compiles the shader on the shared ComputeContext for the InitShaderManager functions
+//
+// If no GL context can be made, and a kernel was registered for (sourceFile) with RegisterKernel, the
+// ShaderManager runs that kernel on the CPU instead
+func initShaderManager[T ShaderData](shaderSource, sourceFile string) *ShaderManager[T] {
+    ctx, err := AcquireComputeContext()
+    if err != nil {
+        if sm := cpuFallback[T](shaderSource, sourceFile, err); sm != nil {
+            return sm
+        }
+        log.Fatal(err)
+    }
+    defer ctx.Release()
 
-	return &ShaderManager[int32]{
-		Window:        window,
-		GLContext:     GLContext,
-		ShaderProgram: shaderProgram,
-	}
+    return NewShaderManager[T](ctx, shaderSource, sourceFile)
 }
 
-// InitShaderManager initializes SDL, OpenGL, and compiles the shader
-func InitShaderManagerFloat(shaderSource, sourceFile string) *ShaderManager[float64] {
-	window, GLContext := InitSdlNoWindow()
-	if err := gl.Init(); err != nil {
-		log.Fatalln("Failed to initialize OpenGL:", err)
-	}
-
-	shaderProgram := CreateComputeShader(shaderSource, sourceFile)
+// Returns a CPU ShaderManager for the kernel registered for (sourceFile), or nil if there isn't one
+func cpuFallback[T ShaderData](shaderSource, sourceFile string, err error) *ShaderManager[T] {
+    kernel, ok := registeredKernels[sourceFile].(Kernel[T])
+    if !ok {
+        return nil
+    }
+    if Verbose {
+        fmt.Printf("%s, running %s on the CPU\n", err, sourceFile)
+    }
+    return NewCPUShaderManager(shaderSource, kernel)
+}
 
-	return &ShaderManager[float64]{
-		Window:        window,
-		GLContext:     GLContext,
-		ShaderProgram: shaderProgram,
-	}
+// InitShaderManager compiles the shader on the shared ComputeContext, initializing SDL and OpenGL if needed
+func InitShaderManager(shaderSource, sourceFile string) *ShaderManager[int32] {
+    return initShaderManager[int32](shaderSource, sourceFile)
 }
 
-// InitShaderManager initializes SDL, OpenGL, and compiles the shader
-func InitShaderManagerUint(shaderSource, sourceFile string) *ShaderManager[uint32] {
-    window, GLContext := InitSdlNoWindow()
-    if err := gl.Init(); err != nil {
-        log.Fatalln("Failed to initialize OpenGL:", err)
+// InitShaderManager compiles the shader on the shared ComputeContext, initializing SDL and OpenGL if needed
+//
+// Exits if the driver has no double precision support, see InitShaderManagerFloatMode to fall back to float32.
+// A buffer at binding 0 without doubles only prints a warning here, the shader is run as written.
+func InitShaderManagerFloat(shaderSource, sourceFile string) *ShaderManager[float64] {
+    if err := checkFloat64Source(shaderSource, sourceFile); err != nil {
+        fmt.Printf("%s, running it anyway\n", err)
     }
-
-    shaderProgram := CreateComputeShader(shaderSource, sourceFile)
-
-    return &ShaderManager[uint32]{
-        Window:        window,
-        GLContext:     GLContext,
-        ShaderProgram: shaderProgram,
+    ctx, err := AcquireComputeContext()
+    if err != nil {
+        if sm := cpuFallback[float64](shaderSource, sourceFile, err); sm != nil {
+            return sm
+        }
+        log.Fatal(err)
     }
-}
+    defer ctx.Release()
 
-// InitShaderManager initializes SDL, OpenGL, and compiles the shader
-func InitShaderManagerVec3(shaderSource, sourceFile string) *ShaderManager[mgl32.Vec4] {
-    window, GLContext := InitSdlNoWindow()
-    if err := gl.Init(); err != nil {
-        log.Fatalln("Failed to initialize OpenGL:", err)
+    sm, err := newShaderManagerFloat(ctx, shaderSource, sourceFile, Float64Native)
+    if err != nil {
+        log.Fatal(err)
     }
+    return sm
+}
 
-    shaderProgram := CreateComputeShader(shaderSource, sourceFile)
+// InitShaderManager compiles the shader on the shared ComputeContext, initializing SDL and OpenGL if needed
+func InitShaderManagerUint(shaderSource, sourceFile string) *ShaderManager[uint32] {
+    return initShaderManager[uint32](shaderSource, sourceFile)
+}
 
-    return &ShaderManager[mgl32.Vec4]{
-        Window:        window,
-        GLContext:     GLContext,
-        ShaderProgram: shaderProgram,
-    }
+// InitShaderManager compiles the shader on the shared ComputeContext, initializing SDL and OpenGL if needed
+func InitShaderManagerVec3(shaderSource, sourceFile string) *ShaderManager[mgl32.Vec4] {
+    return initShaderManager[mgl32.Vec4](shaderSource, sourceFile)
 }
 
-// Cleanup releases all resources used by the ShaderManager
+// Cleanup deletes the program and releases the ShaderManager's reference to its ComputeContext
 func (sm *ShaderManager[T]) Cleanup() {
-	gl.DeleteProgram(sm.ShaderProgram)
-	sdl.GLDeleteContext(sm.GLContext)
-	sm.Window.Destroy()
-	sdl.Quit()
+    if sm.Backend != nil {
+        sm.Backend.Cleanup()
+        return
+    }
+	sm.Context.Do(func() {
+		gl.DeleteProgram(sm.ShaderProgram)
+	})
+	if sm.Context != nil {
+		sm.Context.Release()
+		sm.Context = nil
+	}
 }
 
 // Execute runs the compute shader with the provided data
 func (sm *ShaderManager[T]) Execute(data []T, sizeWorkGP ...int) []T {
+    if sm.Backend != nil {
+        return sm.Backend.Execute(data, sizeWorkGP...)
+    }
+    if thread := sm.glThread(); thread != nil {
+        thread.Call(func() {
+            data = sm.Execute(data, sizeWorkGP...)
+        })
+        return data
+    }
+    if sm.convert32 {
+        return sm.executeAsFloat32(data, func(inner *ShaderManager[float32], data32 []float32) {
+            inner.Execute(data32, sizeWorkGP...)
+        })
+    }
 
 	dataSize := len(data) * int(unsafe.Sizeof(data[0]))
 
+    // A single SSBO can't hold it, so split it into chunks instead of letting the driver truncate it
+    if maxSize := MaxStorageBlockSize(); dataSize > maxSize {
+        if Verbose {
+            fmt.Printf("Execute: %d bytes is over the %d byte storage block limit, streaming it in chunks\n", dataSize, maxSize)
+        }
+        return sm.ExecuteStreamed(data, 0, sizeWorkGP...)
+    }
+
+    sm.Profiler.Begin("Execute")
+    defer sm.Profiler.End()
+
 	// Create buffer and bind data
+    sm.Profiler.Begin("upload")
 	var inputBuffer uint32
 	gl.GenBuffers(1, &inputBuffer)
 	gl.BindBuffer(gl.SHADER_STORAGE_BUFFER, inputBuffer)
 	gl.BufferData(gl.SHADER_STORAGE_BUFFER, dataSize, unsafe.Pointer(&data[0]), gl.DYNAMIC_COPY)
 	gl.BindBufferBase(gl.SHADER_STORAGE_BUFFER, 0, inputBuffer)
+    sm.bindAttached()
+    sm.Profiler.End()
 
 	//    var output[] float64 = make([]float64, len(data))
 	//    dataSize = len(data) * int(unsafe.Sizeof(output[0]))
@@ -430,26 +534,24 @@ func (sm *ShaderManager[T]) Execute(data []T, sizeWorkGP ...int) []T {
 	// gl.BindBufferBase(gl.SHADER_STORAGE_BUFFER, 1, outputBuffer)
 
 	// Calculate number of workgroups
-    var workGroupSize int
-    if len(sizeWorkGP) > 0 {
-        workGroupSize = sizeWorkGP[0]
-    } else {
-        var size int32
-        gl.GetIntegeri_v(gl.MAX_COMPUTE_WORK_GROUP_SIZE, 0, &size)
-        workGroupSize = int(size)
-    }
+    workGroupSize := workGroupSize(sizeWorkGP)
     numWorkgroups := uint32((len(data) + workGroupSize - 1) / workGroupSize)
 
 	// Execute the compute shader
+    sm.Profiler.Begin("dispatch")
 	gl.UseProgram(sm.ShaderProgram)
 	gl.DispatchCompute(numWorkgroups, 1, 1)
 
     // Ensure the compute shader has finished before reading the data
     gl.MemoryBarrier(gl.SHADER_STORAGE_BARRIER_BIT)
+    sm.Profiler.End()
 
 	// Retrieve the results
+    sm.Profiler.Begin("readback")
+    gl.BindBuffer(gl.SHADER_STORAGE_BUFFER, inputBuffer) // Attached SSBOs rebind the generic binding
 	gl.GetBufferSubData(gl.SHADER_STORAGE_BUFFER, 0, dataSize, unsafe.Pointer(&data[0]))
 	gl.BindBuffer(gl.SHADER_STORAGE_BUFFER, 0)
+    sm.Profiler.End()
 
 	// // Retrieve the results
 	// gl.GetBufferSubData(gl.SHADER_STORAGE_BUFFER, 1, dataSize, unsafe.Pointer(&output[0]))
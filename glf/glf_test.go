@@ -0,0 +1,115 @@
+package glf
+
+import (
+	"reflect"
+	"testing"
+	"unsafe"
+
+	"github.com/go-gl/mathgl/mgl32"
+)
+
+type plainVertex struct {
+    Position    [3]float32
+    Normal      mgl32.Vec3
+    Flags       [2]struct {
+        On      bool
+        ID      uint16
+    }
+}
+
+type paddedVertex struct {
+    A   uint8
+    B   float64
+    C   uint16
+}
+
+type nestedPointerVertex struct {
+    Position    [3]float32
+    Inner       struct {
+        Weights [4]*float32
+    }
+}
+
+func TestIsPlainData(t *testing.T) {
+    tests := []struct {
+        name    string
+        value   any
+        want    bool
+    }{
+        {"float32", float32(0), true},
+        {"every int size", struct {
+            A int
+            B int8
+            C int16
+            D int32
+            E int64
+            F uint
+            G uint8
+            H uint16
+            I uint32
+            J uint64
+        }{}, true},
+        {"uintptr", uintptr(0), false},
+        {"bool", false, true},
+        {"float64", float64(0), true},
+        {"Vec4", mgl32.Vec4{}, true},
+        {"array of arrays", [2][3]uint32{}, true},
+        {"struct of arrays and structs", plainVertex{}, true},
+        {"padded struct", paddedVertex{}, true},
+        {"empty struct", struct{}{}, true},
+        {"pointer", (*float32)(nil), false},
+        {"array of pointers", [2]*int32{}, false},
+        {"pointer nested in a struct", nestedPointerVertex{}, false},
+        {"slice", []float32{}, false},
+        {"array of slices", [1][]float32{}, false},
+        {"string", "", false},
+        {"struct with a string", struct{ Name string }{}, false},
+        {"interface", [1]any{}, false},
+        {"struct with an interface", struct{ Value any }{}, false},
+        {"map", map[int]int{}, false},
+        {"channel", make(chan int), false},
+        {"function", func() {}, false},
+        {"complex", complex64(0), false},
+        {"unsafe pointer", unsafe.Pointer(nil), false},
+    }
+    for _, test := range tests {
+        if got := isPlainData(reflect.TypeOf(test.value)); got != test.want {
+            t.Errorf("%s: isPlainData(%T) = %v, want %v", test.name, test.value, got, test.want)
+        }
+    }
+}
+
+// The size is Go's, padding included, so it has to match what the attribute offsets assume
+func TestBufferElementSize(t *testing.T) {
+    if got := bufferElementSize[float32](); got != 4 {
+        t.Errorf("float32 is %d bytes", got)
+    }
+    if got := bufferElementSize[mgl32.Vec3](); got != 12 {
+        t.Errorf("Vec3 is %d bytes", got)
+    }
+    if got := bufferElementSize[[4][4]float64](); got != 128 {
+        t.Errorf("a 4x4 float64 array is %d bytes", got)
+    }
+    // 1 byte, 7 of padding, 8, 2, then 6 of padding to keep the next B aligned
+    if got := bufferElementSize[paddedVertex](); got != 24 || unsafe.Offsetof(paddedVertex{}.B) != 8 {
+        t.Errorf("the padded struct is %d bytes with B at %d, want 24 and 8", got, unsafe.Offsetof(paddedVertex{}.B))
+    }
+    if got := bufferElementSize[plainVertex](); got != int(unsafe.Sizeof(plainVertex{})) {
+        t.Errorf("the vertex struct is %d bytes, want %d", got, unsafe.Sizeof(plainVertex{}))
+    }
+
+    expectPanic := func(name string, size func() int) {
+        t.Helper()
+        defer func() {
+            if recover() == nil {
+                t.Errorf("%s: no panic", name)
+            }
+        }()
+        size()
+    }
+    expectPanic("string", bufferElementSize[string])
+    expectPanic("pointer", bufferElementSize[*float32])
+    expectPanic("nested pointer", bufferElementSize[nestedPointerVertex])
+    expectPanic("interface", bufferElementSize[any])
+    expectPanic("slice", bufferElementSize[[]float32])
+}
@@ -0,0 +1,146 @@
+// Output Comparison Helpers
+package glftest
+
+import (
+	"fmt"
+	"math"
+	"strconv"
+
+	"github.com/KCkingcollin/go-help-func/glf"
+	"github.com/go-gl/mathgl/mgl32"
+)
+
+// Tolerance is how far a float output may be from the reference
+//
+// A value passes if it's within (ULP) units in the last place or (Epsilon) absolute difference, so the
+// zero Tolerance only accepts exact matches. Integer outputs always have to match exactly.
+type Tolerance struct {
+    ULP         uint64
+    Epsilon     float64
+}
+
+func (tol Tolerance) String() string {
+    return fmt.Sprintf("%d ULP, epsilon %g", tol.ULP, tol.Epsilon)
+}
+
+// Mismatch is one output that differs from the reference
+type Mismatch struct {
+    Index       int
+    Component   int     // Vec4 component, -1 for scalars
+    Got         float64
+    Want        float64
+    ULP         uint64  // Distance in units in the last place, 0 for integers
+    bitSize     int     // 32 for float32 and Vec4 values, so they print without float64 noise
+}
+
+func (mismatch Mismatch) String() string {
+    index := fmt.Sprint(mismatch.Index)
+    if mismatch.Component >= 0 {
+        index += "." + string("xyzw"[mismatch.Component])
+    }
+    bitSize := 64
+    if mismatch.bitSize == 32 {
+        bitSize = 32
+    }
+    got := strconv.FormatFloat(mismatch.Got, 'g', -1, bitSize)
+    want := strconv.FormatFloat(mismatch.Want, 'g', -1, bitSize)
+    if mismatch.ULP == 0 {
+        return fmt.Sprintf("[%s] got %s, want %s", index, got, want)
+    }
+    return fmt.Sprintf("[%s] got %s, want %s (diff %g, %d ULP)",
+        index, got, want, math.Abs(mismatch.Got-mismatch.Want), mismatch.ULP)
+}
+
+// Compares the outputs up to the shorter length, and returns every value outside (tol) in index order
+func Compare[T glf.ShaderData](got, want []T, tol Tolerance) []Mismatch {
+    var mismatches []Mismatch
+    for i := range min(len(got), len(want)) {
+        switch got := any(got[i]).(type) {
+        case int32:
+            if want := any(want[i]).(int32); got != want {
+                mismatches = append(mismatches, Mismatch{i, -1, float64(got), float64(want), 0, 64})
+            }
+        case uint32:
+            if want := any(want[i]).(uint32); got != want {
+                mismatches = append(mismatches, Mismatch{i, -1, float64(got), float64(want), 0, 64})
+            }
+        case float32:
+            if ulp, ok := compare32(got, any(want[i]).(float32), tol); !ok {
+                mismatches = append(mismatches, Mismatch{i, -1, float64(got), float64(any(want[i]).(float32)), ulp, 32})
+            }
+        case float64:
+            if ulp, ok := compare64(got, any(want[i]).(float64), tol); !ok {
+                mismatches = append(mismatches, Mismatch{i, -1, got, any(want[i]).(float64), ulp, 64})
+            }
+        case mgl32.Vec4:
+            want := any(want[i]).(mgl32.Vec4)
+            for c := range got {
+                if ulp, ok := compare32(got[c], want[c], tol); !ok {
+                    mismatches = append(mismatches, Mismatch{i, c, float64(got[c]), float64(want[c]), ulp, 32})
+                }
+            }
+        }
+    }
+    return mismatches
+}
+
+// Returns the ULP distance between two float32s and whether it's within (tol)
+func compare32(got, want float32, tol Tolerance) (uint64, bool) {
+    ulp := ULP32(got, want)
+    return ulp, ulp <= tol.ULP || math.Abs(float64(got)-float64(want)) <= tol.Epsilon
+}
+
+// Returns the ULP distance between two float64s and whether it's within (tol)
+func compare64(got, want float64, tol Tolerance) (uint64, bool) {
+    ulp := ULP64(got, want)
+    return ulp, ulp <= tol.ULP || math.Abs(got-want) <= tol.Epsilon
+}
+
+// Returns how many representable float32s apart (a) and (b) are
+//
+// +0 and -0 are 0 apart, two NaNs are 0 apart, and a NaN is as far as possible from anything else
+func ULP32(a, b float32) uint64 {
+    if a != a || b != b {
+        if a != a && b != b {
+            return 0
+        }
+        return math.MaxUint64
+    }
+    return distance(ordered32(a), ordered32(b))
+}
+
+// Returns how many representable float64s apart (a) and (b) are, see ULP32
+func ULP64(a, b float64) uint64 {
+    if math.IsNaN(a) || math.IsNaN(b) {
+        if math.IsNaN(a) && math.IsNaN(b) {
+            return 0
+        }
+        return math.MaxUint64
+    }
+    return distance(ordered64(a), ordered64(b))
+}
+
+// Maps a float32's bits to an integer that orders the same way the floats do
+func ordered32(f float32) int64 {
+    bits := int32(math.Float32bits(f))
+    if bits < 0 {
+        return int64(math.MinInt32) - int64(bits)
+    }
+    return int64(bits)
+}
+
+// Maps a float64's bits to an unsigned integer that orders the same way the floats do
+func ordered64(f float64) uint64 {
+    bits := math.Float64bits(f)
+    if bits>>63 == 1 {
+        return 1<<63 - bits&^(1<<63)
+    }
+    return 1<<63 + bits
+}
+
+func distance[N int64 | uint64](a, b N) uint64 {
+    if a > b {
+        return uint64(a - b)
+    }
+    return uint64(b - a)
+}
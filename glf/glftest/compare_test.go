@@ -0,0 +1,121 @@
+package glftest
+
+import (
+	"math"
+	"strings"
+	"testing"
+
+	"github.com/go-gl/mathgl/mgl32"
+)
+
+func TestULP32(t *testing.T) {
+    nan := float32(math.NaN())
+    smallest := math.Float32frombits(1)
+    tests := []struct {
+        name    string
+        a, b    float32
+        want    uint64
+    }{
+        {"equal", 1.5, 1.5, 0},
+        {"next up", 1, math.Nextafter32(1, 2), 1},
+        {"next down", 1, math.Nextafter32(1, 0), 1},
+        {"zeros", 0, float32(math.Copysign(0, -1)), 0},
+        {"negative zero to smallest", float32(math.Copysign(0, -1)), smallest, 1},
+        {"across zero", -smallest, smallest, 2},
+        {"opposite signs", -1, 1, 2 * uint64(math.Float32bits(1))},
+        {"negative", -2, math.Nextafter32(-2, -3), 1},
+        {"both NaN", nan, nan, 0},
+        {"NaN and number", nan, 1, math.MaxUint64},
+        {"number and NaN", 0, nan, math.MaxUint64},
+        {"infinities", float32(math.Inf(-1)), float32(math.Inf(1)), 2 * uint64(math.Float32bits(float32(math.Inf(1))))},
+    }
+    for _, test := range tests {
+        if got := ULP32(test.a, test.b); got != test.want {
+            t.Errorf("%s: ULP32(%v, %v) = %d, want %d", test.name, test.a, test.b, got, test.want)
+        }
+        if got := ULP32(test.b, test.a); got != test.want {
+            t.Errorf("%s: ULP32(%v, %v) = %d, want %d, the distance should be symmetric", test.name, test.b, test.a, got, test.want)
+        }
+    }
+}
+
+func TestULP64(t *testing.T) {
+    smallest := math.Float64frombits(1)
+    tests := []struct {
+        name    string
+        a, b    float64
+        want    uint64
+    }{
+        {"equal", 1.5, 1.5, 0},
+        {"next up", 1, math.Nextafter(1, 2), 1},
+        {"zeros", 0, math.Copysign(0, -1), 0},
+        {"across zero", -smallest, smallest, 2},
+        {"opposite signs", -1, 1, 2 * math.Float64bits(1)},
+        {"negative", -2, math.Nextafter(-2, -3), 1},
+        {"both NaN", math.NaN(), math.NaN(), 0},
+        {"NaN and number", math.NaN(), -1, math.MaxUint64},
+    }
+    for _, test := range tests {
+        if got := ULP64(test.a, test.b); got != test.want {
+            t.Errorf("%s: ULP64(%v, %v) = %d, want %d", test.name, test.a, test.b, got, test.want)
+        }
+        if got := ULP64(test.b, test.a); got != test.want {
+            t.Errorf("%s: ULP64(%v, %v) = %d, want %d, the distance should be symmetric", test.name, test.b, test.a, got, test.want)
+        }
+    }
+}
+
+func TestCompareTolerance(t *testing.T) {
+    want := []float32{1, 1, 1, 0}
+    got := []float32{1, math.Nextafter32(1, 2), 1.001, float32(math.Copysign(0, -1))}
+
+    if mismatches := Compare(got, want, Tolerance{}); len(mismatches) != 2 || mismatches[0].Index != 1 || mismatches[1].Index != 2 {
+        t.Errorf("exact compare gave %v, want indexes 1 and 2", mismatches)
+    }
+    if mismatches := Compare(got, want, Tolerance{ULP: 1}); len(mismatches) != 1 || mismatches[0].Index != 2 {
+        t.Errorf("1 ULP compare gave %v, want index 2", mismatches)
+    }
+    if mismatches := Compare(got, want, Tolerance{Epsilon: 0.01}); len(mismatches) != 0 {
+        t.Errorf("epsilon compare gave %v, want no mismatches", mismatches)
+    }
+    if mismatches := Compare([]float32{1, 2, 3}, []float32{1}, Tolerance{}); len(mismatches) != 0 {
+        t.Errorf("compare only looks at the shorter length, got %v", mismatches)
+    }
+}
+
+func TestCompareIntegers(t *testing.T) {
+    mismatches := Compare([]int32{1, -2, 3}, []int32{1, 2, 3}, Tolerance{ULP: 100, Epsilon: 10})
+    if len(mismatches) != 1 || mismatches[0].Index != 1 || mismatches[0].ULP != 0 {
+        t.Fatalf("integers have to match exactly whatever the tolerance, got %v", mismatches)
+    }
+    if got, want := mismatches[0].String(), "[1] got -2, want 2"; got != want {
+        t.Errorf("mismatch prints as %q, want %q", got, want)
+    }
+}
+
+func TestMismatchString(t *testing.T) {
+    vec := Compare([]mgl32.Vec4{{}, {0, 0.1, 0, 0}}, []mgl32.Vec4{{}, {0, 0.2, 0, 0}}, Tolerance{})
+    if len(vec) != 1 {
+        t.Fatalf("got %d Vec4 mismatches, want 1", len(vec))
+    }
+    if got := vec[0].String(); !strings.HasPrefix(got, "[1.y] got 0.1, want 0.2 (diff ") {
+        t.Errorf("Vec4 mismatch prints as %q, want the index with its component and float32 values", got)
+    }
+
+    scalar := Compare([]float64{0, 0, 0.1}, []float64{0, 0, 0.3}, Tolerance{})
+    if got := scalar[0].String(); !strings.HasPrefix(got, "[2] got 0.1, want 0.3 (diff ") || !strings.HasSuffix(got, " ULP)") {
+        t.Errorf("float64 mismatch prints as %q", got)
+    }
+}
+
+func TestFormatMismatches(t *testing.T) {
+    mismatches := Compare([]uint32{1, 2, 3, 4}, []uint32{0, 0, 0, 0}, Tolerance{})
+    got := FormatMismatches(mismatches, 2)
+    want := "  [0] got 1, want 0\n  [1] got 2, want 0\n  ... and 2 more"
+    if got != want {
+        t.Errorf("FormatMismatches gave\n%s\nwant\n%s", got, want)
+    }
+    if got := FormatMismatches(mismatches[:2], 2); strings.Contains(got, "more") {
+        t.Errorf("FormatMismatches with everything shown gave\n%s", got)
+    }
+}
@@ -0,0 +1,174 @@
+// Compute Kernel Golden Test Helpers
+//
+// Package glftest runs compute shaders through a glf.ShaderManager on table driven inputs, and checks the
+// outputs against a Go reference function. It's meant to be imported from _test.go files:
+//
+//	func TestDouble(t *testing.T) {
+//	    glftest.Run(t, glftest.Kernel[float32]{
+//	        File:       "shaders/double.comp",
+//	        Reference:  func(in []float32) []float32 { ... },
+//	        Tolerance:  glftest.Tolerance{ULP: 2},
+//	    }, glftest.Case[float32]{Name: "ramp", Input: ramp})
+//	}
+//
+// Tests are skipped when no GL 4.3 context can be created, so they still pass on machines without a GPU.
+// Mesa's llvmpipe is enough to run them for real.
+package glftest
+
+import (
+	"fmt"
+	"os"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/KCkingcollin/go-help-func/glf"
+	"github.com/go-gl/gl/v4.6-core/gl"
+)
+
+// Kernel is a compute shader and the Go function it should match
+type Kernel[T glf.ShaderData] struct {
+    Source          string                 // GLSL source, read from File when empty
+    File            string                 // Path of the shader, also used as its name in errors
+    Reference       func(input []T) []T    // Expected output for an input, the input is a copy it may modify
+    Tolerance       Tolerance              // Allowed difference for float outputs
+    WorkGroupSize   int                    // Invocations per workgroup, read from the shader's local_size_x when 0
+    Float64Mode     glf.Float64Mode        // How float64 kernels are compiled, tests are skipped if it isn't supported
+}
+
+// Case is one input to run a Kernel on
+type Case[T glf.ShaderData] struct {
+    Name    string
+    Input   []T
+}
+
+var shared struct {
+    once    sync.Once
+    ctx     *glf.ComputeContext
+    skip    string
+}
+
+// Returns the compute context shared by every test in the package, or skips (tb) if there isn't one
+//
+// The context lives on its own GLThread, so tests can use it from any goroutine, and it stays
+// alive until the test binary exits.
+func Context(tb testing.TB) *glf.ComputeContext {
+    tb.Helper()
+    shared.once.Do(func() {
+        ctx, err := glf.NewThreadedComputeContext(glf.DefaultContextBackend)
+        if err != nil {
+            shared.skip = fmt.Sprintf("no GL 4.3 context could be created: %v", err)
+            return
+        }
+        var major, minor int32
+        ctx.Do(func() {
+            gl.GetIntegerv(gl.MAJOR_VERSION, &major)
+            gl.GetIntegerv(gl.MINOR_VERSION, &minor)
+        })
+        if major < 4 || (major == 4 && minor < 3) {
+            ctx.Release()
+            shared.skip = fmt.Sprintf("compute shaders need GL 4.3, the context is GL %d.%d", major, minor)
+            return
+        }
+        shared.ctx = ctx
+    })
+    if shared.ctx == nil {
+        tb.Skip(shared.skip)
+    }
+    return shared.ctx
+}
+
+// Compiles (kernel) on the shared context, and cleans it up when the test finishes
+//
+// Fails the test if the shader doesn't compile, and skips it if there's no context or a float64
+// kernel needs double precision the driver doesn't have
+func Load[T glf.ShaderData](tb testing.TB, kernel Kernel[T]) *glf.ShaderManager[T] {
+    tb.Helper()
+    ctx := Context(tb)
+
+    source := kernel.Source
+    if source == "" {
+        data, err := os.ReadFile(kernel.File)
+        if err != nil {
+            tb.Fatalf("reading kernel: %v", err)
+        }
+        source = string(data)
+    }
+
+    var sm *glf.ShaderManager[T]
+    var err error
+    var zero T
+    if _, ok := any(zero).(float64); ok {
+        supported := false
+        ctx.Do(func() {
+            supported = glf.HasFloat64Support()
+        })
+        if !supported && kernel.Float64Mode == glf.Float64Native {
+            tb.Skipf("%s: the driver doesn't support double precision shaders", kernel.File)
+        }
+        var sm64 *glf.ShaderManager[float64]
+        sm64, err = glf.NewShaderManagerFloat(ctx, source, kernel.File, kernel.Float64Mode)
+        sm, _ = any(sm64).(*glf.ShaderManager[T])
+    } else {
+        sm, err = glf.TryNewShaderManager[T](ctx, source, kernel.File)
+    }
+    if err != nil {
+        tb.Fatal(err)
+    }
+    tb.Cleanup(sm.Cleanup)
+    return sm
+}
+
+// Runs every case as a subtest, see Check
+func Run[T glf.ShaderData](t *testing.T, kernel Kernel[T], cases ...Case[T]) {
+    t.Helper()
+    sm := Load(t, kernel)
+    for _, c := range cases {
+        t.Run(c.Name, func(t *testing.T) {
+            Check(t, sm, kernel, c)
+        })
+    }
+}
+
+// Runs (sm) and (kernel.Reference) on copies of the case input, and fails (tb) listing the mismatching indices
+func Check[T glf.ShaderData](tb testing.TB, sm *glf.ShaderManager[T], kernel Kernel[T], c Case[T]) {
+    tb.Helper()
+    want := kernel.Reference(append([]T(nil), c.Input...))
+    got := append([]T(nil), c.Input...)
+    if len(got) > 0 {
+        got = sm.Execute(got, workGroupSize(sm, kernel))
+    }
+
+    if len(got) != len(want) {
+        tb.Errorf("%s: got %d outputs, the reference returned %d", kernel.File, len(got), len(want))
+    }
+    if mismatches := Compare(got, want, kernel.Tolerance); len(mismatches) > 0 {
+        tb.Errorf("%s: %d of %d outputs differ from the reference (tolerance %v)\n%s",
+            kernel.File, len(mismatches), min(len(got), len(want)), kernel.Tolerance, FormatMismatches(mismatches, 10))
+    }
+}
+
+// Returns the kernel's workgroup size, asking GL for the program's local_size_x if it isn't set
+func workGroupSize[T glf.ShaderData](sm *glf.ShaderManager[T], kernel Kernel[T]) int {
+    if kernel.WorkGroupSize > 0 {
+        return kernel.WorkGroupSize
+    }
+    var size [3]int32
+    sm.Context.Do(func() {
+        gl.GetProgramiv(sm.ShaderProgram, gl.COMPUTE_WORK_GROUP_SIZE, &size[0])
+    })
+    return int(size[0])
+}
+
+// Lists the first (limit) mismatches one per line, and how many more there are
+func FormatMismatches(mismatches []Mismatch, limit int) string {
+    var lines []string
+    for i, mismatch := range mismatches {
+        if i == limit {
+            lines = append(lines, fmt.Sprintf("  ... and %d more", len(mismatches)-limit))
+            break
+        }
+        lines = append(lines, "  "+mismatch.String())
+    }
+    return strings.Join(lines, "\n")
+}
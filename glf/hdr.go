@@ -0,0 +1,148 @@
+// Radiance HDR Texture Decoder
+package glf
+
+import (
+	"bufio"
+	"bytes"
+	"errors"
+	"fmt"
+	"image"
+	"io"
+	"math"
+	"strings"
+)
+
+func matchHDR(header []byte) bool {
+    return bytes.HasPrefix(header, []byte("#?RADIANCE")) || bytes.HasPrefix(header, []byte("#?RGBE"))
+}
+
+// Decodes an RGBE Radiance picture into a *FloatImage, flat or run length encoded
+func decodeHDR(r io.Reader) (image.Image, error) {
+    reader := bufio.NewReader(r)
+
+    // Header lines up to the first blank one, then the resolution line
+    for {
+        line, err := reader.ReadString('\n')
+        if err != nil {
+            return nil, errors.New("hdr: header is truncated")
+        }
+        line = strings.TrimSpace(line)
+        if line == "" {
+            break
+        }
+        if format, ok := strings.CutPrefix(line, "FORMAT="); ok && format != "32-bit_rle_rgbe" {
+            return nil, fmt.Errorf("hdr: unsupported format %s", format)
+        }
+    }
+    line, err := reader.ReadString('\n')
+    if err != nil {
+        return nil, errors.New("hdr: resolution is missing")
+    }
+    var yAxis, xAxis string
+    var width, height int
+    if _, err := fmt.Sscanf(line, "%s %d %s %d", &yAxis, &height, &xAxis, &width); err != nil {
+        return nil, fmt.Errorf("hdr: invalid resolution %q", strings.TrimSpace(line))
+    }
+    if (yAxis != "-Y" && yAxis != "+Y") || xAxis != "+X" || width <= 0 || height <= 0 || width > 1<<16 || height > 1<<16 {
+        return nil, fmt.Errorf("hdr: unsupported resolution %q", strings.TrimSpace(line))
+    }
+
+    img := NewFloatImage(image.Rect(0, 0, width, height))
+    scanline := make([]byte, width*4)
+    for row := 0; row < height; row++ {
+        if err := readHDRScanline(reader, scanline); err != nil {
+            return nil, err
+        }
+        y := row
+        if yAxis == "+Y" {
+            y = height - 1 - row
+        }
+        out := img.Pix[y*img.Stride:]
+        for x := 0; x < width; x++ {
+            rgbe := scanline[x*4 : x*4+4]
+            if rgbe[3] == 0 {
+                out[x*4+3] = 1
+                continue
+            }
+            scale := float32(math.Ldexp(1, int(rgbe[3])-(128+8)))
+            out[x*4] = float32(rgbe[0]) * scale
+            out[x*4+1] = float32(rgbe[1]) * scale
+            out[x*4+2] = float32(rgbe[2]) * scale
+            out[x*4+3] = 1
+        }
+    }
+    return img, nil
+}
+
+// Reads one scanline of RGBE pixels into (scanline), in the new per channel RLE, the old RLE, or flat
+func readHDRScanline(reader *bufio.Reader, scanline []byte) error {
+    width := len(scanline) / 4
+    start, err := reader.Peek(4)
+    if err != nil {
+        return errors.New("hdr: pixel data is truncated")
+    }
+
+    // New RLE, each channel is run length encoded separately
+    if width >= 8 && width < 0x8000 && start[0] == 2 && start[1] == 2 && start[2]&0x80 == 0 {
+        if int(start[2])<<8|int(start[3]) != width {
+            return errors.New("hdr: scanline width doesn't match the image")
+        }
+        reader.Discard(4)
+        for channel := 0; channel < 4; channel++ {
+            for x := 0; x < width; {
+                count, err := reader.ReadByte()
+                if err != nil {
+                    return errors.New("hdr: pixel data is truncated")
+                }
+                if count > 128 {
+                    value, err := reader.ReadByte()
+                    if err != nil || x+int(count-128) > width {
+                        return errors.New("hdr: invalid run length data")
+                    }
+                    for n := 0; n < int(count-128); n++ {
+                        scanline[(x+n)*4+channel] = value
+                    }
+                    x += int(count - 128)
+                } else {
+                    if count == 0 || x+int(count) > width {
+                        return errors.New("hdr: invalid run length data")
+                    }
+                    for n := 0; n < int(count); n++ {
+                        value, err := reader.ReadByte()
+                        if err != nil {
+                            return errors.New("hdr: pixel data is truncated")
+                        }
+                        scanline[(x+n)*4+channel] = value
+                    }
+                    x += int(count)
+                }
+            }
+        }
+        return nil
+    }
+
+    // Flat pixels, where 1, 1, 1, n repeats the previous pixel
+    shift := 0
+    for x := 0; x < width; {
+        var pixel [4]byte
+        if _, err := io.ReadFull(reader, pixel[:]); err != nil {
+            return errors.New("hdr: pixel data is truncated")
+        }
+        if pixel[0] == 1 && pixel[1] == 1 && pixel[2] == 1 && x > 0 {
+            count := int(pixel[3]) << shift
+            if x+count > width {
+                return errors.New("hdr: invalid run length data")
+            }
+            for n := 0; n < count; n++ {
+                copy(scanline[(x+n)*4:], scanline[(x-1)*4:x*4])
+            }
+            x += count
+            shift += 8
+            continue
+        }
+        copy(scanline[x*4:], pixel[:])
+        x++
+        shift = 0
+    }
+    return nil
+}
@@ -0,0 +1,117 @@
+package glf
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+)
+
+// Builds a Radiance file with (header) lines, a resolution line and raw scanline data
+func makeHDR(header, resolution string, pixels []byte) []byte {
+    if header != "" {
+        header += "\n"
+    }
+    data := []byte("#?RADIANCE\n" + header + "\n" + resolution + "\n")
+    return append(data, pixels...)
+}
+
+// Fails the test if (img) isn't a FloatImage holding (want), RGBA for each pixel with rows from the top
+func expectFloatImage(t *testing.T, name string, img any, width int, want []float32) {
+    t.Helper()
+    float, ok := img.(*FloatImage)
+    if !ok {
+        t.Errorf("%s: decoded to %T, want *FloatImage", name, img)
+        return
+    }
+    if size := float.Rect.Size(); size.X != width || 4*size.X*size.Y != len(want) {
+        t.Errorf("%s: decoded to %v, want %d pixels across", name, float.Rect, width)
+        return
+    }
+    for i := 0; i < len(want); i += 4 {
+        x, y := i/4%width, i/4/width
+        at := float.PixOffset(x, y)
+        if got := float.Pix[at : at+4]; !equalFloats(got, want[i:i+4]) {
+            t.Errorf("%s: pixel (%d, %d) is %v, want %v", name, x, y, got, want[i:i+4])
+        }
+    }
+}
+
+func equalFloats(a, b []float32) bool {
+    for i := range a {
+        if a[i] != b[i] {
+            return false
+        }
+    }
+    return len(a) == len(b)
+}
+
+func TestDecodeHDR(t *testing.T) {
+    // 128 with an exponent of 129 is 1.0, 64 is 0.5 and 32 is 0.25
+    pixel := []byte{128, 64, 32, 129}
+    newRLE := []byte{2, 2, 0, 8}
+    for _, channel := range pixel {
+        newRLE = append(newRLE, 128+8, channel)
+    }
+    tests := []struct {
+        name    string
+        file    []byte
+        width   int
+        want    []float32
+    }{
+        {"flat", makeHDR("FORMAT=32-bit_rle_rgbe\nEXPOSURE=1", "-Y 1 +X 2", append([]byte{0, 0, 0, 0}, pixel...)), 2,
+            []float32{0, 0, 0, 1, 1, 0.5, 0.25, 1}},
+        {"bottom up", makeHDR("", "+Y 2 +X 1", append([]byte{0, 0, 0, 0}, pixel...)), 1,
+            []float32{1, 0.5, 0.25, 1, 0, 0, 0, 1}},
+        {"old RLE", makeHDR("", "-Y 1 +X 4", append(append([]byte{}, pixel...), 1, 1, 1, 3)), 4,
+            []float32{1, 0.5, 0.25, 1, 1, 0.5, 0.25, 1, 1, 0.5, 0.25, 1, 1, 0.5, 0.25, 1}},
+        {"new RLE", makeHDR("", "-Y 1 +X 8", newRLE), 8,
+            []float32{1, 0.5, 0.25, 1, 1, 0.5, 0.25, 1, 1, 0.5, 0.25, 1, 1, 0.5, 0.25, 1, 1, 0.5, 0.25, 1, 1, 0.5, 0.25, 1, 1, 0.5, 0.25, 1, 1, 0.5, 0.25, 1}},
+        // Channels can mix literal runs and repeats
+        {"new RLE literals", makeHDR("", "-Y 1 +X 8", append([]byte{2, 2, 0, 8},
+            8, 128, 128, 128, 128, 128, 128, 128, 128,
+            136, 64,
+            3, 32, 32, 32, 128+5, 32,
+            136, 129)), 8,
+            []float32{1, 0.5, 0.25, 1, 1, 0.5, 0.25, 1, 1, 0.5, 0.25, 1, 1, 0.5, 0.25, 1, 1, 0.5, 0.25, 1, 1, 0.5, 0.25, 1, 1, 0.5, 0.25, 1, 1, 0.5, 0.25, 1}},
+    }
+    for _, test := range tests {
+        if !matchHDR(test.file) {
+            t.Errorf("%s: matchHDR is false", test.name)
+        }
+        img, err := decodeHDR(bytes.NewReader(test.file))
+        if err != nil {
+            t.Errorf("%s: %v", test.name, err)
+            continue
+        }
+        expectFloatImage(t, test.name, img, test.width, test.want)
+    }
+    if !matchHDR([]byte("#?RGBE\n")) || matchHDR([]byte("#?RAD")) {
+        t.Error("matchHDR doesn't check the whole magic")
+    }
+}
+
+func TestDecodeHDRErrors(t *testing.T) {
+    tests := []struct {
+        name    string
+        file    []byte
+        want    string
+    }{
+        {"header", []byte("#?RADIANCE\nFORMAT=32-bit_rle_rgbe\n"), "header is truncated"},
+        {"format", makeHDR("FORMAT=32-bit_rle_xyze", "-Y 1 +X 1", make([]byte, 4)), "unsupported format 32-bit_rle_xyze"},
+        {"resolution", []byte("#?RADIANCE\n\n"), "resolution is missing"},
+        {"resolution syntax", makeHDR("", "-Y one +X 1", make([]byte, 4)), "invalid resolution"},
+        {"axes", makeHDR("", "+X 1 -Y 1", make([]byte, 4)), "unsupported resolution"},
+        {"too big", makeHDR("", "-Y 1 +X 65537", make([]byte, 4)), "unsupported resolution"},
+        {"pixels", makeHDR("", "-Y 2 +X 1", make([]byte, 6)), "pixel data is truncated"},
+        {"scanline width", makeHDR("", "-Y 1 +X 8", []byte{2, 2, 0, 9}), "scanline width doesn't match"},
+        {"run past the end", makeHDR("", "-Y 1 +X 8", []byte{2, 2, 0, 8, 128 + 9, 0}), "invalid run length data"},
+        {"zero literal", makeHDR("", "-Y 1 +X 8", []byte{2, 2, 0, 8, 0}), "invalid run length data"},
+        {"old RLE past the end", makeHDR("", "-Y 1 +X 2", []byte{1, 2, 3, 4, 1, 1, 1, 2}), "invalid run length data"},
+    }
+    for _, test := range tests {
+        _, err := decodeHDR(bytes.NewReader(test.file))
+        if err == nil || !strings.HasPrefix(err.Error(), "hdr: ") || !strings.Contains(err.Error(), test.want) {
+            t.Errorf("%s: got error %v, want one containing %q", test.name, err, test.want)
+        }
+    }
+}
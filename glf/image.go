@@ -0,0 +1,193 @@
+// Image Compute GL Helper Functions
+package glf
+
+import (
+	"errors"
+	"fmt"
+	"image"
+	"image/draw"
+	"regexp"
+	"strconv"
+	"strings"
+
+	"github.com/go-gl/gl/v4.6-core/gl"
+)
+
+// A texture bound to an image unit for ExecuteImage
+type ImageBinding struct {
+    Unit        uint32 // Image unit, has to match the binding of an image2D in the shader
+    Texture     uint32
+    Level       int32
+    Access      uint32 // gl.READ_ONLY, gl.WRITE_ONLY or gl.READ_WRITE
+    Format      uint32 // Sized internal format the shader sees, e.g. gl.RGBA8 or gl.RGBA16F for LoadTexture textures
+}
+
+// An image2D uniform declared in a compute shader
+type imageDecl struct {
+    name        string
+    binding     uint32
+    format      uint32 // 0 if the shader gives no format
+    readonly    bool
+    writeonly   bool
+}
+
+// GLSL image format layout qualifiers and their sized internal formats
+var imageFormats = map[string]uint32{
+    "rgba32f": gl.RGBA32F, "rgba16f": gl.RGBA16F, "rg32f": gl.RG32F, "rg16f": gl.RG16F,
+    "r11f_g11f_b10f": gl.R11F_G11F_B10F, "r32f": gl.R32F, "r16f": gl.R16F,
+    "rgba16": gl.RGBA16, "rgb10_a2": gl.RGB10_A2, "rgba8": gl.RGBA8, "rg16": gl.RG16, "rg8": gl.RG8,
+    "r16": gl.R16, "r8": gl.R8, "rgba16_snorm": gl.RGBA16_SNORM, "rgba8_snorm": gl.RGBA8_SNORM,
+    "rg16_snorm": gl.RG16_SNORM, "rg8_snorm": gl.RG8_SNORM, "r16_snorm": gl.R16_SNORM, "r8_snorm": gl.R8_SNORM,
+    "rgba32i": gl.RGBA32I, "rgba16i": gl.RGBA16I, "rgba8i": gl.RGBA8I, "rg32i": gl.RG32I, "rg16i": gl.RG16I,
+    "rg8i": gl.RG8I, "r32i": gl.R32I, "r16i": gl.R16I, "r8i": gl.R8I,
+    "rgba32ui": gl.RGBA32UI, "rgba16ui": gl.RGBA16UI, "rgb10_a2ui": gl.RGB10_A2UI, "rgba8ui": gl.RGBA8UI,
+    "rg32ui": gl.RG32UI, "rg16ui": gl.RG16UI, "rg8ui": gl.RG8UI, "r32ui": gl.R32UI, "r16ui": gl.R16UI, "r8ui": gl.R8UI,
+}
+
+var imageDeclPattern = regexp.MustCompile(`layout\s*\(([^)]*)\)\s*((?:\w+\s+)*?)[iu]?image2D\s+(\w+)`)
+
+// Finds every image2D uniform in a shader source along with its binding, format and access qualifiers
+func parseImageDecls(shaderSource string) []imageDecl {
+    var decls []imageDecl
+    for _, match := range imageDeclPattern.FindAllStringSubmatch(shaderSource, -1) {
+        decl := imageDecl{name: match[3]}
+        for _, part := range strings.Split(match[1], ",") {
+            key, value, found := strings.Cut(strings.TrimSpace(part), "=")
+            key = strings.TrimSpace(key)
+            if found && key == "binding" {
+                binding, err := strconv.ParseUint(strings.TrimSpace(value), 0, 32)
+                if err == nil {
+                    decl.binding = uint32(binding)
+                }
+            } else if format, ok := imageFormats[key]; ok {
+                decl.format = format
+            }
+        }
+        for _, qualifier := range strings.Fields(match[2]) {
+            switch qualifier {
+            case "readonly":
+                decl.readonly = true
+            case "writeonly":
+                decl.writeonly = true
+            }
+        }
+        decls = append(decls, decl)
+    }
+    return decls
+}
+
+// Checks an image binding against the image2D declared at the same unit in the shader
+func checkImageBinding(decls []imageDecl, binding ImageBinding) error {
+    for _, decl := range decls {
+        if decl.binding != binding.Unit {
+            continue
+        }
+        if decl.format != 0 && decl.format != binding.Format {
+            return fmt.Errorf("image %s at binding %d is declared with format 0x%X but bound as 0x%X", decl.name, decl.binding, decl.format, binding.Format)
+        }
+        canRead := binding.Access == gl.READ_ONLY || binding.Access == gl.READ_WRITE
+        canWrite := binding.Access == gl.WRITE_ONLY || binding.Access == gl.READ_WRITE
+        if (!decl.writeonly && !canRead) || (!decl.readonly && !canWrite) {
+            return fmt.Errorf("image %s at binding %d can't be used with access 0x%X", decl.name, decl.binding, binding.Access)
+        }
+        return nil
+    }
+    return fmt.Errorf("no image2D is declared at binding %d", binding.Unit)
+}
+
+// ExecuteImage binds the images and dispatches the compute shader over a (width) x (height) grid
+//
+// The work group count comes from the local size the program was linked with.
+// Every binding is checked against the shader's image2D declarations before anything is dispatched.
+func (sm *ShaderManager[T]) ExecuteImage(width, height int, images ...ImageBinding) error {
+    if sm.Backend != nil {
+        return errors.New("ExecuteImage: images are only supported on the GL backend")
+    }
+    if thread := sm.glThread(); thread != nil {
+        var err error
+        thread.Call(func() {
+            err = sm.ExecuteImage(width, height, images...)
+        })
+        return err
+    }
+    decls := parseImageDecls(sm.source)
+    for _, binding := range images {
+        if err := checkImageBinding(decls, binding); err != nil {
+            return fmt.Errorf("ExecuteImage: %w", err)
+        }
+    }
+
+    var localSize [3]int32
+    gl.GetProgramiv(sm.ShaderProgram, gl.COMPUTE_WORK_GROUP_SIZE, &localSize[0])
+    groupsX := uint32((width + int(localSize[0]) - 1) / int(localSize[0]))
+    groupsY := uint32((height + int(localSize[1]) - 1) / int(localSize[1]))
+
+    for _, binding := range images {
+        gl.BindImageTexture(binding.Unit, binding.Texture, binding.Level, false, 0, binding.Access, binding.Format)
+    }
+
+    sm.Profiler.Begin("ExecuteImage")
+    sm.bindAttached()
+    gl.UseProgram(sm.ShaderProgram)
+    gl.DispatchCompute(groupsX, groupsY, 1)
+    gl.MemoryBarrier(gl.SHADER_IMAGE_ACCESS_BARRIER_BIT | gl.TEXTURE_FETCH_BARRIER_BIT | gl.TEXTURE_UPDATE_BARRIER_BIT)
+    sm.Profiler.End()
+
+    for _, binding := range images {
+        gl.BindImageTexture(binding.Unit, 0, 0, false, 0, gl.READ_ONLY, binding.Format)
+    }
+    return nil
+}
+
+// ProcessImage runs the shader with (src) as a readonly rgba8 image on unit 0, and an empty writeonly
+// rgba8 image of the same size on unit 1, then returns what the shader wrote
+func (sm *ShaderManager[T]) ProcessImage(src image.Image) (*image.NRGBA, error) {
+    if thread := sm.glThread(); thread != nil {
+        var result *image.NRGBA
+        var err error
+        thread.Call(func() {
+            result, err = sm.ProcessImage(src)
+        })
+        return result, err
+    }
+    width, height := src.Bounds().Dx(), src.Bounds().Dy()
+    input := UploadImage(src)
+    output := NewImageTexture(width, height, gl.RGBA8)
+    defer gl.DeleteTextures(1, &input)
+    defer gl.DeleteTextures(1, &output)
+
+    err := sm.ExecuteImage(width, height,
+        ImageBinding{Unit: 0, Texture: input, Access: gl.READ_ONLY, Format: gl.RGBA8},
+        ImageBinding{Unit: 1, Texture: output, Access: gl.WRITE_ONLY, Format: gl.RGBA8},
+    )
+    if err != nil {
+        return nil, err
+    }
+    return ReadTexture(output, 0, false)
+}
+
+// Creates a 2D texture with immutable storage of the given size and sized internal format, usable as an image2D
+func NewImageTexture(width, height int, format uint32) uint32 {
+    texture := GenBindTexture()
+    gl.TexStorage2D(gl.TEXTURE_2D, 1, format, int32(width), int32(height))
+    gl.TexParameteri(gl.TEXTURE_2D, gl.TEXTURE_MIN_FILTER, gl.LINEAR)
+    gl.TexParameteri(gl.TEXTURE_2D, gl.TEXTURE_MAG_FILTER, gl.LINEAR)
+    return texture
+}
+
+// Uploads a Go image to a new rgba8 texture with straight alpha, and returns its ID
+//
+// Row 0 of the texture is the top row of the image
+func UploadImage(img image.Image) uint32 {
+    bounds := img.Bounds()
+    nrgba := image.NewNRGBA(image.Rect(0, 0, bounds.Dx(), bounds.Dy()))
+    draw.Draw(nrgba, nrgba.Bounds(), img, bounds.Min, draw.Src)
+
+    texture := NewImageTexture(bounds.Dx(), bounds.Dy(), gl.RGBA8)
+    if len(nrgba.Pix) > 0 {
+        gl.PixelStorei(gl.UNPACK_ALIGNMENT, 1)
+        gl.TexSubImage2D(gl.TEXTURE_2D, 0, 0, 0, int32(bounds.Dx()), int32(bounds.Dy()), gl.RGBA, gl.UNSIGNED_BYTE, gl.Ptr(nrgba.Pix))
+        gl.PixelStorei(gl.UNPACK_ALIGNMENT, 4)
+    }
+    return texture
+}
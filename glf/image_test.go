@@ -0,0 +1,38 @@
+package glf
+
+import (
+	"image"
+	"image/color"
+	"testing"
+
+	"github.com/go-gl/gl/v4.6-core/gl"
+)
+
+// UploadImage sets byte alignment for its own rows, and has to put back GL's default for later uploads
+func TestUploadImageRestoresAlignment(t *testing.T) {
+    ctx, err := NewThreadedComputeContext(DefaultContextBackend)
+    if err != nil {
+        t.Skipf("no GL context could be created: %v", err)
+    }
+    defer ctx.Release()
+
+    img := image.NewRGBA(image.Rect(10, 20, 13, 22))
+    img.Set(10, 20, color.RGBA{255, 0, 0, 255})
+    img.Set(12, 21, color.RGBA{0, 0, 255, 255})
+    ctx.Do(func() {
+        texture := UploadImage(img)
+        defer gl.DeleteTextures(1, &texture)
+        var alignment int32
+        gl.GetIntegerv(gl.UNPACK_ALIGNMENT, &alignment)
+        if alignment != 4 {
+            t.Errorf("UNPACK_ALIGNMENT is %d after UploadImage, want 4", alignment)
+        }
+        got, err := ReadTexture(texture, 0, false)
+        if err != nil {
+            t.Fatal(err)
+        }
+        if got.Rect.Dx() != 3 || got.Rect.Dy() != 2 || got.NRGBAAt(0, 0) != (color.NRGBA{255, 0, 0, 255}) || got.NRGBAAt(2, 1) != (color.NRGBA{0, 0, 255, 255}) {
+            t.Errorf("the uploaded texture is %v with corners %v and %v", got.Rect, got.NRGBAAt(0, 0), got.NRGBAAt(2, 1))
+        }
+    })
+}
@@ -0,0 +1,155 @@
+// CPU Reference Implementations of the GPU Parallel Primitives
+package parallel
+
+import (
+	"fmt"
+	"math"
+	"slices"
+)
+
+// Returns the identity value of an operation for T
+func identity[T Number](op Op) T {
+    var value T
+    switch op {
+    case Min:
+        switch v := any(&value).(type) {
+        case *int32:
+            *v = math.MaxInt32
+        case *uint32:
+            *v = math.MaxUint32
+        case *float32:
+            *v = float32(math.Inf(1))
+        }
+    case Max:
+        switch v := any(&value).(type) {
+        case *int32:
+            *v = math.MinInt32
+        case *uint32:
+            *v = 0
+        case *float32:
+            *v = float32(math.Inf(-1))
+        }
+    }
+    return value
+}
+
+// CPU version of Reduce
+//
+// Float sums are added in order, so they can differ slightly from the GPU's tree order
+func ReduceCPU[T Number](data []T, op Op) T {
+    result := identity[T](op)
+    for _, v := range data {
+        switch op {
+        case Min:
+            result = min(result, v)
+        case Max:
+            result = max(result, v)
+        default:
+            result += v
+        }
+    }
+    return result
+}
+
+// CPU version of InclusiveScan
+func InclusiveScanCPU[T Number](data []T) []T {
+    result := make([]T, len(data))
+    var sum T
+    for i, v := range data {
+        sum += v
+        result[i] = sum
+    }
+    return result
+}
+
+// CPU version of ExclusiveScan
+func ExclusiveScanCPU[T Number](data []T) []T {
+    result := make([]T, len(data))
+    var sum T
+    for i, v := range data {
+        result[i] = sum
+        sum += v
+    }
+    return result
+}
+
+// CPU version of Compact
+func CompactCPU[T Number](data []T, keep []bool) []T {
+    if len(keep) != len(data) {
+        panic(fmt.Sprintf("CompactCPU: %d keep flags for %d elements", len(keep), len(data)))
+    }
+    result := make([]T, 0, len(data))
+    for i, v := range data {
+        if keep[i] {
+            result = append(result, v)
+        }
+    }
+    return result
+}
+
+// CPU version of Histogram, with the bin math done in float32 like on the GPU
+func HistogramCPU[T Number](data []T, bins int, lo, hi T) []uint32 {
+    if bins <= 0 {
+        panic(fmt.Sprintf("HistogramCPU: invalid bin count %d", bins))
+    }
+    result := make([]uint32, bins)
+    if !(lo < hi) {
+        return result
+    }
+    span := diff(hi, lo)
+    for _, v := range data {
+        if !(v >= lo && v < hi) {
+            continue
+        }
+        f := float32(diff(v, lo) / span)
+        bin := min(uint32(f*float32(bins)), uint32(bins-1))
+        result[bin]++
+    }
+    return result
+}
+
+// CPU version of Sort, ordering by the same keys as the GPU radix sort
+func SortCPU[T Number](data []T) []T {
+    result := slices.Clone(data)
+    slices.SortStableFunc(result, func(a, b T) int {
+        ka, kb := sortKey(a), sortKey(b)
+        switch {
+        case ka < kb:
+            return -1
+        case ka > kb:
+            return 1
+        }
+        return 0
+    })
+    return result
+}
+
+// Distance from b to a as a float32, integers are subtracted with wrap around like in GLSL
+func diff[T Number](a, b T) float32 {
+    switch v := any(a).(type) {
+    case int32:
+        return float32(uint32(v - any(b).(int32)))
+    case uint32:
+        return float32(v - any(b).(uint32))
+    case float32:
+        return float32(v - any(b).(float32))
+    }
+    return 0
+}
+
+// Maps a value to a uint32 with the same ordering, matching encode() in radix.comp
+func sortKey[T Number](value T) uint32 {
+    switch v := any(value).(type) {
+    case int32:
+        return uint32(v) ^ 0x80000000
+    case uint32:
+        return v
+    case float32:
+        bits := math.Float32bits(v)
+        if bits&0x80000000 != 0 {
+            return ^bits
+        }
+        return bits ^ 0x80000000
+    }
+    return 0
+}
@@ -0,0 +1,191 @@
+// GPU Parallel Primitive Operations
+package parallel
+
+import (
+	"fmt"
+)
+
+// Reduces (data) to a single value with the given operation
+//
+// Returns the identity of the operation for an empty slice (0, the largest T, or the smallest T)
+func (p *Primitives[T]) Reduce(data []T, op Op) T {
+    if len(data) == 0 {
+        return identity[T](op)
+    }
+
+    input := newBuffer(data, 0)
+    p.reduce.use()
+    p.reduce.setUint("uOp", uint32(op))
+    for n := len(data); n > 1; n = blocks(n) {
+        output := newBuffer[T](nil, blocks(n))
+        p.reduce.setUint("uCount", uint32(n))
+        bind(input, output)
+        dispatch(n)
+        deleteBuffers(input)
+        input = output
+    }
+
+    result := make([]T, 1)
+    readBuffer(input, result)
+    deleteBuffers(input)
+    return result[0]
+}
+
+// Returns the inclusive prefix sum of (data), element i is the sum of data[0] through data[i]
+func (p *Primitives[T]) InclusiveScan(data []T) []T {
+    result := make([]T, len(data))
+    if len(data) == 0 {
+        return result
+    }
+
+    buffer := newBuffer(data, 0)
+    scanBuffer(p.scan, p.add, buffer, len(data))
+    readBuffer(buffer, result)
+    deleteBuffers(buffer)
+    return result
+}
+
+// Returns the exclusive prefix sum of (data), element i is the sum of data[0] through data[i-1]
+func (p *Primitives[T]) ExclusiveScan(data []T) []T {
+    result := make([]T, len(data))
+    if len(data) == 0 {
+        return result
+    }
+
+    buffer := newBuffer(data, 0)
+    shifted := newBuffer[T](nil, len(data))
+    scanBuffer(p.scan, p.add, buffer, len(data))
+    p.shift.use()
+    p.shift.setUint("uCount", uint32(len(data)))
+    bind(buffer, shifted)
+    dispatch(len(data))
+    readBuffer(shifted, result)
+    deleteBuffers(buffer, shifted)
+    return result
+}
+
+// Returns the elements of (data) whose (keep) flag is set, in their original order
+func (p *Primitives[T]) Compact(data []T, keep []bool) []T {
+    if len(keep) != len(data) {
+        panic(fmt.Sprintf("Compact: %d keep flags for %d elements", len(keep), len(data)))
+    }
+    flags := make([]uint32, len(data))
+    total := 0
+    for i, k := range keep {
+        if k {
+            flags[i] = 1
+            total++
+        }
+    }
+    result := make([]T, total)
+    if total == 0 {
+        return result
+    }
+
+    input := newBuffer(data, 0)
+    flagBuffer := newBuffer(flags, 0)
+    positions := newBuffer(flags, 0)
+    output := newBuffer[T](nil, total)
+    scanBuffer(p.indexScan, p.indexAdd, positions, len(data))
+    p.compact.use()
+    p.compact.setUint("uCount", uint32(len(data)))
+    bind(input, flagBuffer, positions, output)
+    dispatch(len(data))
+    readBuffer(output, result)
+    deleteBuffers(input, flagBuffer, positions, output)
+    return result
+}
+
+// Counts the elements of (data) in [lo, hi) into (bins) equally sized bins
+//
+// Elements outside the range are not counted
+func (p *Primitives[T]) Histogram(data []T, bins int, lo, hi T) []uint32 {
+    if bins <= 0 {
+        panic(fmt.Sprintf("Histogram: invalid bin count %d", bins))
+    }
+    result := make([]uint32, bins)
+    if len(data) == 0 || !(lo < hi) {
+        return result
+    }
+
+    input := newBuffer(data, 0)
+    counts := newBuffer(result, 0)
+    p.histogram.use()
+    p.histogram.setUint("uCount", uint32(len(data)))
+    p.histogram.setUint("uBins", uint32(bins))
+    setValue(p.histogram, "uLo", lo)
+    setValue(p.histogram, "uHi", hi)
+    bind(input, counts)
+    dispatch(len(data))
+    readBuffer(counts, result)
+    deleteBuffers(input, counts)
+    return result
+}
+
+// Returns (data) sorted in ascending order with a stable LSD radix sort
+//
+// Floats are ordered by their bits, so -0 comes before +0 and NaNs end up at the ends
+func (p *Primitives[T]) Sort(data []T) []T {
+    result := make([]T, len(data))
+    n := len(data)
+    if n == 0 {
+        return result
+    }
+
+    keys := newBuffer(data, 0)
+    flags := newBuffer[uint32](nil, n)
+    sorted := newBuffer[uint32](nil, n)
+
+    p.radix.use()
+    p.radix.setUint("uCount", uint32(n))
+    p.radix.setUint("uStage", 0)
+    bind(keys)
+    dispatch(n)
+
+    for bit := uint32(0); bit < 32; bit++ {
+        p.radix.use()
+        p.radix.setUint("uStage", 1)
+        p.radix.setUint("uBit", bit)
+        bind(keys, flags)
+        dispatch(n)
+
+        scanBuffer(p.indexScan, p.indexAdd, flags, n)
+
+        p.radix.use()
+        p.radix.setUint("uStage", 2)
+        bind(keys, flags, sorted)
+        dispatch(n)
+        keys, sorted = sorted, keys
+    }
+
+    p.radix.setUint("uStage", 3)
+    bind(keys)
+    dispatch(n)
+
+    readBuffer(keys, result)
+    deleteBuffers(keys, flags, sorted)
+    return result
+}
+
+// Inclusive scan of the first (n) elements of a buffer in place
+//
+// Each block is scanned on its own, then the block totals are scanned the same way and added back on
+func scanBuffer(scan, add *program, buffer uint32, n int) {
+    groups := blocks(n)
+    sums := newBuffer[uint32](nil, groups)
+
+    scan.use()
+    scan.setUint("uCount", uint32(n))
+    bind(buffer, sums)
+    dispatch(n)
+
+    if groups > 1 {
+        scanBuffer(scan, add, sums, groups)
+        add.use()
+        add.setUint("uCount", uint32(n))
+        bind(buffer, sums)
+        dispatch(n)
+    }
+
+    deleteBuffers(sums)
+}
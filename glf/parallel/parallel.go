@@ -0,0 +1,236 @@
+// GPU Parallel Primitives
+package parallel
+
+import (
+	"embed"
+	"strings"
+	"unsafe"
+
+	"github.com/KCkingcollin/go-help-func/glf"
+	"github.com/go-gl/gl/v4.6-core/gl"
+)
+
+//go:embed shaders/*.comp
+var shaderFiles embed.FS
+
+// Number of invocations in every workgroup of the primitive kernels
+const workGroupSize = 256
+
+// Largest workgroup count GL guarantees per dispatch dimension
+const maxWorkGroups = 65535
+
+// Element types the primitives can work on
+type Number interface {
+    int32 | uint32 | float32
+}
+
+// Operation used by Reduce
+type Op uint32
+const (
+    Sum Op = iota
+    Min
+    Max
+)
+
+// Primitives holds the compiled compute programs for one element type
+//
+// A GL 4.3 context has to be current on the calling thread, e.g. one made by glf.InitSdlNoWindow or glf.InitShaderManager
+type Primitives[T Number] struct {
+    reduce      *program
+    scan        *program
+    add         *program
+    shift       *program
+    compact     *program
+    histogram   *program
+    indexScan   *program
+    indexAdd    *program
+    radix       *program
+}
+
+// A compiled kernel and its cached uniform locations
+type program struct {
+    id          uint32
+    uniforms    map[string]int32
+}
+
+// Compiles all the primitive kernels for the element type T on the current context
+//
+// Returns the compile or link error of the first kernel that fails, the ones before it are deleted
+func New[T Number]() (*Primitives[T], error) {
+    typed := typeDefines[T]()
+    index := typeDefines[uint32]()
+    p := &Primitives[T]{}
+    kernels := []struct {
+        prog    **program
+        name    string
+        defines string
+    }{
+        {&p.reduce, "reduce.comp", typed},
+        {&p.scan, "scan.comp", typed},
+        {&p.add, "add.comp", typed},
+        {&p.shift, "shift.comp", typed},
+        {&p.compact, "compact.comp", typed},
+        {&p.histogram, "histogram.comp", typed},
+        {&p.indexScan, "scan.comp", index},
+        {&p.indexAdd, "add.comp", index},
+        {&p.radix, "radix.comp", typed},
+    }
+    for _, kernel := range kernels {
+        prog, err := newProgram(kernel.name, kernel.defines)
+        if err != nil {
+            p.Cleanup()
+            return nil, err
+        }
+        *kernel.prog = prog
+    }
+    return p, nil
+}
+
+// Cleanup deletes every program owned by the Primitives
+func (p *Primitives[T]) Cleanup() {
+    for _, prog := range []*program{p.reduce, p.scan, p.add, p.shift, p.compact, p.histogram, p.indexScan, p.indexAdd, p.radix} {
+        if prog != nil {
+            gl.DeleteProgram(prog.id)
+        }
+    }
+}
+
+// Returns the GLSL defines that specialize the kernels for T
+func typeDefines[T Number]() string {
+    var defines string
+    switch any(*new(T)).(type) {
+    case int32:
+        defines = "#define T int\n" +
+            "#define T_MIN int(0x80000000u)\n" +
+            "#define T_MAX int(0x7fffffffu)\n" +
+            "#define DIFF(a, b) float(uint((a) - (b)))\n" +
+            "#define KEY_MODE 1\n"
+    case uint32:
+        defines = "#define T uint\n" +
+            "#define T_MIN 0u\n" +
+            "#define T_MAX 0xffffffffu\n" +
+            "#define DIFF(a, b) float((a) - (b))\n" +
+            "#define KEY_MODE 0\n"
+    case float32:
+        defines = "#define T float\n" +
+            "#define T_MIN uintBitsToFloat(0xff800000u)\n" +
+            "#define T_MAX uintBitsToFloat(0x7f800000u)\n" +
+            "#define DIFF(a, b) float((a) - (b))\n" +
+            "#define KEY_MODE 2\n"
+    }
+    return defines +
+        "#define WG_SIZE 256\n" +
+        "#define OP_SUM 0u\n" +
+        "#define OP_MIN 1u\n" +
+        "#define OP_MAX 2u\n"
+}
+
+// Loads an embedded kernel, puts the defines right after its #version line, and compiles it
+func newProgram(name, defines string) (*program, error) {
+    source, err := shaderFiles.ReadFile("shaders/" + name)
+    if err != nil {
+        return nil, err
+    }
+    version, body, _ := strings.Cut(string(source), "\n")
+    id, err := glf.TryCreateComputeShader(version+"\n"+defines+body, name)
+    if err != nil {
+        return nil, err
+    }
+    return &program{id: id, uniforms: make(map[string]int32)}, nil
+}
+
+// Makes the program current
+func (prog *program) use() {
+    gl.UseProgram(prog.id)
+}
+
+// Gets the location of a uniform, caching it for the next call
+func (prog *program) location(name string) int32 {
+    loc, ok := prog.uniforms[name]
+    if !ok {
+        loc = gl.GetUniformLocation(prog.id, gl.Str(name+"\x00"))
+        prog.uniforms[name] = loc
+    }
+    return loc
+}
+
+// Sets a uint uniform on the program, the program has to be in use
+func (prog *program) setUint(name string, value uint32) {
+    gl.Uniform1ui(prog.location(name), value)
+}
+
+// Sets a uniform of the element type T on the program, the program has to be in use
+func setValue[T Number](prog *program, name string, value T) {
+    switch v := any(value).(type) {
+    case int32:
+        gl.Uniform1i(prog.location(name), v)
+    case uint32:
+        gl.Uniform1ui(prog.location(name), v)
+    case float32:
+        gl.Uniform1f(prog.location(name), v)
+    }
+}
+
+// Returns a pointer to the first element of a slice, or nil if it's empty
+func pointer[E any](data []E) unsafe.Pointer {
+    if len(data) == 0 {
+        return nil
+    }
+    return unsafe.Pointer(&data[0])
+}
+
+// Creates a shader storage buffer holding (data), or (count) undefined elements if data is nil
+func newBuffer[E Number](data []E, count int) uint32 {
+    buffer := glf.GenBindBuffers(gl.SHADER_STORAGE_BUFFER)
+    size := max(count, 1) * 4
+    if data != nil {
+        size = max(len(data), 1) * 4
+    }
+    gl.BufferData(gl.SHADER_STORAGE_BUFFER, size, nil, gl.DYNAMIC_COPY)
+    if len(data) > 0 {
+        gl.BufferSubData(gl.SHADER_STORAGE_BUFFER, 0, len(data)*4, pointer(data))
+    }
+    gl.BindBuffer(gl.SHADER_STORAGE_BUFFER, 0)
+    return buffer
+}
+
+// Reads the start of a buffer back into (out)
+func readBuffer[E Number](buffer uint32, out []E) {
+    if len(out) == 0 {
+        return
+    }
+    gl.BindBuffer(gl.SHADER_STORAGE_BUFFER, buffer)
+    gl.GetBufferSubData(gl.SHADER_STORAGE_BUFFER, 0, len(out)*4, pointer(out))
+    gl.BindBuffer(gl.SHADER_STORAGE_BUFFER, 0)
+}
+
+// Deletes all the given buffers
+func deleteBuffers(buffers ...uint32) {
+    gl.DeleteBuffers(int32(len(buffers)), &buffers[0])
+}
+
+// Binds the buffers to the shader storage bindings 0, 1, 2... in order
+func bind(buffers ...uint32) {
+    for i, buffer := range buffers {
+        gl.BindBufferBase(gl.SHADER_STORAGE_BUFFER, uint32(i), buffer)
+    }
+}
+
+// Returns the number of workgroups needed to cover (n) elements
+func blocks(n int) int {
+    return (n + workGroupSize - 1) / workGroupSize
+}
+
+// Dispatches enough workgroups of the program in use to cover (n) elements and waits on the writes
+//
+// Counts past the per dimension limit are folded into a 2D grid, which the kernels flatten back
+func dispatch(n int) {
+    groups := blocks(n)
+    if groups == 0 {
+        return
+    }
+    x := min(groups, maxWorkGroups)
+    y := (groups + x - 1) / x
+    gl.DispatchCompute(uint32(x), uint32(y), 1)
+    gl.MemoryBarrier(gl.SHADER_STORAGE_BARRIER_BIT | gl.BUFFER_UPDATE_BARRIER_BIT)
+}
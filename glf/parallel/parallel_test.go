@@ -0,0 +1,102 @@
+package parallel
+
+import (
+	"fmt"
+	"testing"
+
+	"github.com/KCkingcollin/go-help-func/glf"
+	"github.com/KCkingcollin/go-help-func/glf/glftest"
+)
+
+// Sizes around the workgroup size, and past 256 * 256 so the scans and reductions take more than two levels
+var testSizes = []int{0, 1, 255, 256, 257, 1000, 70000}
+
+// Returns (n) pseudo random values from (next), the same every run
+func testData[T Number](n int, next func(r uint32) T) []T {
+    data := make([]T, n)
+    r := uint32(12345)
+    for i := range data {
+        r = r*1664525 + 1013904223
+        data[i] = next(r >> 8)
+    }
+    return data
+}
+
+// Compiles the primitives for T on the shared test context, skipping when there isn't one
+func testPrimitives[T Number](t *testing.T) (*Primitives[T], func(func())) {
+    t.Helper()
+    ctx := glftest.Context(t)
+    var p *Primitives[T]
+    var err error
+    ctx.Do(func() {
+        p, err = New[T]()
+    })
+    if err != nil {
+        t.Fatal(err)
+    }
+    t.Cleanup(func() {
+        ctx.Do(p.Cleanup)
+    })
+    return p, ctx.Do
+}
+
+func expectEqual[T glf.ShaderData](t *testing.T, name string, got, want []T) {
+    t.Helper()
+    if len(got) != len(want) {
+        t.Errorf("%s: got %d values, want %d", name, len(got), len(want))
+        return
+    }
+    if mismatches := glftest.Compare(got, want, glftest.Tolerance{}); len(mismatches) > 0 {
+        t.Errorf("%s: %d values differ from the CPU reference\n%s", name, len(mismatches), glftest.FormatMismatches(mismatches, 10))
+    }
+}
+
+// Runs every primitive on the GPU and on its CPU reference, the values are kept small enough that
+// float sums are exact in any order
+func testOps[T Number](t *testing.T, next func(r uint32) T, lo, hi T) {
+    p, do := testPrimitives[T](t)
+    for _, n := range testSizes {
+        t.Run(fmt.Sprint(n), func(t *testing.T) {
+            data := testData(n, next)
+            keep := make([]bool, n)
+            for i := range keep {
+                keep[i] = (i*7)%3 == 0
+            }
+
+            var reduced [3]T
+            var inclusive, exclusive, compacted, sorted []T
+            var histogram []uint32
+            do(func() {
+                for op := Sum; op <= Max; op++ {
+                    reduced[op] = p.Reduce(data, op)
+                }
+                inclusive = p.InclusiveScan(data)
+                exclusive = p.ExclusiveScan(data)
+                compacted = p.Compact(data, keep)
+                histogram = p.Histogram(data, 16, lo, hi)
+                sorted = p.Sort(data)
+            })
+
+            for op, name := range []string{"sum", "min", "max"} {
+                expectEqual(t, "reduce "+name, reduced[op:op+1], []T{ReduceCPU(data, Op(op))})
+            }
+            expectEqual(t, "inclusive scan", inclusive, InclusiveScanCPU(data))
+            expectEqual(t, "exclusive scan", exclusive, ExclusiveScanCPU(data))
+            expectEqual(t, "compact", compacted, CompactCPU(data, keep))
+            expectEqual(t, "histogram", histogram, HistogramCPU(data, 16, lo, hi))
+            expectEqual(t, "sort", sorted, SortCPU(data))
+        })
+    }
+}
+
+func TestInt32(t *testing.T) {
+    testOps(t, func(r uint32) int32 { return int32(r%201) - 100 }, -100, 101)
+}
+
+func TestUint32(t *testing.T) {
+    testOps(t, func(r uint32) uint32 { return r % 1000 }, 0, 1000)
+}
+
+func TestFloat32(t *testing.T) {
+    testOps(t, func(r uint32) float32 { return float32(int32(r%401)-200) / 4 }, -50, 50.25)
+}
@@ -0,0 +1,182 @@
+// Texture Pixel Conversion GL Helper Functions
+package glf
+
+import (
+	"image"
+	"image/color"
+	"image/draw"
+
+	"github.com/go-gl/gl/v4.6-core/gl"
+)
+
+// Pixel data ready for glTexImage2D, rows from the top with no padding
+type texturePixels struct {
+    data    any     // []uint8 or []float32
+    format  uint32  // gl.RGBA or gl.RED
+    xtype   uint32  // gl.UNSIGNED_BYTE or gl.FLOAT
+}
+
+// Returns true for internal formats with only a red channel, which grayscale images can be uploaded to as is
+func isSingleChannel(internalFormat int32) bool {
+    switch internalFormat {
+    case gl.RED, gl.R8, gl.R8_SNORM, gl.R16, gl.R16_SNORM, gl.R16F, gl.R32F:
+        return true
+    }
+    return false
+}
+
+// Converts an image to pixel data GL can take, with straight alpha unless (premultiply) is set
+//
+// The common decoder outputs, paletted included, get byte paths without any per pixel interface calls, and NRGBA or RGBA with
+// the matching alpha and no row padding go to GL without being copied. Other 8 bit images are drawn into
+// an NRGBA first, and 16 bit ones go through float32 so they keep their precision.
+func imagePixels(img image.Image, singleChannel, premultiply bool) texturePixels {
+    bounds := img.Bounds()
+    w, h := bounds.Dx(), bounds.Dy()
+
+    switch img := img.(type) {
+    case *FloatImage:
+        return texturePixels{floatImagePixels(img, premultiply), gl.RGBA, gl.FLOAT}
+    case *image.NRGBA:
+        pixels := packedRows(img.Pix[img.PixOffset(bounds.Min.X, bounds.Min.Y):], img.Stride, 4*w, h, premultiply)
+        if premultiply {
+            premultiplyBytes(pixels)
+        }
+        return texturePixels{pixels, gl.RGBA, gl.UNSIGNED_BYTE}
+    case *image.RGBA:
+        pixels := packedRows(img.Pix[img.PixOffset(bounds.Min.X, bounds.Min.Y):], img.Stride, 4*w, h, !premultiply)
+        if !premultiply {
+            unpremultiplyBytes(pixels)
+        }
+        return texturePixels{pixels, gl.RGBA, gl.UNSIGNED_BYTE}
+    case *image.Gray:
+        gray := packedRows(img.Pix[img.PixOffset(bounds.Min.X, bounds.Min.Y):], img.Stride, w, h, false)
+        if singleChannel {
+            return texturePixels{gray, gl.RED, gl.UNSIGNED_BYTE}
+        }
+        pixels := make([]uint8, 4*w*h)
+        for i, value := range gray {
+            pixels[i*4], pixels[i*4+1], pixels[i*4+2], pixels[i*4+3] = value, value, value, 0xff
+        }
+        return texturePixels{pixels, gl.RGBA, gl.UNSIGNED_BYTE}
+    case *image.Paletted:
+        var palette [256][4]uint8
+        for i, c := range img.Palette {
+            nrgba := color.NRGBAModel.Convert(c).(color.NRGBA)
+            palette[i] = [4]uint8{nrgba.R, nrgba.G, nrgba.B, nrgba.A}
+        }
+        if premultiply {
+            for i := range palette {
+                premultiplyBytes(palette[i][:])
+            }
+        }
+        pixels := make([]uint8, 0, 4*w*h)
+        for y := bounds.Min.Y; y < bounds.Max.Y; y++ {
+            row := img.Pix[img.PixOffset(bounds.Min.X, y):][:w]
+            for _, index := range row {
+                pixels = append(pixels, palette[index][:]...)
+            }
+        }
+        return texturePixels{pixels, gl.RGBA, gl.UNSIGNED_BYTE}
+    case *image.YCbCr:
+        pixels := make([]uint8, 4*w*h)
+        i := 0
+        for y := bounds.Min.Y; y < bounds.Max.Y; y++ {
+            for x := bounds.Min.X; x < bounds.Max.X; x++ {
+                yi, ci := img.YOffset(x, y), img.COffset(x, y)
+                pixels[i], pixels[i+1], pixels[i+2] = color.YCbCrToRGB(img.Y[yi], img.Cb[ci], img.Cr[ci])
+                pixels[i+3] = 0xff
+                i += 4
+            }
+        }
+        return texturePixels{pixels, gl.RGBA, gl.UNSIGNED_BYTE}
+    }
+
+    switch img.ColorModel() {
+    case color.RGBA64Model, color.NRGBA64Model, color.Gray16Model, color.Alpha16Model:
+        return texturePixels{colorFloatPixels(img, premultiply), gl.RGBA, gl.FLOAT}
+    }
+    nrgba := image.NewNRGBA(image.Rect(0, 0, w, h))
+    draw.Draw(nrgba, nrgba.Rect, img, bounds.Min, draw.Src)
+    return imagePixels(nrgba, singleChannel, premultiply)
+}
+
+// Returns (h) rows of (rowSize) bytes (stride) apart as one slice, only copying if they're padded or (copyRows) is set
+func packedRows(pix []uint8, stride, rowSize, h int, copyRows bool) []uint8 {
+    if stride == rowSize && !copyRows {
+        return pix[:rowSize*h]
+    }
+    pixels := make([]uint8, 0, rowSize*h)
+    for y := 0; y < h; y++ {
+        pixels = append(pixels, pix[y*stride:y*stride+rowSize]...)
+    }
+    return pixels
+}
+
+// Multiplies the color of straight RGBA bytes by their alpha, in place
+func premultiplyBytes(pixels []uint8) {
+    for i := 0; i+3 < len(pixels); i += 4 {
+        a := uint32(pixels[i+3])
+        if a == 0xff {
+            continue
+        }
+        pixels[i] = uint8((uint32(pixels[i])*a + 127) / 255)
+        pixels[i+1] = uint8((uint32(pixels[i+1])*a + 127) / 255)
+        pixels[i+2] = uint8((uint32(pixels[i+2])*a + 127) / 255)
+    }
+}
+
+// Divides the color of premultiplied RGBA bytes by their alpha, in place
+func unpremultiplyBytes(pixels []uint8) {
+    for i := 0; i+3 < len(pixels); i += 4 {
+        a := uint32(pixels[i+3])
+        if a == 0xff || a == 0 {
+            continue
+        }
+        pixels[i] = uint8(min((uint32(pixels[i])*255+a/2)/a, 0xff))
+        pixels[i+1] = uint8(min((uint32(pixels[i+1])*255+a/2)/a, 0xff))
+        pixels[i+2] = uint8(min((uint32(pixels[i+2])*255+a/2)/a, 0xff))
+    }
+}
+
+// Returns the FloatImage's pixels as packed rows, premultiplied if asked, without copying when it can
+func floatImagePixels(img *FloatImage, premultiply bool) []float32 {
+    bounds := img.Bounds()
+    rowSize := 4 * bounds.Dx()
+    if img.Stride == rowSize && !premultiply {
+        start := img.PixOffset(bounds.Min.X, bounds.Min.Y)
+        return img.Pix[start : start+rowSize*bounds.Dy()]
+    }
+    pixels := make([]float32, 0, rowSize*bounds.Dy())
+    for y := bounds.Min.Y; y < bounds.Max.Y; y++ {
+        row := img.PixOffset(bounds.Min.X, y)
+        pixels = append(pixels, img.Pix[row:row+rowSize]...)
+    }
+    if premultiply {
+        for i := 0; i+3 < len(pixels); i += 4 {
+            pixels[i] *= pixels[i+3]
+            pixels[i+1] *= pixels[i+3]
+            pixels[i+2] *= pixels[i+3]
+        }
+    }
+    return pixels
+}
+
+// Returns any image as RGBA float32s from 0 to 1, the slow path that keeps 16 bit precision
+func colorFloatPixels(img image.Image, premultiply bool) []float32 {
+    bounds := img.Bounds()
+    pixels := make([]float32, 0, bounds.Dx()*bounds.Dy()*4)
+    for y := bounds.Min.Y; y < bounds.Max.Y; y++ {
+        for x := bounds.Min.X; x < bounds.Max.X; x++ {
+            var r, g, b, a uint32
+            if premultiply {
+                r, g, b, a = img.At(x, y).RGBA()
+            } else {
+                c := color.NRGBA64Model.Convert(img.At(x, y)).(color.NRGBA64)
+                r, g, b, a = uint32(c.R), uint32(c.G), uint32(c.B), uint32(c.A)
+            }
+            pixels = append(pixels, float32(r)/65535.0, float32(g)/65535.0, float32(b)/65535.0, float32(a)/65535.0)
+        }
+    }
+    return pixels
+}
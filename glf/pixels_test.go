@@ -0,0 +1,134 @@
+package glf
+
+import (
+	"image"
+	"image/color"
+	"testing"
+
+	"github.com/go-gl/gl/v4.6-core/gl"
+)
+
+// Hides the concrete type of an image, so imagePixels has to take the generic At() path
+type opaqueImage struct {
+    image.Image
+}
+
+func TestImagePixelsKeepsAlphaStraight(t *testing.T) {
+    straight := color.NRGBA{200, 100, 50, 128}
+    nrgba := image.NewNRGBA(image.Rect(0, 0, 2, 1))
+    nrgba.SetNRGBA(0, 0, straight)
+    nrgba.SetNRGBA(1, 0, color.NRGBA{10, 20, 30, 255})
+    rgba := image.NewRGBA(nrgba.Rect)
+    rgba.Set(0, 0, straight)
+    rgba.Set(1, 0, color.NRGBA{10, 20, 30, 255})
+
+    for name, img := range map[string]image.Image{
+        "nrgba":   nrgba,
+        "rgba":    rgba,
+        "generic": opaqueImage{nrgba},
+    } {
+        pixels := imagePixels(img, false, false)
+        data, ok := pixels.data.([]uint8)
+        if !ok || pixels.xtype != gl.UNSIGNED_BYTE {
+            t.Fatalf("%s: pixels aren't bytes", name)
+        }
+        got := color.NRGBA{data[0], data[1], data[2], data[3]}
+        // The RGBA image stores 100, 50, 25 premultiplied, which can't give back the exact straight values
+        if diff(got.R, straight.R) > 1 || diff(got.G, straight.G) > 1 || diff(got.B, straight.B) > 1 || got.A != straight.A {
+            t.Errorf("%s: translucent pixel uploads as %v, want straight %v", name, got, straight)
+        }
+        if opaque := (color.NRGBA{data[4], data[5], data[6], data[7]}); opaque != (color.NRGBA{10, 20, 30, 255}) {
+            t.Errorf("%s: opaque pixel uploads as %v", name, opaque)
+        }
+
+        premultiplied := imagePixels(img, false, true).data.([]uint8)
+        if got := (color.NRGBA{premultiplied[0], premultiplied[1], premultiplied[2], premultiplied[3]}); got != (color.NRGBA{100, 50, 25, 128}) {
+            t.Errorf("%s: premultiplied pixel uploads as %v, want {100 50 25 128}", name, got)
+        }
+    }
+}
+
+func TestImagePixelsDoesntChangeTheImage(t *testing.T) {
+    nrgba := image.NewNRGBA(image.Rect(0, 0, 1, 1))
+    nrgba.SetNRGBA(0, 0, color.NRGBA{200, 100, 50, 128})
+    imagePixels(nrgba, false, true)
+    if got := nrgba.NRGBAAt(0, 0); got != (color.NRGBA{200, 100, 50, 128}) {
+        t.Errorf("premultiplying the upload changed the image to %v", got)
+    }
+}
+
+func diff(a, b uint8) uint8 {
+    if a > b {
+        return a - b
+    }
+    return b - a
+}
+
+const benchmarkSize = 1024
+
+// Fills an NRGBA with a translucent gradient, so the alpha paths have work to do
+func benchmarkNRGBA() *image.NRGBA {
+    img := image.NewNRGBA(image.Rect(0, 0, benchmarkSize, benchmarkSize))
+    for y := 0; y < benchmarkSize; y++ {
+        for x := 0; x < benchmarkSize; x++ {
+            img.SetNRGBA(x, y, color.NRGBA{uint8(x), uint8(y), uint8(x ^ y), uint8(x + y)})
+        }
+    }
+    return img
+}
+
+func benchmarkImagePixels(b *testing.B, img image.Image) {
+    b.SetBytes(int64(4 * benchmarkSize * benchmarkSize))
+    b.ResetTimer()
+    for i := 0; i < b.N; i++ {
+        imagePixels(img, false, false)
+    }
+}
+
+// The per pixel At().RGBA() float conversion every image went through before the byte paths
+func BenchmarkImagePixelsFloatBaseline(b *testing.B) {
+    img := benchmarkNRGBA()
+    b.SetBytes(int64(4 * benchmarkSize * benchmarkSize))
+    b.ResetTimer()
+    for i := 0; i < b.N; i++ {
+        colorFloatPixels(img, true)
+    }
+}
+
+func BenchmarkImagePixelsNRGBA(b *testing.B) {
+    benchmarkImagePixels(b, benchmarkNRGBA())
+}
+
+func BenchmarkImagePixelsRGBA(b *testing.B) {
+    src := benchmarkNRGBA()
+    img := image.NewRGBA(src.Rect)
+    for y := 0; y < benchmarkSize; y++ {
+        for x := 0; x < benchmarkSize; x++ {
+            img.Set(x, y, src.At(x, y))
+        }
+    }
+    benchmarkImagePixels(b, img)
+}
+
+func BenchmarkImagePixelsGray(b *testing.B) {
+    img := image.NewGray(image.Rect(0, 0, benchmarkSize, benchmarkSize))
+    for i := range img.Pix {
+        img.Pix[i] = uint8(i)
+    }
+    benchmarkImagePixels(b, img)
+}
+
+func BenchmarkImagePixelsYCbCr(b *testing.B) {
+    img := image.NewYCbCr(image.Rect(0, 0, benchmarkSize, benchmarkSize), image.YCbCrSubsampleRatio420)
+    for i := range img.Y {
+        img.Y[i] = uint8(i)
+    }
+    for i := range img.Cb {
+        img.Cb[i], img.Cr[i] = uint8(i), uint8(i>>3)
+    }
+    benchmarkImagePixels(b, img)
+}
+
+func BenchmarkImagePixelsGeneric(b *testing.B) {
+    benchmarkImagePixels(b, opaqueImage{benchmarkNRGBA()})
+}
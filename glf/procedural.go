@@ -0,0 +1,545 @@
+// Procedural Texture GL Helper Functions
+package glf
+
+import (
+	"errors"
+	"fmt"
+	"image"
+	"image/color"
+	"math"
+	"math/bits"
+	"runtime"
+	"strings"
+	"sync"
+
+	"github.com/go-gl/gl/v4.6-core/gl"
+)
+
+// What a Pattern generates
+type PatternKind int
+
+const (
+    PatternSolid        PatternKind = iota
+    PatternCheckerboard
+    PatternGradient
+    PatternUVGrid
+    PatternPerlin
+    PatternSimplex
+    PatternWorley
+)
+
+func (kind PatternKind) String() string {
+    switch kind {
+    case PatternSolid:
+        return "solid"
+    case PatternCheckerboard:
+        return "checkerboard"
+    case PatternGradient:
+        return "gradient"
+    case PatternUVGrid:
+        return "uv grid"
+    case PatternPerlin:
+        return "perlin"
+    case PatternSimplex:
+        return "simplex"
+    case PatternWorley:
+        return "worley"
+    }
+    return fmt.Sprintf("PatternKind(%d)", int(kind))
+}
+
+// Pattern describes a texture that's generated instead of loaded, either on the CPU with Image, or with a
+// compute pass with a ProceduralGenerator for large sizes
+//
+// The two give the same pixels, to within float precision, so a pattern can be prototyped on one and
+// moved to the other. Noise is fractal (fBm), each octave (Lacunarity) times the frequency and
+// (Persistence) times the amplitude of the one before, and goes from Colors[0] at its lowest to
+// Colors[1] at its highest.
+type Pattern struct {
+    Kind        PatternKind
+    Colors      [2]color.Color  // Solid uses the first, checkerboard squares, gradient ends and noise range, black and white when nil
+    Cells       int             // Squares across a checkerboard, or across each axis of a UV grid, 8 when 0
+    Vertical    bool            // Gradient runs from the top row to the bottom one, instead of left to right
+    Frequency   float64         // Noise cells across the width of the texture, 4 when 0
+    Octaves     int             // 1 when 0
+    Persistence float64         // 0.5 when 0
+    Lacunarity  float64         // 2 when 0
+    Seed        uint32
+    Tileable    bool            // Noise wraps at the edges, with the frequencies rounded to whole cells for perlin and worley
+}
+
+// Creates a pattern with every pixel (c)
+func SolidPattern(c color.Color) Pattern {
+    return Pattern{Kind: PatternSolid, Colors: [2]color.Color{c, c}}
+}
+
+// Creates a checkerboard pattern with (cells) squares across its width, starting with (a) in the top left
+func CheckerboardPattern(cells int, a, b color.Color) Pattern {
+    return Pattern{Kind: PatternCheckerboard, Colors: [2]color.Color{a, b}, Cells: cells}
+}
+
+// Creates a linear gradient pattern from (from) to (to), left to right or top to bottom if (vertical)
+func GradientPattern(from, to color.Color, vertical bool) Pattern {
+    return Pattern{Kind: PatternGradient, Colors: [2]color.Color{from, to}, Vertical: vertical}
+}
+
+// Creates a UV test grid with (cells) cells along each axis
+//
+// Red rises with U from left to right and green with V from the first row to the last, which is V in GL
+// texture coordinates since the first row is the one uploaded first. Blue alternates like a
+// checkerboard, and every cell has a white line on its top and left edges.
+func UVGridPattern(cells int) Pattern {
+    return Pattern{Kind: PatternUVGrid, Cells: cells}
+}
+
+// Creates a tileable black to white noise pattern of (kind) PatternPerlin, PatternSimplex or PatternWorley
+func NoisePattern(kind PatternKind, frequency float64, octaves int, seed uint32) Pattern {
+    return Pattern{Kind: kind, Frequency: frequency, Octaves: octaves, Seed: seed, Tileable: true}
+}
+
+// Returns the pattern with every unset field filled in with its default
+func (pattern Pattern) withDefaults() Pattern {
+    if pattern.Colors[0] == nil {
+        pattern.Colors[0] = color.Black
+    }
+    if pattern.Colors[1] == nil {
+        pattern.Colors[1] = color.White
+    }
+    if pattern.Cells <= 0 {
+        pattern.Cells = 8
+    }
+    if pattern.Frequency <= 0 {
+        pattern.Frequency = 4
+    }
+    if pattern.Octaves <= 0 {
+        pattern.Octaves = 1
+    }
+    if pattern.Persistence == 0 {
+        pattern.Persistence = 0.5
+    }
+    if pattern.Lacunarity == 0 {
+        pattern.Lacunarity = 2
+    }
+    return pattern
+}
+
+// Generates the pattern on the CPU as a (width) x (height) image, split across runtime.NumCPU() goroutines
+//
+// The image is a FloatImage, so noise keeps its precision when it's uploaded, and it converts to
+// any other image type with image/draw.
+func (pattern Pattern) Image(width, height int) *FloatImage {
+    pattern = pattern.withDefaults()
+    img := NewFloatImage(image.Rect(0, 0, max(width, 0), max(height, 0)))
+    if width <= 0 || height <= 0 {
+        return img
+    }
+    a := FloatColorModel.Convert(pattern.Colors[0]).(FloatColor)
+    b := FloatColorModel.Convert(pattern.Colors[1]).(FloatColor)
+
+    workers := min(runtime.NumCPU(), height)
+    var wg sync.WaitGroup
+    for worker := 0; worker < workers; worker++ {
+        wg.Add(1)
+        go func(first int) {
+            defer wg.Done()
+            for y := first; y < height; y += workers {
+                for x := 0; x < width; x++ {
+                    c := pattern.at(x, y, width, height, a, b)
+                    i := img.PixOffset(x, y)
+                    img.Pix[i], img.Pix[i+1], img.Pix[i+2], img.Pix[i+3] = c.R, c.G, c.B, c.A
+                }
+            }
+        }(worker)
+    }
+    wg.Wait()
+    return img
+}
+
+// Generates the pattern on the CPU and uploads it as a 2D texture, stored and sampled according to (opts)
+func (pattern Pattern) Texture(width, height int, opts TextureOptions) (uint32, error) {
+    if width <= 0 || height <= 0 {
+        return 0, fmt.Errorf("invalid procedural texture size %dx%d", width, height)
+    }
+    return NewTextureFromImage(pattern.Image(width, height), opts)
+}
+
+// Returns the color of pixel (x, y), (pattern) has its defaults and (a) and (b) are its colors
+//
+// Kept in step with procedural.comp.
+func (pattern Pattern) at(x, y, width, height int, a, b FloatColor) FloatColor {
+    u := (float64(x) + 0.5) / float64(width)
+    v := (float64(y) + 0.5) / float64(height)
+    switch pattern.Kind {
+    case PatternCheckerboard:
+        cells := pattern.Cells
+        if (x*cells/width+y*cells/width)%2 == 1 {
+            return b
+        }
+        return a
+    case PatternGradient:
+        if pattern.Vertical {
+            return mixColor(a, b, float32(v))
+        }
+        return mixColor(a, b, float32(u))
+    case PatternUVGrid:
+        cells := pattern.Cells
+        if x*cells%width < cells || y*cells%height < cells {
+            return FloatColor{1, 1, 1, 1}
+        }
+        blue := float32(0.25)
+        if (x*cells/width+y*cells/height)%2 == 1 {
+            blue = 0.75
+        }
+        return FloatColor{float32(u), float32(v), blue, 1}
+    case PatternPerlin, PatternSimplex, PatternWorley:
+        return mixColor(a, b, float32(pattern.noise(u, v, float64(height)/float64(width))))
+    }
+    return a
+}
+
+// Interpolates straight alpha colors, (t) of the way from (a) to (b)
+func mixColor(a, b FloatColor, t float32) FloatColor {
+    return FloatColor{a.R + (b.R-a.R)*t, a.G + (b.G-a.G)*t, a.B + (b.B-a.B)*t, a.A + (b.A-a.A)*t}
+}
+
+// Returns the fractal noise at texture coordinate (u, v) mapped to 0-1, (aspect) is height over width
+func (pattern Pattern) noise(u, v, aspect float64) float64 {
+    sum, total, amplitude, scale := 0.0, 0.0, 1.0, 1.0
+    for octave := 0; octave < pattern.Octaves; octave++ {
+        periodX, periodY := pattern.Frequency*scale, pattern.Frequency*scale*aspect
+        if pattern.Tileable && pattern.Kind != PatternSimplex {
+            periodX, periodY = max(math.Floor(periodX+0.5), 1), max(math.Floor(periodY+0.5), 1)
+        }
+        wrapX, wrapY := 0, 0
+        if pattern.Tileable {
+            wrapX, wrapY = int(periodX), int(periodY)
+        }
+        seed := pattern.Seed + uint32(octave)
+
+        var n float64
+        switch {
+        case pattern.Kind == PatternPerlin:
+            n = perlinNoise(u*periodX, v*periodY, wrapX, wrapY, seed)
+        case pattern.Kind == PatternWorley:
+            n = worleyNoise(u*periodX, v*periodY, wrapX, wrapY, seed)
+        case pattern.Tileable:
+            // A torus in 4D, each axis a circle as many cells around as the period, wraps without seams
+            radiusX, radiusY := periodX/(2*math.Pi), periodY/(2*math.Pi)
+            angleX, angleY := 2*math.Pi*u, 2*math.Pi*v
+            n = simplexNoise4(radiusX*math.Cos(angleX), radiusX*math.Sin(angleX),
+                radiusY*math.Cos(angleY), radiusY*math.Sin(angleY), seed)
+        default:
+            n = simplexNoise2(u*periodX, v*periodY, seed)
+        }
+        sum += n * amplitude
+        total += amplitude
+        amplitude *= pattern.Persistence
+        scale *= pattern.Lacunarity
+    }
+    return min(max(0.5+0.5*sum/total, 0), 1)
+}
+
+// Integer hash with good avalanche, the same in GLSL so the CPU and GPU patterns match
+func hashUint(x uint32) uint32 {
+    x ^= x >> 16
+    x *= 0x7feb352d
+    x ^= x >> 15
+    x *= 0x846ca68b
+    x ^= x >> 16
+    return x
+}
+
+// Hashes a lattice point, negative coordinates wrap around like a GLSL uint() of an int
+func hashLattice(seed uint32, coords ...int) uint32 {
+    h := hashUint(seed)
+    for _, c := range coords {
+        h = hashUint(uint32(int32(c)) ^ h)
+    }
+    return h
+}
+
+// Wraps lattice coordinate (i) into 0 to (period)-1, or leaves it alone if (period) is 0
+func wrapLattice(i, period int) int {
+    if period <= 0 {
+        return i
+    }
+    return (i%period + period) % period
+}
+
+// Dot product of (x, y) with one of eight gradients picked by (h)
+func gradient2(h uint32, x, y float64) float64 {
+    switch h & 7 {
+    case 0:
+        return x + y
+    case 1:
+        return -x + y
+    case 2:
+        return x - y
+    case 3:
+        return -x - y
+    case 4:
+        return x
+    case 5:
+        return -x
+    case 6:
+        return y
+    }
+    return -y
+}
+
+// Classic 2D gradient noise in about -1 to 1, repeating every (periodX) and (periodY) cells unless they're 0
+func perlinNoise(x, y float64, periodX, periodY int, seed uint32) float64 {
+    ix, iy := int(math.Floor(x)), int(math.Floor(y))
+    fx, fy := x-float64(ix), y-float64(iy)
+    x0, x1 := wrapLattice(ix, periodX), wrapLattice(ix+1, periodX)
+    y0, y1 := wrapLattice(iy, periodY), wrapLattice(iy+1, periodY)
+
+    n00 := gradient2(hashLattice(seed, x0, y0), fx, fy)
+    n10 := gradient2(hashLattice(seed, x1, y0), fx-1, fy)
+    n01 := gradient2(hashLattice(seed, x0, y1), fx, fy-1)
+    n11 := gradient2(hashLattice(seed, x1, y1), fx-1, fy-1)
+
+    fade := func(t float64) float64 {
+        return t * t * t * (t*(t*6-15) + 10)
+    }
+    sx, sy := fade(fx), fade(fy)
+    nx0 := n00 + (n10-n00)*sx
+    nx1 := n01 + (n11-n01)*sx
+    return nx0 + (nx1-nx0)*sy
+}
+
+// Cellular noise in -1 to 1, the distance to the nearest jittered feature point mapped from 0-1 cells,
+// repeating every (periodX) and (periodY) cells unless they're 0
+func worleyNoise(x, y float64, periodX, periodY int, seed uint32) float64 {
+    ix, iy := int(math.Floor(x)), int(math.Floor(y))
+    nearest := 2.0
+    for dy := -1; dy <= 1; dy++ {
+        for dx := -1; dx <= 1; dx++ {
+            h := hashLattice(seed, wrapLattice(ix+dx, periodX), wrapLattice(iy+dy, periodY))
+            px := float64(ix+dx) + float64(h&0xffff)/65536
+            py := float64(iy+dy) + float64(h>>16)/65536
+            nearest = min(nearest, math.Hypot(px-x, py-y))
+        }
+    }
+    return 2*min(nearest, 1) - 1
+}
+
+// 2D simplex noise in about -1 to 1
+func simplexNoise2(x, y float64, seed uint32) float64 {
+    const f2 = 0.36602540378443865 // (sqrt(3) - 1) / 2
+    const g2 = 0.21132486540518713 // (3 - sqrt(3)) / 6
+
+    s := (x + y) * f2
+    i, j := int(math.Floor(x+s)), int(math.Floor(y+s))
+    t := float64(i+j) * g2
+    x0, y0 := x-(float64(i)-t), y-(float64(j)-t)
+    i1, j1 := 0, 1
+    if x0 > y0 {
+        i1, j1 = 1, 0
+    }
+    corners := [3][4]float64{
+        {x0, y0, 0, 0},
+        {x0 - float64(i1) + g2, y0 - float64(j1) + g2, float64(i1), float64(j1)},
+        {x0 - 1 + 2*g2, y0 - 1 + 2*g2, 1, 1},
+    }
+    n := 0.0
+    for _, corner := range corners {
+        t := 0.5 - corner[0]*corner[0] - corner[1]*corner[1]
+        if t > 0 {
+            t *= t
+            h := hashLattice(seed, i+int(corner[2]), j+int(corner[3]))
+            n += t * t * gradient2(h, corner[0], corner[1])
+        }
+    }
+    return 70 * n
+}
+
+// Dot product of (x, y, z, w) with one of the 32 edge gradients of a 4D cube picked by (h)
+func gradient4(h uint32, x, y, z, w float64) float64 {
+    d := [4]float64{x, y, z, w}
+    zero := int(h>>3) & 3
+    sum, bit := 0.0, uint32(1)
+    for axis := 0; axis < 4; axis++ {
+        if axis == zero {
+            continue
+        }
+        if h&bit != 0 {
+            sum -= d[axis]
+        } else {
+            sum += d[axis]
+        }
+        bit <<= 1
+    }
+    return sum
+}
+
+// 4D simplex noise in about -1 to 1, what tileable simplex patterns sample on a torus
+func simplexNoise4(x, y, z, w float64, seed uint32) float64 {
+    const f4 = 0.30901699437494745 // (sqrt(5) - 1) / 4
+    const g4 = 0.1381966011250105  // (5 - sqrt(5)) / 20
+
+    p := [4]float64{x, y, z, w}
+    s := (x + y + z + w) * f4
+    var cell [4]int
+    var d0 [4]float64
+    t := 0.0
+    for axis := range p {
+        cell[axis] = int(math.Floor(p[axis] + s))
+        t += float64(cell[axis])
+    }
+    t *= g4
+    for axis := range p {
+        d0[axis] = p[axis] - (float64(cell[axis]) - t)
+    }
+
+    // Each axis steps to the next corner in order of how far along it the point is
+    var rank [4]int
+    for a := 0; a < 4; a++ {
+        for b := a + 1; b < 4; b++ {
+            if d0[a] > d0[b] {
+                rank[a]++
+            } else {
+                rank[b]++
+            }
+        }
+    }
+
+    n := 0.0
+    for corner := 0; corner < 5; corner++ {
+        var offset [4]int
+        var d [4]float64
+        for axis := range p {
+            if rank[axis] >= 4-corner {
+                offset[axis] = 1
+            }
+            d[axis] = d0[axis] - float64(offset[axis]) + float64(corner)*g4
+        }
+        t := 0.6 - d[0]*d[0] - d[1]*d[1] - d[2]*d[2] - d[3]*d[3]
+        if t > 0 {
+            t *= t
+            h := hashLattice(seed, cell[0]+offset[0], cell[1]+offset[1], cell[2]+offset[2], cell[3]+offset[3])
+            n += t * t * gradient4(h, d[0], d[1], d[2], d[3])
+        }
+    }
+    return 27 * n
+}
+
+// ProceduralGenerator generates Patterns with a compute pass on the current context, for sizes where
+// Pattern.Image is slow
+//
+// It keeps a ShaderManager for each output format it has been asked for, Cleanup deletes them.
+type ProceduralGenerator struct {
+    managers    map[uint32]*ShaderManager[float32]
+}
+
+// Creates a ProceduralGenerator, the shaders are compiled on first use
+func NewProceduralGenerator() *ProceduralGenerator {
+    return &ProceduralGenerator{managers: make(map[uint32]*ShaderManager[float32])}
+}
+
+// Generates (pattern) as a new (width) x (height) 2D texture, stored and sampled according to (opts) like
+// NewTextureFromImage
+//
+// The internal format has to be one an image2D can store, e.g. the default gl.RGBA16F, gl.RGBA8,
+// gl.RGBA32F or gl.R16F, not an sRGB or integer format. The texture has immutable storage.
+func (gen *ProceduralGenerator) Texture(pattern Pattern, width, height int, opts TextureOptions) (uint32, error) {
+    opts = opts.withDefaults()
+    if opts.NoMipmaps && isMipmapFilter(opts.MinFilter) {
+        return 0, errors.New("a mipmap min filter needs mipmaps, but NoMipmaps is set")
+    }
+    if width <= 0 || height <= 0 {
+        return 0, fmt.Errorf("invalid procedural texture size %dx%d", width, height)
+    }
+    sm, err := gen.manager(uint32(opts.InternalFormat))
+    if err != nil {
+        return 0, err
+    }
+
+    pattern = pattern.withDefaults()
+    a := FloatColorModel.Convert(pattern.Colors[0]).(FloatColor)
+    b := FloatColorModel.Convert(pattern.Colors[1]).(FloatColor)
+    program := sm.ShaderProgram
+    uniform := func(name string) int32 {
+        return gl.GetUniformLocation(program, gl.Str(name+"\x00"))
+    }
+    gl.ProgramUniform1i(program, uniform("uKind"), int32(pattern.Kind))
+    gl.ProgramUniform4f(program, uniform("uColorA"), a.R, a.G, a.B, a.A)
+    gl.ProgramUniform4f(program, uniform("uColorB"), b.R, b.G, b.B, b.A)
+    gl.ProgramUniform1i(program, uniform("uCells"), int32(pattern.Cells))
+    gl.ProgramUniform1i(program, uniform("uVertical"), boolToInt32(pattern.Vertical))
+    gl.ProgramUniform1f(program, uniform("uFrequency"), float32(pattern.Frequency))
+    gl.ProgramUniform1i(program, uniform("uOctaves"), int32(pattern.Octaves))
+    gl.ProgramUniform1f(program, uniform("uPersistence"), float32(pattern.Persistence))
+    gl.ProgramUniform1f(program, uniform("uLacunarity"), float32(pattern.Lacunarity))
+    gl.ProgramUniform1ui(program, uniform("uSeed"), pattern.Seed)
+    gl.ProgramUniform1i(program, uniform("uTileable"), boolToInt32(pattern.Tileable))
+    gl.ProgramUniform1i(program, uniform("uPremultiply"), boolToInt32(opts.PremultiplyAlpha))
+
+    levels := 1
+    if !opts.NoMipmaps {
+        levels = bits.Len(uint(max(width, height)))
+    }
+    texture := GenBindTexture()
+    gl.TexStorage2D(gl.TEXTURE_2D, int32(levels), uint32(opts.InternalFormat), int32(width), int32(height))
+    setTextureParameters(gl.TEXTURE_2D, opts)
+
+    err = sm.ExecuteImage(width, height, ImageBinding{
+        Unit:    0,
+        Texture: texture,
+        Access:  gl.WRITE_ONLY,
+        Format:  uint32(opts.InternalFormat),
+    })
+    if err != nil {
+        gl.DeleteTextures(1, &texture)
+        return 0, err
+    }
+    if !opts.NoMipmaps {
+        BindTexture(texture)
+        gl.GenerateMipmap(gl.TEXTURE_2D)
+    }
+    return texture, nil
+}
+
+// Returns the ShaderManager writing (format), compiling it the first time
+func (gen *ProceduralGenerator) manager(format uint32) (*ShaderManager[float32], error) {
+    if sm, ok := gen.managers[format]; ok {
+        return sm, nil
+    }
+    name := ""
+    for imageName, imageFormat := range imageFormats {
+        if imageFormat == format && !strings.HasSuffix(imageName, "i") {
+            name = imageName
+        }
+    }
+    if name == "" {
+        return nil, errors.New("procedural textures made by a compute pass need a float or normalized internal format an image2D can store")
+    }
+    source, err := shaderFiles.ReadFile("shaders/procedural.comp")
+    if err != nil {
+        return nil, err
+    }
+    version, body, _ := strings.Cut(string(source), "\n")
+    shaderSource := version + "\n#define IMAGE_FORMAT " + name + "\n" + body
+    program, err := TryCreateComputeShader(shaderSource, "procedural.comp")
+    if err != nil {
+        return nil, err
+    }
+    sm := &ShaderManager[float32]{ShaderProgram: program, source: shaderSource}
+    gen.managers[format] = sm
+    return sm, nil
+}
+
+// Deletes the generator's shaders, the textures it made are left alone
+func (gen *ProceduralGenerator) Cleanup() {
+    for format, sm := range gen.managers {
+        sm.Cleanup()
+        delete(gen.managers, format)
+    }
+}
+
+func boolToInt32(b bool) int32 {
+    if b {
+        return 1
+    }
+    return 0
+}
@@ -0,0 +1,75 @@
+package glf_test
+
+import (
+	"image/color"
+	"math"
+	"testing"
+
+	"github.com/KCkingcollin/go-help-func/glf"
+	"github.com/KCkingcollin/go-help-func/glf/glftest"
+	"github.com/go-gl/gl/v4.6-core/gl"
+)
+
+// ProceduralGenerator is the GPU side of Pattern.Image, so the two have to give the same pixels
+func TestProceduralGeneratorMatchesImage(t *testing.T) {
+    ctx := glftest.Context(t)
+    red, blue := color.NRGBA{255, 0, 0, 255}, color.NRGBA{0, 0, 255, 255}
+    tests := []struct {
+        name        string
+        pattern     glf.Pattern
+        tolerance   float64 // Noise is float32 on the GPU and float64 on the CPU
+    }{
+        {"solid", glf.SolidPattern(red), 0},
+        {"checkerboard", glf.CheckerboardPattern(5, red, blue), 0},
+        {"horizontal gradient", glf.GradientPattern(red, blue, false), 0},
+        {"vertical gradient", glf.GradientPattern(red, blue, true), 0},
+        {"uv grid", glf.UVGridPattern(4), 0},
+        {"perlin", glf.Pattern{Kind: glf.PatternPerlin, Frequency: 3, Octaves: 3, Seed: 1}, 1e-3},
+        {"tileable perlin", glf.NoisePattern(glf.PatternPerlin, 4, 2, 2), 1e-3},
+        {"simplex", glf.Pattern{Kind: glf.PatternSimplex, Frequency: 5, Octaves: 2, Seed: 3}, 1e-3},
+        {"tileable simplex", glf.NoisePattern(glf.PatternSimplex, 3, 2, 4), 1e-3},
+        {"worley", glf.Pattern{Kind: glf.PatternWorley, Frequency: 6, Seed: 5}, 1e-3},
+        {"tileable worley", glf.NoisePattern(glf.PatternWorley, 4, 3, 6), 1e-3},
+    }
+    const width, height = 40, 24
+    opts := glf.TextureOptions{InternalFormat: gl.RGBA32F, NoMipmaps: true, MinFilter: gl.NEAREST, MagFilter: gl.NEAREST}
+
+    ctx.Do(func() {
+        gen := glf.NewProceduralGenerator()
+        defer gen.Cleanup()
+        for _, test := range tests {
+            texture, err := gen.Texture(test.pattern, width, height, opts)
+            if err != nil {
+                t.Errorf("%s: %v", test.name, err)
+                continue
+            }
+            got, err := glf.ReadTexture64(texture, 0, false)
+            gl.DeleteTextures(1, &texture)
+            if err != nil {
+                t.Errorf("%s: %v", test.name, err)
+                continue
+            }
+            want := test.pattern.Image(width, height)
+
+            // Both end up as 16 bit values, so a tolerance of 0 still allows one step of rounding
+            limit := max(test.tolerance, 1.0/65535)
+            worst, worstAt := 0.0, [2]int{}
+            for y := 0; y < height; y++ {
+                for x := 0; x < width; x++ {
+                    g := got.RGBA64At(x, y)
+                    i := want.PixOffset(x, y)
+                    for c, value := range []uint16{g.R, g.G, g.B, g.A} {
+                        if diff := math.Abs(float64(value)/65535 - float64(want.Pix[i+c])); diff > worst {
+                            worst, worstAt = diff, [2]int{x, y}
+                        }
+                    }
+                }
+            }
+            if worst > limit {
+                at := want.PixOffset(worstAt[0], worstAt[1])
+                t.Errorf("%s: pixel %v is %v on the GPU and %v on the CPU, %v apart with a tolerance of %v",
+                    test.name, worstAt, got.RGBA64At(worstAt[0], worstAt[1]), want.Pix[at:at+4], worst, limit)
+            }
+        }
+    })
+}
@@ -0,0 +1,137 @@
+package glf
+
+import (
+	"image/color"
+	"math"
+	"testing"
+)
+
+// Fails the test if pixel (x, y) of (img) isn't (want)
+func expectFloatPixel(t *testing.T, name string, img *FloatImage, x, y int, want FloatColor) {
+    t.Helper()
+    i := img.PixOffset(x, y)
+    if got := (FloatColor{img.Pix[i], img.Pix[i+1], img.Pix[i+2], img.Pix[i+3]}); got != want {
+        t.Errorf("%s: pixel (%d, %d) is %v, want %v", name, x, y, got, want)
+    }
+}
+
+func TestCheckerboardPattern(t *testing.T) {
+    red, blue := color.NRGBA{255, 0, 0, 255}, color.NRGBA{0, 0, 255, 255}
+    a, b := FloatColor{1, 0, 0, 1}, FloatColor{0, 0, 1, 1}
+    // 4 squares across 8 pixels are 2 pixels each, and they stay square on the shorter height
+    img := CheckerboardPattern(4, red, blue).Image(8, 4)
+    tests := []struct {
+        x, y    int
+        want    FloatColor
+    }{
+        {0, 0, a}, {1, 1, a}, {2, 0, b}, {3, 1, b}, {4, 0, a}, {7, 0, b},
+        {0, 2, b}, {2, 2, a}, {7, 3, a},
+    }
+    for _, test := range tests {
+        expectFloatPixel(t, "checkerboard", img, test.x, test.y, test.want)
+    }
+}
+
+func TestUVGridPattern(t *testing.T) {
+    white := FloatColor{1, 1, 1, 1}
+    // 2 cells on each axis of an 8x4 image, 4 pixels across and 2 down
+    img := UVGridPattern(2).Image(8, 4)
+    tests := []struct {
+        name    string
+        x, y    int
+        want    FloatColor
+    }{
+        {"top left corner", 0, 0, white},
+        {"left edge", 0, 1, white},
+        {"top edge", 1, 0, white},
+        {"second column line", 4, 3, white},
+        {"second row line", 6, 2, white},
+        {"first cell", 1, 1, FloatColor{1.5 / 8, 1.5 / 4, 0.25, 1}},
+        {"second cell across", 5, 1, FloatColor{5.5 / 8, 1.5 / 4, 0.75, 1}},
+        {"second cell down", 3, 3, FloatColor{3.5 / 8, 3.5 / 4, 0.75, 1}},
+        {"last cell", 7, 3, FloatColor{7.5 / 8, 3.5 / 4, 0.25, 1}},
+    }
+    for _, test := range tests {
+        expectFloatPixel(t, test.name, img, test.x, test.y, test.want)
+    }
+}
+
+func TestSolidAndGradientPatterns(t *testing.T) {
+    expectFloatPixel(t, "solid", SolidPattern(color.NRGBA{0, 255, 0, 255}).Image(3, 3), 2, 1, FloatColor{0, 1, 0, 1})
+    // The pixel centers of a 4 pixel gradient are 1/8, 3/8, 5/8 and 7/8 of the way along
+    across := GradientPattern(color.Black, color.White, false).Image(4, 2)
+    down := GradientPattern(color.Black, color.White, true).Image(2, 4)
+    for i := 0; i < 4; i++ {
+        value := (float32(i) + 0.5) / 4
+        expectFloatPixel(t, "horizontal gradient", across, i, 1, FloatColor{value, value, value, 1})
+        expectFloatPixel(t, "vertical gradient", down, 1, i, FloatColor{value, value, value, 1})
+    }
+    if img := SolidPattern(color.White).Image(0, 5); len(img.Pix) != 0 || !img.Rect.Empty() {
+        t.Errorf("a zero width pattern gave %v", img.Rect)
+    }
+}
+
+// Tileable noise has to repeat every whole texture, so the right edge runs on into the left one
+func TestTileableNoiseWraps(t *testing.T) {
+    tests := []struct {
+        name    string
+        pattern Pattern
+        aspect  float64
+    }{
+        {"perlin", NoisePattern(PatternPerlin, 4, 1, 1), 1},
+        {"perlin rounded frequency", NoisePattern(PatternPerlin, 2.6, 3, 2), 0.5},
+        {"simplex", NoisePattern(PatternSimplex, 3.3, 4, 3), 2},
+        {"worley", NoisePattern(PatternWorley, 5, 2, 4), 1},
+        {"worley rounded frequency", NoisePattern(PatternWorley, 4.4, 1, 5), 0.75},
+    }
+    for _, test := range tests {
+        pattern := test.pattern.withDefaults()
+        for _, at := range [][2]float64{{0, 0}, {0.01, 0.3}, {0.37, 0.91}, {0.999, 0.5}} {
+            u, v := at[0], at[1]
+            n := pattern.noise(u, v, test.aspect)
+            if across := pattern.noise(u+1, v, test.aspect); math.Abs(across-n) > 1e-9 {
+                t.Errorf("%s: noise at (%v, %v) is %v, one texture across it's %v", test.name, u, v, n, across)
+            }
+            if down := pattern.noise(u, v+1, test.aspect); math.Abs(down-n) > 1e-9 {
+                t.Errorf("%s: noise at (%v, %v) is %v, one texture down it's %v", test.name, u, v, n, down)
+            }
+        }
+    }
+
+    // The same seams without Tileable don't line up
+    pattern := Pattern{Kind: PatternPerlin, Frequency: 4, Seed: 1}.withDefaults()
+    if pattern.noise(0.3, 0.3, 1) == pattern.noise(1.3, 0.3, 1) {
+        t.Error("perlin noise repeats without Tileable")
+    }
+}
+
+// Noise fills the range between the two colors without going past either of them
+func TestNoisePatternRange(t *testing.T) {
+    for _, kind := range []PatternKind{PatternPerlin, PatternSimplex, PatternWorley} {
+        for _, tileable := range []bool{false, true} {
+            pattern := Pattern{Kind: kind, Frequency: 8, Octaves: 4, Seed: 7, Tileable: tileable,
+                Colors: [2]color.Color{color.NRGBA{0, 0, 64, 255}, color.NRGBA{255, 255, 64, 255}}}
+            img := pattern.Image(64, 64)
+            low, high := float32(1), float32(0)
+            for i := 0; i < len(img.Pix); i += 4 {
+                r, g, b, a := img.Pix[i], img.Pix[i+1], img.Pix[i+2], img.Pix[i+3]
+                if r < 0 || r > 1 || r != g || b != 64.0/255 || a != 1 {
+                    t.Fatalf("%v tileable %v: pixel %d is %v, want a color between the two", kind, tileable, i/4, img.Pix[i:i+4])
+                }
+                low, high = min(low, r), max(high, r)
+            }
+            if high-low < 0.3 {
+                t.Errorf("%v tileable %v: noise only covers %v to %v", kind, tileable, low, high)
+            }
+        }
+    }
+}
+
+func TestPatternKindString(t *testing.T) {
+    if got := PatternWorley.String(); got != "worley" {
+        t.Errorf("PatternWorley is %q", got)
+    }
+    if got := PatternKind(42).String(); got != "PatternKind(42)" {
+        t.Errorf("an unknown kind is %q", got)
+    }
+}
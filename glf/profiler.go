@@ -0,0 +1,258 @@
+// Profiler GL Helper Functions
+package glf
+
+import (
+	"strings"
+	"time"
+
+	"github.com/go-gl/gl/v4.6-core/gl"
+)
+
+// Profiler times GPU work in named scopes with GL_TIMESTAMP queries
+//
+// Scopes can be nested, and are grouped into frames by EndFrame. Query results are only read once the
+// driver says they're available, usually a frame or two later, so profiling never stalls the pipeline.
+// All methods do nothing on a nil Profiler, so it can be left unset on a ShaderManager.
+//
+// Code that never calls EndFrame, e.g. compute only work through Execute, still has its queries recycled:
+// once maxFrameScopes scopes have finished Begin closes the frame itself, and Results polls for anything
+// that's ready, so the profiler doesn't grow without bound.
+type Profiler struct {
+    current     []*profileScope
+    open        []*profileScope
+    pending     [][]*profileScope
+    queries     []uint32
+    results     []ProfileResult
+    stats       map[string]*ProfileStats
+}
+
+// Finished scopes after which Begin ends the frame itself
+const maxFrameScopes = 256
+
+// One timed scope waiting on its queries
+type profileScope struct {
+    path        string
+    depth       int
+    begin       uint32
+    end         uint32
+    cpuStart    time.Time
+    cpu         time.Duration
+}
+
+// Timing of one scope in a finished frame
+type ProfileResult struct {
+    Path    string          // Names of the enclosing scopes and this one joined by "/", e.g. "Execute/upload"
+    Depth   int             // Nesting depth, 0 for outer scopes
+    GPU     time.Duration   // Time between the GPU reaching the start and end of the scope
+    CPU     time.Duration   // Wall clock time between Begin and End on the calling thread
+}
+
+// Running totals for every scope with the same path
+type ProfileStats struct {
+    Count       int
+    GPULast     time.Duration
+    GPUMin      time.Duration
+    GPUMax      time.Duration
+    GPUTotal    time.Duration
+    CPUTotal    time.Duration
+}
+
+// Average GPU time of the scope
+func (stats ProfileStats) GPUAverage() time.Duration {
+    if stats.Count == 0 {
+        return 0
+    }
+    return stats.GPUTotal / time.Duration(stats.Count)
+}
+
+// Average CPU time of the scope
+func (stats ProfileStats) CPUAverage() time.Duration {
+    if stats.Count == 0 {
+        return 0
+    }
+    return stats.CPUTotal / time.Duration(stats.Count)
+}
+
+// Creates a new profiler, a GL context has to be current when scopes are used
+func NewProfiler() *Profiler {
+    return &Profiler{stats: make(map[string]*ProfileStats)}
+}
+
+// Gets a query object from the pool, or makes a new one
+func (p *Profiler) query() uint32 {
+    if n := len(p.queries); n > 0 {
+        id := p.queries[n-1]
+        p.queries = p.queries[:n-1]
+        return id
+    }
+    var id uint32
+    gl.GenQueries(1, &id)
+    return id
+}
+
+// Opens a scope inside whatever scope is currently open
+func (p *Profiler) Begin(name string) {
+    if p == nil {
+        return
+    }
+    // Every open scope is also in current, the rest are finished
+    if len(p.current)-len(p.open) >= maxFrameScopes {
+        p.EndFrame()
+    }
+    path := name
+    if n := len(p.open); n > 0 {
+        path = p.open[n-1].path + "/" + name
+    }
+    scope := &profileScope{path: path, depth: len(p.open), begin: p.query(), end: p.query()}
+    gl.QueryCounter(scope.begin, gl.TIMESTAMP)
+    scope.cpuStart = time.Now()
+    p.open = append(p.open, scope)
+    p.current = append(p.current, scope)
+}
+
+// Closes the innermost open scope
+func (p *Profiler) End() {
+    if p == nil || len(p.open) == 0 {
+        return
+    }
+    scope := p.open[len(p.open)-1]
+    p.open = p.open[:len(p.open)-1]
+    scope.cpu = time.Since(scope.cpuStart)
+    gl.QueryCounter(scope.end, gl.TIMESTAMP)
+}
+
+// Finishes the current frame, and collects the results of any earlier frames the GPU is done with
+//
+// Scopes still open carry over into the next frame
+func (p *Profiler) EndFrame() {
+    if p == nil {
+        return
+    }
+    var finished []*profileScope
+    var carried []*profileScope
+    for _, scope := range p.current {
+        if p.isOpen(scope) {
+            carried = append(carried, scope)
+        } else {
+            finished = append(finished, scope)
+        }
+    }
+    if len(finished) > 0 {
+        p.pending = append(p.pending, finished)
+    }
+    p.current = carried
+    p.Poll()
+}
+
+// Returns true if the scope hasn't been ended yet
+func (p *Profiler) isOpen(scope *profileScope) bool {
+    for _, open := range p.open {
+        if open == scope {
+            return true
+        }
+    }
+    return false
+}
+
+// Reads back every pending frame whose queries are all available, oldest first, without waiting
+func (p *Profiler) Poll() {
+    if p == nil {
+        return
+    }
+    for len(p.pending) > 0 {
+        frame := p.pending[0]
+        for _, scope := range frame {
+            var available int32
+            gl.GetQueryObjectiv(scope.end, gl.QUERY_RESULT_AVAILABLE, &available)
+            if available == gl.FALSE {
+                return
+            }
+        }
+
+        p.results = p.results[:0]
+        for _, scope := range frame {
+            var begin, end uint64
+            gl.GetQueryObjectui64v(scope.begin, gl.QUERY_RESULT, &begin)
+            gl.GetQueryObjectui64v(scope.end, gl.QUERY_RESULT, &end)
+            p.queries = append(p.queries, scope.begin, scope.end)
+
+            result := ProfileResult{Path: scope.path, Depth: scope.depth, GPU: time.Duration(end - begin), CPU: scope.cpu}
+            p.results = append(p.results, result)
+            p.record(result)
+        }
+        p.pending = p.pending[1:]
+    }
+}
+
+// Adds a result to the stats of its path
+func (p *Profiler) record(result ProfileResult) {
+    stats, ok := p.stats[result.Path]
+    if !ok {
+        stats = &ProfileStats{GPUMin: result.GPU}
+        p.stats[result.Path] = stats
+    }
+    stats.Count++
+    stats.GPULast = result.GPU
+    stats.GPUMin = min(stats.GPUMin, result.GPU)
+    stats.GPUMax = max(stats.GPUMax, result.GPU)
+    stats.GPUTotal += result.GPU
+    stats.CPUTotal += result.CPU
+}
+
+// Collects any finished frames, then returns the scopes of the most recently collected one in the order
+// they were opened
+func (p *Profiler) Results() []ProfileResult {
+    if p == nil {
+        return nil
+    }
+    p.Poll()
+    return append([]ProfileResult(nil), p.results...)
+}
+
+// Collects any finished frames, then returns a copy of the running stats of every scope path so far
+func (p *Profiler) Stats() map[string]ProfileStats {
+    stats := make(map[string]ProfileStats)
+    if p == nil {
+        return stats
+    }
+    p.Poll()
+    for path, s := range p.stats {
+        stats[path] = *s
+    }
+    return stats
+}
+
+// Clears the running stats, pending frames are kept
+func (p *Profiler) ResetStats() {
+    if p == nil {
+        return
+    }
+    p.stats = make(map[string]*ProfileStats)
+}
+
+// Formats the last collected frame as an indented list of GPU and CPU times
+func (p *Profiler) String() string {
+    var b strings.Builder
+    for _, result := range p.Results() {
+        name := result.Path[strings.LastIndex(result.Path, "/")+1:]
+        b.WriteString(strings.Repeat("  ", result.Depth))
+        b.WriteString(name + ": gpu " + result.GPU.String() + ", cpu " + result.CPU.String() + "\n")
+    }
+    return b.String()
+}
+
+// Deletes every query owned by the profiler, results of frames still pending are lost
+func (p *Profiler) Delete() {
+    if p == nil {
+        return
+    }
+    for _, frame := range append(p.pending, p.current) {
+        for _, scope := range frame {
+            p.queries = append(p.queries, scope.begin, scope.end)
+        }
+    }
+    if len(p.queries) > 0 {
+        gl.DeleteQueries(int32(len(p.queries)), &p.queries[0])
+    }
+    p.queries, p.pending, p.current, p.open = nil, nil, nil, nil
+}
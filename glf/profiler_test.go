@@ -0,0 +1,70 @@
+package glf
+
+import (
+	"testing"
+
+	"github.com/go-gl/gl/v4.6-core/gl"
+)
+
+// Scopes opened without ever calling EndFrame, like compute only code does, have to be recycled
+func TestProfilerWithoutEndFrame(t *testing.T) {
+    ctx, err := NewThreadedComputeContext(DefaultContextBackend)
+    if err != nil {
+        t.Skipf("no GL context could be created: %v", err)
+    }
+    defer ctx.Release()
+
+    p := NewProfiler()
+    ctx.Do(func() {
+        defer p.Delete()
+        for i := 0; i < 10*maxFrameScopes; i++ {
+            p.Begin("outer")
+            p.Begin("inner")
+            p.End()
+            p.End()
+            if i%maxFrameScopes == 0 {
+                gl.Finish()
+            }
+        }
+        if len(p.current) > maxFrameScopes+2 {
+            t.Errorf("%d scopes are waiting in the current frame, want at most %d", len(p.current), maxFrameScopes+2)
+        }
+        gl.Finish()
+        if len(p.Results()) == 0 {
+            t.Error("Results is empty without EndFrame")
+        }
+        if stats := p.Stats()["outer/inner"]; stats.Count == 0 {
+            t.Error("no inner scopes were collected without EndFrame")
+        }
+        if len(p.pending) > 0 {
+            t.Errorf("%d frames are still pending after Finish", len(p.pending))
+        }
+    })
+}
+
+func TestProfilerKeepsOpenScopes(t *testing.T) {
+    ctx, err := NewThreadedComputeContext(DefaultContextBackend)
+    if err != nil {
+        t.Skipf("no GL context could be created: %v", err)
+    }
+    defer ctx.Release()
+
+    p := NewProfiler()
+    ctx.Do(func() {
+        defer p.Delete()
+        p.Begin("long")
+        for i := 0; i < 2*maxFrameScopes; i++ {
+            p.Begin("short")
+            p.End()
+        }
+        p.End()
+        p.EndFrame()
+        gl.Finish()
+        if stats := p.Stats()["long"]; stats.Count != 1 {
+            t.Errorf("the scope open across the automatic frame end was collected %d times, want 1", stats.Count)
+        }
+        if stats := p.Stats()["long/short"]; stats.Count != 2*maxFrameScopes {
+            t.Errorf("collected %d inner scopes, want %d", stats.Count, 2*maxFrameScopes)
+        }
+    })
+}
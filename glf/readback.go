@@ -0,0 +1,241 @@
+// Texture and Framebuffer Readback GL Helper Functions
+package glf
+
+import (
+	"errors"
+	"fmt"
+	"image"
+	"image/png"
+	"os"
+
+	"github.com/go-gl/gl/v4.6-core/gl"
+)
+
+// RenderTarget is a framebuffer with a color texture and a depth buffer, something to draw into and
+// read back when there's no window to draw to, like in contexts made by InitSdlNoWindow
+type RenderTarget struct {
+    Framebuffer     uint32
+    Texture         uint32 // gl.RGBA8 color attachment
+    Depth           uint32 // DEPTH24_STENCIL8 renderbuffer
+    Width, Height   int
+}
+
+// Creates a (width) x (height) RenderTarget, leaves it bound, and sets the viewport to it
+func NewRenderTarget(width, height int) (*RenderTarget, error) {
+    if width <= 0 || height <= 0 {
+        return nil, fmt.Errorf("invalid render target size %dx%d", width, height)
+    }
+    target := &RenderTarget{Width: width, Height: height}
+    target.Texture = NewImageTexture(width, height, gl.RGBA8)
+    BindTexture(0)
+
+    gl.GenRenderbuffers(1, &target.Depth)
+    gl.BindRenderbuffer(gl.RENDERBUFFER, target.Depth)
+    gl.RenderbufferStorage(gl.RENDERBUFFER, gl.DEPTH24_STENCIL8, int32(width), int32(height))
+    gl.BindRenderbuffer(gl.RENDERBUFFER, 0)
+
+    gl.GenFramebuffers(1, &target.Framebuffer)
+    gl.BindFramebuffer(gl.FRAMEBUFFER, target.Framebuffer)
+    gl.FramebufferTexture2D(gl.FRAMEBUFFER, gl.COLOR_ATTACHMENT0, gl.TEXTURE_2D, target.Texture, 0)
+    gl.FramebufferRenderbuffer(gl.FRAMEBUFFER, gl.DEPTH_STENCIL_ATTACHMENT, gl.RENDERBUFFER, target.Depth)
+    if status := gl.CheckFramebufferStatus(gl.FRAMEBUFFER); status != gl.FRAMEBUFFER_COMPLETE {
+        target.Delete()
+        return nil, fmt.Errorf("render target framebuffer is incomplete, status 0x%X", status)
+    }
+    gl.Viewport(0, 0, int32(width), int32(height))
+    return target, nil
+}
+
+// Binds the render target for drawing and reading, and sets the viewport to it
+func (target *RenderTarget) Bind() {
+    gl.BindFramebuffer(gl.FRAMEBUFFER, target.Framebuffer)
+    gl.Viewport(0, 0, int32(target.Width), int32(target.Height))
+}
+
+// Reads the render target back with the top row first
+func (target *RenderTarget) ReadImage() *image.NRGBA {
+    return ReadFramebuffer(target.Framebuffer, 0, 0, target.Width, target.Height)
+}
+
+// Deletes the framebuffer and its attachments, and binds the default framebuffer if it was bound
+func (target *RenderTarget) Delete() {
+    var bound int32
+    gl.GetIntegerv(gl.FRAMEBUFFER_BINDING, &bound)
+    if uint32(bound) == target.Framebuffer {
+        gl.BindFramebuffer(gl.FRAMEBUFFER, 0)
+    }
+    gl.DeleteFramebuffers(1, &target.Framebuffer)
+    gl.DeleteTextures(1, &target.Texture)
+    gl.DeleteRenderbuffers(1, &target.Depth)
+}
+
+// Reads mip (level) of a 2D texture back as 8 bit straight alpha RGBA
+//
+// Textures from LoadTexture and UploadImage keep the top row of the image in row 0, so they read back
+// the right way up as they are. Textures that were rendered to have the bottom row in row 0, set (flipY)
+// to turn those into image coordinates.
+func ReadTexture(texture uint32, level int, flipY bool) (*image.NRGBA, error) {
+    width, height, err := textureLevelSize(texture, level)
+    if err != nil {
+        return nil, err
+    }
+    img := image.NewNRGBA(image.Rect(0, 0, width, height))
+    if len(img.Pix) > 0 {
+        gl.PixelStorei(gl.PACK_ALIGNMENT, 1)
+        gl.GetTexImage(gl.TEXTURE_2D, int32(level), gl.RGBA, gl.UNSIGNED_BYTE, gl.Ptr(img.Pix))
+        gl.PixelStorei(gl.PACK_ALIGNMENT, 4)
+    }
+    if flipY {
+        flipRows(img.Pix, img.Stride)
+    }
+    return img, nil
+}
+
+// Reads mip (level) of a 2D texture back as 16 bit RGBA, for float and 16 bit textures, see ReadTexture
+//
+// Values are clamped to 0-1, and premultiplied since image.RGBA64 is
+func ReadTexture64(texture uint32, level int, flipY bool) (*image.RGBA64, error) {
+    width, height, err := textureLevelSize(texture, level)
+    if err != nil {
+        return nil, err
+    }
+    pixels := make([]uint16, width*height*4)
+    if len(pixels) > 0 {
+        gl.PixelStorei(gl.PACK_ALIGNMENT, 2)
+        gl.GetTexImage(gl.TEXTURE_2D, int32(level), gl.RGBA, gl.UNSIGNED_SHORT, gl.Ptr(pixels))
+        gl.PixelStorei(gl.PACK_ALIGNMENT, 4)
+    }
+    return rgba64Image(pixels, width, height, flipY), nil
+}
+
+// Reads a (width) x (height) rectangle of (framebuffer), 0 for the default one, as 8 bit straight alpha RGBA
+//
+// (x) and (y) are the bottom left corner in GL window coordinates, the image comes back flipped so its
+// top row is the top of the rectangle. The color attachment set with glReadBuffer is the one read, and the
+// framebuffer that was bound for reading is bound again afterwards.
+func ReadFramebuffer(framebuffer uint32, x, y, width, height int) *image.NRGBA {
+    img := image.NewNRGBA(image.Rect(0, 0, max(width, 0), max(height, 0)))
+    if len(img.Pix) == 0 {
+        return img
+    }
+    restore := bindReadFramebuffer(framebuffer)
+    gl.PixelStorei(gl.PACK_ALIGNMENT, 1)
+    gl.ReadPixels(int32(x), int32(y), int32(width), int32(height), gl.RGBA, gl.UNSIGNED_BYTE, gl.Ptr(img.Pix))
+    gl.PixelStorei(gl.PACK_ALIGNMENT, 4)
+    restore()
+    flipRows(img.Pix, img.Stride)
+    return img
+}
+
+// Same as ReadFramebuffer, but reads 16 bits per channel into a premultiplied image.RGBA64
+func ReadFramebuffer64(framebuffer uint32, x, y, width, height int) *image.RGBA64 {
+    width, height = max(width, 0), max(height, 0)
+    pixels := make([]uint16, width*height*4)
+    if len(pixels) > 0 {
+        restore := bindReadFramebuffer(framebuffer)
+        gl.PixelStorei(gl.PACK_ALIGNMENT, 2)
+        gl.ReadPixels(int32(x), int32(y), int32(width), int32(height), gl.RGBA, gl.UNSIGNED_SHORT, gl.Ptr(pixels))
+        gl.PixelStorei(gl.PACK_ALIGNMENT, 4)
+        restore()
+    }
+    return rgba64Image(pixels, width, height, true)
+}
+
+// Reads the current viewport of the framebuffer bound for reading, the usual way to take a screenshot
+func ReadViewport() *image.NRGBA {
+    var viewport [4]int32
+    var bound int32
+    gl.GetIntegerv(gl.VIEWPORT, &viewport[0])
+    gl.GetIntegerv(gl.READ_FRAMEBUFFER_BINDING, &bound)
+    return ReadFramebuffer(uint32(bound), int(viewport[0]), int(viewport[1]), int(viewport[2]), int(viewport[3]))
+}
+
+// Encodes (img) as a PNG file at (filePath), replacing it if it exists
+func SavePNG(filePath string, img image.Image) error {
+    file, err := os.Create(filePath)
+    if err != nil {
+        return err
+    }
+    if err := png.Encode(file, img); err != nil {
+        file.Close()
+        return fmt.Errorf("%s: %w", filePath, err)
+    }
+    return file.Close()
+}
+
+// Saves mip (level) of a 2D texture as an 8 bit PNG, see ReadTexture
+func SaveTexturePNG(filePath string, texture uint32, level int, flipY bool) error {
+    img, err := ReadTexture(texture, level, flipY)
+    if err != nil {
+        return err
+    }
+    return SavePNG(filePath, img)
+}
+
+// Saves the current viewport as an 8 bit PNG, see ReadViewport
+func SaveScreenshotPNG(filePath string) error {
+    return SavePNG(filePath, ReadViewport())
+}
+
+// Binds (texture) and returns the size of its mip (level), failing for levels it doesn't have
+func textureLevelSize(texture uint32, level int) (int, int, error) {
+    if !gl.IsTexture(texture) {
+        return 0, 0, fmt.Errorf("%d isn't a texture", texture)
+    }
+    if level < 0 {
+        return 0, 0, errors.New("texture level can't be negative")
+    }
+    var width, height int32
+    BindTexture(texture)
+    gl.GetTexLevelParameteriv(gl.TEXTURE_2D, int32(level), gl.TEXTURE_WIDTH, &width)
+    gl.GetTexLevelParameteriv(gl.TEXTURE_2D, int32(level), gl.TEXTURE_HEIGHT, &height)
+    if width == 0 || height == 0 {
+        return 0, 0, fmt.Errorf("texture %d has no level %d", texture, level)
+    }
+    return int(width), int(height), nil
+}
+
+// Binds (framebuffer) for reading and returns a function that binds the previous one again
+func bindReadFramebuffer(framebuffer uint32) func() {
+    var previous int32
+    gl.GetIntegerv(gl.READ_FRAMEBUFFER_BINDING, &previous)
+    gl.BindFramebuffer(gl.READ_FRAMEBUFFER, framebuffer)
+    return func() {
+        gl.BindFramebuffer(gl.READ_FRAMEBUFFER, uint32(previous))
+    }
+}
+
+// Reverses the order of the rows of (pix), which are (stride) bytes each
+func flipRows(pix []uint8, stride int) {
+    row := make([]uint8, stride)
+    for top, bottom := 0, len(pix)-stride; top < bottom; top, bottom = top+stride, bottom-stride {
+        copy(row, pix[top:top+stride])
+        copy(pix[top:top+stride], pix[bottom:bottom+stride])
+        copy(pix[bottom:bottom+stride], row)
+    }
+}
+
+// Builds an image.RGBA64 from straight alpha 16 bit RGBA values read from GL, premultiplying them
+func rgba64Image(pixels []uint16, width, height int, flipY bool) *image.RGBA64 {
+    img := image.NewRGBA64(image.Rect(0, 0, width, height))
+    for y := 0; y < height; y++ {
+        row := y
+        if flipY {
+            row = height - 1 - y
+        }
+        in := pixels[row*width*4 : (row+1)*width*4]
+        out := img.Pix[y*img.Stride:]
+        for i := 0; i < len(in); i += 4 {
+            a := uint32(in[i+3])
+            for c := 0; c < 4; c++ {
+                value := uint32(in[i+c])
+                if c < 3 {
+                    value = (value*a + 0x7fff) / 0xffff
+                }
+                out[i*2+c*2] = uint8(value >> 8)
+                out[i*2+c*2+1] = uint8(value)
+            }
+        }
+    }
+    return img
+}
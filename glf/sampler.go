@@ -0,0 +1,67 @@
+// Sampler Object GL Helper Functions
+package glf
+
+import (
+	"errors"
+
+	"github.com/go-gl/gl/v4.6-core/gl"
+)
+
+// Sampler is a GL sampler object, filtering and wrap state that overrides the texture's own on the units it's bound to
+//
+// One sampler can be shared by any number of textures, so a texture can be sampled with different state
+// in different places without changing it.
+type Sampler struct {
+    ID      uint32
+    Options TextureOptions  // Only the wrap, filter, anisotropy and border fields apply
+}
+
+// Creates a sampler with the wrap, filter, anisotropy and border color of (opts), unset fields use the
+// same defaults as textures
+func NewSampler(opts TextureOptions) (*Sampler, error) {
+    sampler := &Sampler{}
+    gl.GenSamplers(1, &sampler.ID)
+    if err := sampler.Set(opts); err != nil {
+        gl.DeleteSamplers(1, &sampler.ID)
+        return nil, err
+    }
+    return sampler, nil
+}
+
+// Changes the sampler's state, every texture sampled through it picks up the change
+//
+// A mipmap min filter only works on textures that have mipmaps, the sampler doesn't know which ones do.
+func (sampler *Sampler) Set(opts TextureOptions) error {
+    opts = opts.withDefaults()
+    if opts.NoMipmaps && isMipmapFilter(opts.MinFilter) {
+        return errors.New("a mipmap min filter needs mipmaps, but NoMipmaps is set")
+    }
+    gl.SamplerParameteri(sampler.ID, gl.TEXTURE_WRAP_S, opts.WrapS)
+    gl.SamplerParameteri(sampler.ID, gl.TEXTURE_WRAP_T, opts.WrapT)
+    gl.SamplerParameteri(sampler.ID, gl.TEXTURE_WRAP_R, opts.WrapS)
+    gl.SamplerParameteri(sampler.ID, gl.TEXTURE_MIN_FILTER, opts.MinFilter)
+    gl.SamplerParameteri(sampler.ID, gl.TEXTURE_MAG_FILTER, opts.MagFilter)
+    gl.SamplerParameterfv(sampler.ID, gl.TEXTURE_BORDER_COLOR, &opts.BorderColor[0])
+
+    // Always set, so lowering it on an existing sampler takes effect
+    var limit float32
+    gl.GetFloatv(gl.MAX_TEXTURE_MAX_ANISOTROPY, &limit)
+    gl.SamplerParameterf(sampler.ID, gl.TEXTURE_MAX_ANISOTROPY, max(min(opts.MaxAnisotropy, limit), 1))
+    sampler.Options = opts
+    return nil
+}
+
+// Binds the sampler to texture unit (unit), a number from 0 rather than gl.TEXTURE0 + n
+func (sampler *Sampler) Bind(unit uint32) {
+    gl.BindSampler(unit, sampler.ID)
+}
+
+// Unbinds whatever sampler is on texture unit (unit), so textures there use their own state again
+func UnbindSampler(unit uint32) {
+    gl.BindSampler(unit, 0)
+}
+
+// Deletes the sampler, GL unbinds it from any units it was on
+func (sampler *Sampler) Delete() {
+    gl.DeleteSamplers(1, &sampler.ID)
+}
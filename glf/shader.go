@@ -15,8 +15,13 @@ type ShaderInfo struct {
     fragmentPath    string
     vertModified    time.Time
     fragModified    time.Time
+    textureUnits    map[string]uint32   // Sampler uniform names and the texture units they're set to
+    unitsProgram    uint32              // Program the sampler uniforms were set on, relinking resets them
 }
 
+// Texture units the ShaderInfo allocator hands out start here, unit 0 is left to BindTexture and the loaders
+const firstAllocatedUnit = 1
+
 var loadedShaders = make(map[uint32]*ShaderInfo)
 
 // Creates a new shader program with a path to a glsl vertex shader, and fragment shader source, in that order
@@ -29,7 +34,13 @@ func NewShaderProgram(vertexPath, fragmentPath string) (*ShaderInfo, error) {
     if err != nil {
         return nil, err
     }
-    result := &ShaderInfo{id, vertexPath, fragmentPath, ghf.GetModifiedTime(vertexPath), ghf.GetModifiedTime(fragmentPath)}
+    result := &ShaderInfo{
+        id:             id,
+        vertexPath:     vertexPath,
+        fragmentPath:   fragmentPath,
+        vertModified:   ghf.GetModifiedTime(vertexPath),
+        fragModified:   ghf.GetModifiedTime(fragmentPath),
+    }
     loadedShaders[id] = result
     return result, nil
 }
@@ -39,6 +50,93 @@ func (shader *ShaderInfo) Use() {
     gl.UseProgram(shader.id)
 }
 
+// Returns the texture unit kept for the sampler uniform (name), the first time picking the lowest free one
+// and setting the uniform to it
+//
+// Units are kept per shader, so textures have to be bound again after switching shaders. Relinking the
+// shader in CheckShadersforChanges keeps the units.
+func (shader *ShaderInfo) TextureUnit(name string) (uint32, error) {
+    shader.syncTextureUnits()
+    if unit, ok := shader.textureUnits[name]; ok {
+        return unit, nil
+    }
+    location := gl.GetUniformLocation(shader.id, gl.Str(name+"\x00"))
+    if location < 0 {
+        return 0, fmt.Errorf("shader has no active sampler uniform %s", name)
+    }
+
+    used := make(map[uint32]bool, len(shader.textureUnits))
+    for _, unit := range shader.textureUnits {
+        used[unit] = true
+    }
+    unit := uint32(firstAllocatedUnit)
+    for used[unit] {
+        unit++
+    }
+    var maxUnits int32
+    gl.GetIntegerv(gl.MAX_COMBINED_TEXTURE_IMAGE_UNITS, &maxUnits)
+    if unit >= uint32(maxUnits) {
+        return 0, fmt.Errorf("no texture unit left for sampler %s, the driver has %d", name, maxUnits)
+    }
+    gl.ProgramUniform1i(shader.id, location, int32(unit))
+    shader.textureUnits[name] = unit
+    return unit, nil
+}
+
+// Sets the sampler uniforms of a relinked program to their units again
+func (shader *ShaderInfo) syncTextureUnits() {
+    if shader.textureUnits == nil {
+        shader.textureUnits = make(map[string]uint32)
+        shader.unitsProgram = shader.id
+    }
+    if shader.unitsProgram == shader.id {
+        return
+    }
+    for name, unit := range shader.textureUnits {
+        if location := gl.GetUniformLocation(shader.id, gl.Str(name+"\x00")); location >= 0 {
+            gl.ProgramUniform1i(shader.id, location, int32(unit))
+        }
+    }
+    shader.unitsProgram = shader.id
+}
+
+// Binds (texture) to (target) on the texture unit of the sampler uniform (name), see TextureUnit
+//
+// The active unit is set back to 0 afterwards, so BindTexture and the texture loaders can't replace a
+// texture bound here.
+func (shader *ShaderInfo) BindTexture(name string, target, texture uint32) error {
+    unit, err := shader.TextureUnit(name)
+    if err != nil {
+        return err
+    }
+    gl.ActiveTexture(gl.TEXTURE0 + unit)
+    gl.BindTexture(target, texture)
+    gl.ActiveTexture(gl.TEXTURE0)
+    return nil
+}
+
+// Binds a 2D texture to the sampler uniform (name), see BindTexture
+func (shader *ShaderInfo) BindTexture2D(name string, texture uint32) error {
+    return shader.BindTexture(name, gl.TEXTURE_2D, texture)
+}
+
+// Binds (sampler) to the texture unit of the sampler uniform (name), so its state overrides the texture's,
+// or unbinds the unit's sampler if it's nil
+//
+// Samplers stay bound to the unit until something else is bound there, whichever shader is in use.
+func (shader *ShaderInfo) BindSampler(name string, sampler *Sampler) error {
+    unit, err := shader.TextureUnit(name)
+    if err != nil {
+        return err
+    }
+    if sampler == nil {
+        UnbindSampler(unit)
+    } else {
+        sampler.Bind(unit)
+    }
+    return nil
+}
+
 // Checks to see if any of the loaded shaders have been modified, and if so recreates the program for that shader.
 func CheckShadersforChanges() {
     for _, shader := range loadedShaders {
@@ -60,6 +158,7 @@ func CheckShadersforChanges() {
                 }
                 gl.DeleteProgram(shader.id)
                 shader.id = id
+                shader.syncTextureUnits()
                 shader.vertModified = ghf.GetModifiedTime(shader.vertexPath)
                 shader.fragModified = ghf.GetModifiedTime(shader.fragmentPath)
             }
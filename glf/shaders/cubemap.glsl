@@ -0,0 +1,25 @@
+// Shared by the cubemap kernels, inserted after their #version line along with IMAGE_FORMAT, the output format
+
+const float PI = 3.14159265358979;
+
+// Direction through the center of texel (texel) of cube face (face), in the GL face order +X, -X, +Y, -Y, +Z, -Z
+vec3 cubeDirection(ivec2 texel, int face, int size) {
+    vec2 uv = (vec2(texel) + 0.5) / float(size) * 2.0 - 1.0;
+    vec3 dir;
+    switch (face) {
+    case 0: dir = vec3(1.0, -uv.y, -uv.x); break;
+    case 1: dir = vec3(-1.0, -uv.y, uv.x); break;
+    case 2: dir = vec3(uv.x, 1.0, uv.y); break;
+    case 3: dir = vec3(uv.x, -1.0, -uv.y); break;
+    case 4: dir = vec3(uv.x, -uv.y, 1.0); break;
+    default: dir = vec3(-uv.x, -uv.y, -1.0); break;
+    }
+    return normalize(dir);
+}
+
+// Builds a basis around (n) so tangent space vectors can be turned into world directions
+mat3 tangentBasis(vec3 n) {
+    vec3 up = abs(n.y) < 0.999 ? vec3(0.0, 1.0, 0.0) : vec3(1.0, 0.0, 0.0);
+    vec3 tangent = normalize(cross(up, n));
+    return mat3(tangent, cross(n, tangent), n);
+}
@@ -0,0 +1,126 @@
+// Streaming Compute GL Helper Functions
+package glf
+
+import (
+	"unsafe"
+
+	"github.com/go-gl/gl/v4.6-core/gl"
+)
+
+// Name of the uint uniform ExecuteStreamed sets to the index of the chunk's first element in the whole slice
+//
+// Inside the shader binding 0 only holds the current chunk, so gl_GlobalInvocationID.x still indexes the
+// buffer and uChunkOffset + gl_GlobalInvocationID.x is the index in the original data.
+// Shaders that don't declare it run fine, the uniform is just skipped.
+const ChunkOffsetUniform = "uChunkOffset"
+
+// Returns GL_MAX_SHADER_STORAGE_BLOCK_SIZE, the most bytes a single SSBO binding can expose to a shader
+func MaxStorageBlockSize() int {
+    var size int64
+    gl.GetInteger64v(gl.MAX_SHADER_STORAGE_BLOCK_SIZE, &size)
+    return int(size)
+}
+
+// Returns the workgroup size Execute divides the data by, the given one or GL_MAX_COMPUTE_WORK_GROUP_SIZE
+func workGroupSize(sizeWorkGP []int) int {
+    if len(sizeWorkGP) > 0 {
+        return sizeWorkGP[0]
+    }
+    var size int32
+    gl.GetIntegeri_v(gl.MAX_COMPUTE_WORK_GROUP_SIZE, 0, &size)
+    return int(size)
+}
+
+// ExecuteStreamed runs the compute shader over (data) one chunk of (chunkSize) elements at a time, and
+// returns the results in order
+//
+// Two SSBOs are used in turn, so the next chunk is uploaded while the GPU works on the current one.
+// A (chunkSize) of 0 uses the largest multiple of the workgroup size that fits in MaxStorageBlockSize.
+func (sm *ShaderManager[T]) ExecuteStreamed(data []T, chunkSize int, sizeWorkGP ...int) []T {
+    if sm.Backend != nil {
+        return sm.Backend.Execute(data, sizeWorkGP...)
+    }
+    if thread := sm.glThread(); thread != nil {
+        thread.Call(func() {
+            data = sm.ExecuteStreamed(data, chunkSize, sizeWorkGP...)
+        })
+        return data
+    }
+    if sm.convert32 {
+        return sm.executeAsFloat32(data, func(inner *ShaderManager[float32], data32 []float32) {
+            inner.ExecuteStreamed(data32, chunkSize, sizeWorkGP...)
+        })
+    }
+    if len(data) == 0 {
+        return data
+    }
+
+    groupSize := workGroupSize(sizeWorkGP)
+    elemSize := int(unsafe.Sizeof(data[0]))
+    if chunkSize <= 0 {
+        chunkSize = max(MaxStorageBlockSize()/elemSize/groupSize, 1) * groupSize
+    }
+    chunkSize = min(chunkSize, len(data))
+    numChunks := (len(data) + chunkSize - 1) / chunkSize
+
+    sm.Profiler.Begin("ExecuteStreamed")
+    defer sm.Profiler.End()
+
+    var buffers [2]uint32
+    gl.GenBuffers(2, &buffers[0])
+    for _, buffer := range buffers {
+        gl.BindBuffer(gl.SHADER_STORAGE_BUFFER, buffer)
+        gl.BufferData(gl.SHADER_STORAGE_BUFFER, chunkSize*elemSize, nil, gl.DYNAMIC_COPY)
+    }
+    defer gl.DeleteBuffers(2, &buffers[0])
+
+    gl.UseProgram(sm.ShaderProgram)
+    offsetLocation := gl.GetUniformLocation(sm.ShaderProgram, gl.Str(ChunkOffsetUniform+"\x00"))
+
+    // Returns the part of data that belongs to a chunk
+    chunk := func(i int) []T {
+        return data[i*chunkSize : min((i+1)*chunkSize, len(data))]
+    }
+
+    upload := func(i int) {
+        sm.Profiler.Begin("upload")
+        part := chunk(i)
+        gl.BindBuffer(gl.SHADER_STORAGE_BUFFER, buffers[i%2])
+        gl.BufferSubData(gl.SHADER_STORAGE_BUFFER, 0, len(part)*elemSize, unsafe.Pointer(&part[0]))
+        sm.Profiler.End()
+    }
+
+    dispatch := func(i int) {
+        sm.Profiler.Begin("dispatch")
+        part := chunk(i)
+        gl.BindBufferRange(gl.SHADER_STORAGE_BUFFER, 0, buffers[i%2], 0, len(part)*elemSize)
+        sm.bindAttached()
+        gl.UseProgram(sm.ShaderProgram)
+        gl.Uniform1ui(offsetLocation, uint32(i*chunkSize))
+        gl.DispatchCompute(uint32((len(part)+groupSize-1)/groupSize), 1, 1)
+        gl.MemoryBarrier(gl.SHADER_STORAGE_BARRIER_BIT | gl.BUFFER_UPDATE_BARRIER_BIT)
+        sm.Profiler.End()
+    }
+
+    readback := func(i int) {
+        sm.Profiler.Begin("readback")
+        part := chunk(i)
+        gl.BindBuffer(gl.SHADER_STORAGE_BUFFER, buffers[i%2])
+        gl.GetBufferSubData(gl.SHADER_STORAGE_BUFFER, 0, len(part)*elemSize, unsafe.Pointer(&part[0]))
+        sm.Profiler.End()
+    }
+
+    upload(0)
+    dispatch(0)
+    for i := 0; i < numChunks; i++ {
+        // Queue up the next chunk on the other buffer before waiting on this one
+        if i+1 < numChunks {
+            upload(i + 1)
+            dispatch(i + 1)
+        }
+        readback(i)
+    }
+    gl.BindBuffer(gl.SHADER_STORAGE_BUFFER, 0)
+
+    return data
+}
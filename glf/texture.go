@@ -0,0 +1,157 @@
+// Texture Creation GL Helper Functions
+package glf
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"image"
+	"os"
+
+	"github.com/go-gl/gl/v4.6-core/gl"
+)
+
+// TextureOptions controls how a 2D texture is stored and sampled
+//
+// The zero value is what LoadTexture has always made, an RGBA16F texture with REPEAT wrapping, LINEAR
+// filtering and mipmaps, so only the fields that differ need setting.
+type TextureOptions struct {
+    InternalFormat  int32       // e.g. gl.RGBA8, gl.SRGB8_ALPHA8, gl.RG8, gl.R16F, gl.RGBA16F when 0
+    WrapS           int32       // gl.REPEAT when 0, or gl.CLAMP_TO_EDGE, gl.MIRRORED_REPEAT, gl.CLAMP_TO_BORDER
+    WrapT           int32       // gl.REPEAT when 0
+    MinFilter       int32       // gl.LINEAR when 0, the mipmap filters need mipmaps
+    MagFilter       int32       // gl.LINEAR when 0, or gl.NEAREST
+    NoMipmaps       bool        // Skip generating mipmaps
+    MaxAnisotropy   float32     // Anisotropic filtering, clamped to what the driver allows, off when 1 or less
+    BorderColor     [4]float32  // Color sampled outside the texture with gl.CLAMP_TO_BORDER
+    PremultiplyAlpha bool       // Store color multiplied by alpha for ONE, ONE_MINUS_SRC_ALPHA blending, straight when false
+}
+
+// Returns the options with every unset field filled in with its default
+func (opts TextureOptions) withDefaults() TextureOptions {
+    if opts.InternalFormat == 0 {
+        opts.InternalFormat = gl.RGBA16F
+    }
+    if opts.WrapS == 0 {
+        opts.WrapS = gl.REPEAT
+    }
+    if opts.WrapT == 0 {
+        opts.WrapT = gl.REPEAT
+    }
+    if opts.MinFilter == 0 {
+        opts.MinFilter = gl.LINEAR
+    }
+    if opts.MagFilter == 0 {
+        opts.MagFilter = gl.LINEAR
+    }
+    return opts
+}
+
+// Returns true for the min filters that sample mipmap levels
+func isMipmapFilter(filter int32) bool {
+    switch filter {
+    case gl.NEAREST_MIPMAP_NEAREST, gl.LINEAR_MIPMAP_NEAREST, gl.NEAREST_MIPMAP_LINEAR, gl.LINEAR_MIPMAP_LINEAR:
+        return true
+    }
+    return false
+}
+
+// Loads a texture file like LoadTexture, stored and sampled according to (opts)
+//
+// DDS and KTX2 files are uploaded compressed with their own mip levels, see NewCompressedTexture, and
+// have to hold a plain 2D texture, use LoadCompressedTexture for arrays and cubemaps.
+func LoadTextureWithOptions(filePath string, opts TextureOptions) (uint32, error) {
+    file, err := os.Open(filePath)
+    if err != nil {
+        return 0, err
+    }
+    defer file.Close()
+
+    img, compressed, err := decodeAnyTexture(bufio.NewReader(file))
+    if err != nil {
+        return 0, fmt.Errorf("%s: %w", filePath, err)
+    }
+    if compressed != nil {
+        texture, err := NewCompressedTexture(compressed, opts)
+        if err != nil {
+            return 0, fmt.Errorf("%s: %w", filePath, err)
+        }
+        return texture, nil
+    }
+    return NewTextureFromImage(img, opts)
+}
+
+// Decodes a texture file as an image, or as a CompressedTexture for DDS and KTX2, which have to hold a plain 2D texture
+func decodeAnyTexture(reader *bufio.Reader) (image.Image, *CompressedTexture, error) {
+    if header, _ := reader.Peek(12); isCompressedTexture(header) {
+        tex, err := DecodeCompressedTexture(reader)
+        if err != nil {
+            return nil, nil, err
+        }
+        if tex.Target() != gl.TEXTURE_2D {
+            return nil, nil, errors.New("holds an array or cubemap texture, load it with LoadCompressedTexture")
+        }
+        return nil, tex, nil
+    }
+    img, _, err := DecodeTexture(reader)
+    return img, nil, err
+}
+
+// Creates a 2D texture from an image, stored and sampled according to (opts)
+func NewTextureFromImage(img image.Image, opts TextureOptions) (uint32, error) {
+    opts = opts.withDefaults()
+    if opts.NoMipmaps && isMipmapFilter(opts.MinFilter) {
+        return 0, errors.New("a mipmap min filter needs mipmaps, but NoMipmaps is set")
+    }
+    texture := GenBindTexture()
+    uploadTexture2D(texture, img, opts)
+    return texture, nil
+}
+
+// Sets the wrap, filter, anisotropy and border parameters of the texture bound to (target)
+func setTextureParameters(target uint32, opts TextureOptions) {
+    gl.TexParameteri(target, gl.TEXTURE_WRAP_S, opts.WrapS)
+    gl.TexParameteri(target, gl.TEXTURE_WRAP_T, opts.WrapT)
+    gl.TexParameteri(target, gl.TEXTURE_MIN_FILTER, opts.MinFilter)
+    gl.TexParameteri(target, gl.TEXTURE_MAG_FILTER, opts.MagFilter)
+    gl.TexParameterfv(target, gl.TEXTURE_BORDER_COLOR, &opts.BorderColor[0])
+    if opts.MaxAnisotropy > 1 {
+        var limit float32
+        gl.GetFloatv(gl.MAX_TEXTURE_MAX_ANISOTROPY, &limit)
+        gl.TexParameterf(target, gl.TEXTURE_MAX_ANISOTROPY, min(opts.MaxAnisotropy, limit))
+    }
+}
+
+// Uploads (img) as level 0 of (texture) and sets its sampling parameters, replacing what was there before
+//
+// (opts) must already have its defaults filled in. The texture is left bound to GL_TEXTURE_2D.
+func uploadTexture2D(texture uint32, img image.Image, opts TextureOptions) {
+    BindTexture(texture)
+    setTextureParameters(gl.TEXTURE_2D, opts)
+
+    texImage2D(gl.TEXTURE_2D, img, opts)
+
+    if !opts.NoMipmaps {
+        gl.GenerateMipmap(gl.TEXTURE_2D)
+    }
+}
+
+// Uploads (img) as level 0 of (target), the bound 2D texture or a face of the bound cubemap
+func texImage2D(target uint32, img image.Image, opts TextureOptions) {
+    bounds := img.Bounds()
+    pixels := imagePixels(img, isSingleChannel(opts.InternalFormat), opts.PremultiplyAlpha)
+    gl.PixelStorei(gl.UNPACK_ALIGNMENT, 1)
+    gl.TexImage2D(target, 0, opts.InternalFormat, int32(bounds.Dx()), int32(bounds.Dy()), 0, pixels.format, pixels.xtype,
+        gl.Ptr(pixels.data))
+    gl.PixelStorei(gl.UNPACK_ALIGNMENT, 4)
+}
+
+// Uploads (img) into layer (layer) of the bound 2D array texture, which must already have storage
+func texSubImageLayer(layer int, img image.Image, opts TextureOptions) {
+    bounds := img.Bounds()
+    pixels := imagePixels(img, isSingleChannel(opts.InternalFormat), opts.PremultiplyAlpha)
+    gl.PixelStorei(gl.UNPACK_ALIGNMENT, 1)
+    gl.TexSubImage3D(gl.TEXTURE_2D_ARRAY, 0, 0, 0, int32(layer), int32(bounds.Dx()), int32(bounds.Dy()), 1,
+        pixels.format, pixels.xtype, gl.Ptr(pixels.data))
+    gl.PixelStorei(gl.UNPACK_ALIGNMENT, 4)
+}
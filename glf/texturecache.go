@@ -0,0 +1,134 @@
+// Texture Cache GL Helper Functions
+package glf
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"time"
+
+	"github.com/go-gl/gl/v4.6-core/gl"
+)
+
+type TextureInfo struct {
+    id          uint32
+    path        string
+    options     TextureOptions
+    modified    time.Time
+    refs        int
+}
+
+// Textures are shared by their file and the options they're stored with
+type textureKey struct {
+    path    string
+    options TextureOptions
+}
+
+var loadedTextures = make(map[textureKey]*TextureInfo)
+
+// Gets the texture for a file and options, loading it the first time, with a reference held for the caller
+//
+// Loading the same file with the same options again, unset options counting as their defaults, returns
+// the same texture instead of a new one. Every AcquireTexture needs a matching Release, the texture is
+// deleted with the last one. Like the shader functions this has to be called on the GL thread.
+func AcquireTexture(filePath string, opts TextureOptions) (*TextureInfo, error) {
+    opts = opts.withDefaults()
+    if opts.NoMipmaps && isMipmapFilter(opts.MinFilter) {
+        return nil, errors.New("a mipmap min filter needs mipmaps, but NoMipmaps is set")
+    }
+    if absolute, err := filepath.Abs(filePath); err == nil {
+        filePath = absolute
+    }
+    key := textureKey{filePath, opts}
+    if texture, ok := loadedTextures[key]; ok {
+        texture.refs++
+        return texture, nil
+    }
+
+    modified, err := textureModifiedTime(filePath)
+    if err != nil {
+        return nil, err
+    }
+    id, err := LoadTextureWithOptions(filePath, opts)
+    if err != nil {
+        return nil, err
+    }
+    texture := &TextureInfo{id, filePath, opts, modified, 1}
+    loadedTextures[key] = texture
+    return texture, nil
+}
+
+// Returns the GL name of the texture, which stays the same across reloads
+func (texture *TextureInfo) ID() uint32 {
+    return texture.id
+}
+
+// Simply binds the texture to the active texture unit
+func (texture *TextureInfo) Bind() {
+    BindTexture(texture.id)
+}
+
+// Drops a reference to the texture, and deletes it if it was the last one
+func (texture *TextureInfo) Release() {
+    if texture.refs <= 0 {
+        return
+    }
+    texture.refs--
+    if texture.refs > 0 {
+        return
+    }
+    delete(loadedTextures, textureKey{texture.path, texture.options})
+    gl.DeleteTextures(1, &texture.id)
+    texture.id = 0
+}
+
+// Checks to see if any of the acquired textures' files have been modified, and if so reloads them into the same texture
+func CheckTexturesforChanges() {
+    for _, texture := range loadedTextures {
+        modified, err := textureModifiedTime(texture.path)
+        if err != nil || modified.Equal(texture.modified) {
+            continue
+        }
+        fmt.Println("Reloading texture: " + texture.path)
+        if err := texture.reload(); err != nil {
+            if Verbose {
+                fmt.Printf("Could not reload texture, %s \n", err)
+            }
+        } else if Verbose {
+            fmt.Println("Reloaded texture")
+        }
+        texture.modified = modified
+    }
+}
+
+// Decodes the texture's file again and uploads it over the old contents, keeping the old ones if decoding fails
+func (texture *TextureInfo) reload() error {
+    file, err := os.Open(texture.path)
+    if err != nil {
+        return err
+    }
+    defer file.Close()
+
+    img, compressed, err := decodeAnyTexture(bufio.NewReader(file))
+    if err != nil {
+        return fmt.Errorf("%s: %w", texture.path, err)
+    }
+    if compressed != nil {
+        return uploadCompressed(texture.id, compressed, texture.options)
+    }
+    BindTexture(texture.id)
+    gl.TexParameteri(gl.TEXTURE_2D, gl.TEXTURE_MAX_LEVEL, 1000) // Undo the limit a compressed file sets
+    uploadTexture2D(texture.id, img, texture.options)
+    return nil
+}
+
+// Returns when a texture file was last modified, an error for files that are missing, say halfway through being saved
+func textureModifiedTime(filePath string) (time.Time, error) {
+    info, err := os.Stat(filePath)
+    if err != nil {
+        return time.Time{}, err
+    }
+    return info.ModTime(), nil
+}
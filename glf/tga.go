@@ -0,0 +1,191 @@
+// TGA Texture Decoder
+package glf
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"image"
+	"image/color"
+	"io"
+)
+
+// TGA image types
+const (
+    tgaColorMapped      = 1
+    tgaTrueColor        = 2
+    tgaGray             = 3
+    tgaRLEColorMapped   = 9
+    tgaRLETrueColor     = 10
+    tgaRLEGray          = 11
+)
+
+// TGA has no magic number, so this checks the header fields are ones a TGA could have
+func matchTGA(header []byte) bool {
+    if len(header) < 18 {
+        return false
+    }
+    colorMapType, imageType, depth := header[1], header[2], header[16]
+    width, height := binary.LittleEndian.Uint16(header[12:]), binary.LittleEndian.Uint16(header[14:])
+    if width == 0 || height == 0 || colorMapType > 1 {
+        return false
+    }
+    switch imageType {
+    case tgaColorMapped, tgaRLEColorMapped:
+        return colorMapType == 1 && (depth == 8 || depth == 16)
+    case tgaTrueColor, tgaRLETrueColor:
+        return depth == 15 || depth == 16 || depth == 24 || depth == 32
+    case tgaGray, tgaRLEGray:
+        return depth == 8 || depth == 16
+    }
+    return false
+}
+
+// Decodes a color mapped, true color or grayscale TGA, RLE or not, into an *image.NRGBA
+func decodeTGA(r io.Reader) (image.Image, error) {
+    data, err := io.ReadAll(r)
+    if err != nil {
+        return nil, err
+    }
+    if len(data) < 18 {
+        return nil, errors.New("tga: file is truncated")
+    }
+    idLength := int(data[0])
+    colorMapType, imageType := data[1], data[2]
+    mapFirst := int(binary.LittleEndian.Uint16(data[3:]))
+    mapLength := int(binary.LittleEndian.Uint16(data[5:]))
+    mapDepth := int(data[7])
+    width := int(binary.LittleEndian.Uint16(data[12:]))
+    height := int(binary.LittleEndian.Uint16(data[14:]))
+    depth := int(data[16])
+    descriptor := data[17]
+    alphaBits := descriptor & 0xf
+    rightToLeft := descriptor&0x10 != 0
+    topDown := descriptor&0x20 != 0
+
+    offset := 18 + idLength
+    var palette []color.NRGBA
+    if colorMapType == 1 {
+        entrySize := (mapDepth + 7) / 8
+        if offset+mapLength*entrySize > len(data) {
+            return nil, errors.New("tga: color map is truncated")
+        }
+        palette = make([]color.NRGBA, mapFirst+mapLength)
+        for i := 0; i < mapLength; i++ {
+            palette[mapFirst+i] = tgaColor(data[offset+i*entrySize:], mapDepth, alphaBits)
+        }
+        offset += mapLength * entrySize
+    }
+
+    pixelSize := (depth + 7) / 8
+    if pixelSize == 0 {
+        return nil, fmt.Errorf("tga: unsupported pixel depth %d", depth)
+    }
+
+    if width == 0 || height == 0 || width > 1<<15 || height > 1<<15 {
+        return nil, fmt.Errorf("tga: invalid size %dx%d", width, height)
+    }
+    if offset > len(data) {
+        return nil, errors.New("tga: pixel data is truncated")
+    }
+
+    // Unpack RLE packets so both kinds of image are read the same way
+    pixels := data[offset:]
+    count := width * height
+    switch imageType {
+    case tgaRLEColorMapped, tgaRLETrueColor, tgaRLEGray:
+        // Every packet takes at least a pixel and its header byte and gives at most 128 pixels, so a size the
+        // data can't fill is rejected before anything is allocated for it
+        if count > (len(pixels)+pixelSize)/(pixelSize+1)*128 {
+            return nil, errors.New("tga: pixel data is truncated")
+        }
+        unpacked := make([]byte, 0, count*pixelSize)
+        for i := 0; len(unpacked) < count*pixelSize && i < len(pixels); {
+            packet := pixels[i]
+            length := int(packet&0x7f) + 1
+            i++
+            if packet&0x80 != 0 {
+                if i+pixelSize > len(pixels) {
+                    break
+                }
+                for n := 0; n < length; n++ {
+                    unpacked = append(unpacked, pixels[i:i+pixelSize]...)
+                }
+                i += pixelSize
+            } else {
+                end := min(i+length*pixelSize, len(pixels))
+                unpacked = append(unpacked, pixels[i:end]...)
+                i = end
+            }
+        }
+        pixels = unpacked
+        imageType -= 8
+    case tgaColorMapped, tgaTrueColor, tgaGray:
+    default:
+        return nil, fmt.Errorf("tga: unsupported image type %d", imageType)
+    }
+    if len(pixels) < count*pixelSize {
+        return nil, errors.New("tga: pixel data is truncated")
+    }
+
+    img := image.NewNRGBA(image.Rect(0, 0, width, height))
+    for i := 0; i < count; i++ {
+        pixel := pixels[i*pixelSize:]
+        var c color.NRGBA
+        switch imageType {
+        case tgaColorMapped:
+            index := int(pixel[0])
+            if pixelSize == 2 {
+                index = int(binary.LittleEndian.Uint16(pixel))
+            }
+            if index < len(palette) {
+                c = palette[index]
+            }
+        case tgaTrueColor:
+            c = tgaColor(pixel, depth, alphaBits)
+        case tgaGray:
+            c = color.NRGBA{pixel[0], pixel[0], pixel[0], 0xff}
+            if pixelSize == 2 {
+                c.A = pixel[1]
+            }
+        }
+
+        x, y := i%width, i/width
+        if rightToLeft {
+            x = width - 1 - x
+        }
+        if !topDown {
+            y = height - 1 - y
+        }
+        at := y*img.Stride + x*4
+        img.Pix[at], img.Pix[at+1], img.Pix[at+2], img.Pix[at+3] = c.R, c.G, c.B, c.A
+    }
+    return img, nil
+}
+
+// Reads a little endian BGR(A) pixel of (depth) bits, ignoring alpha if the descriptor says there's none
+func tgaColor(pixel []byte, depth int, alphaBits byte) color.NRGBA {
+    switch depth {
+    case 15, 16:
+        value := binary.LittleEndian.Uint16(pixel)
+        c := color.NRGBA{
+            uint8((value >> 10 & 0x1f) * 255 / 31),
+            uint8((value >> 5 & 0x1f) * 255 / 31),
+            uint8((value & 0x1f) * 255 / 31),
+            0xff,
+        }
+        if depth == 16 && alphaBits == 1 && value&0x8000 == 0 {
+            c.A = 0
+        }
+        return c
+    case 24:
+        return color.NRGBA{pixel[2], pixel[1], pixel[0], 0xff}
+    case 32:
+        c := color.NRGBA{pixel[2], pixel[1], pixel[0], pixel[3]}
+        if alphaBits == 0 {
+            c.A = 0xff
+        }
+        return c
+    }
+    return color.NRGBA{}
+}
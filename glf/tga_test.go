@@ -0,0 +1,159 @@
+package glf
+
+import (
+	"bytes"
+	"encoding/binary"
+	"image"
+	"image/color"
+	"strings"
+	"testing"
+)
+
+// TGA header fields for makeTGA
+type tgaHeader struct {
+    imageType       byte
+    width, height   int
+    depth           byte
+    descriptor      byte
+    id              string
+    mapFirst        int
+    mapDepth        byte
+    palette         []byte // Color map entries, a color map is only written when this is set
+}
+
+// Builds a TGA file from its header and pixel data
+func makeTGA(h tgaHeader, pixels []byte) []byte {
+    data := make([]byte, 18)
+    data[0] = byte(len(h.id))
+    if h.palette != nil {
+        data[1] = 1
+        binary.LittleEndian.PutUint16(data[3:], uint16(h.mapFirst))
+        binary.LittleEndian.PutUint16(data[5:], uint16(len(h.palette)/int((h.mapDepth+7)/8)))
+        data[7] = h.mapDepth
+    }
+    data[2] = h.imageType
+    binary.LittleEndian.PutUint16(data[12:], uint16(h.width))
+    binary.LittleEndian.PutUint16(data[14:], uint16(h.height))
+    data[16] = h.depth
+    data[17] = h.descriptor
+    data = append(data, h.id...)
+    data = append(data, h.palette...)
+    return append(data, pixels...)
+}
+
+// Fails the test if (img) isn't an NRGBA holding (want), rows from the top
+func expectNRGBA(t *testing.T, name string, img image.Image, width int, want []color.NRGBA) {
+    t.Helper()
+    nrgba, ok := img.(*image.NRGBA)
+    if !ok {
+        t.Errorf("%s: decoded to %T, want *image.NRGBA", name, img)
+        return
+    }
+    if size := nrgba.Rect.Size(); size.X != width || size.X*size.Y != len(want) {
+        t.Errorf("%s: decoded to %v, want %d pixels across", name, nrgba.Rect, width)
+        return
+    }
+    for i, c := range want {
+        if got := nrgba.NRGBAAt(i%width, i/width); got != c {
+            t.Errorf("%s: pixel (%d, %d) is %v, want %v", name, i%width, i/width, got, c)
+        }
+    }
+}
+
+func TestDecodeTGA(t *testing.T) {
+    red, green, blue, white := color.NRGBA{255, 0, 0, 255}, color.NRGBA{0, 255, 0, 255}, color.NRGBA{0, 0, 255, 255}, color.NRGBA{255, 255, 255, 255}
+    tests := []struct {
+        name    string
+        file    []byte
+        width   int
+        want    []color.NRGBA
+    }{
+        {"24 bit bottom up", makeTGA(tgaHeader{imageType: tgaTrueColor, width: 2, height: 2, depth: 24},
+            []byte{0, 0, 255, 0, 255, 0, 255, 0, 0, 255, 255, 255}), 2, []color.NRGBA{blue, white, red, green}},
+        {"32 bit top down", makeTGA(tgaHeader{imageType: tgaTrueColor, width: 2, height: 1, depth: 32, descriptor: 0x28},
+            []byte{0, 0, 255, 128, 255, 0, 0, 0}), 2, []color.NRGBA{{255, 0, 0, 128}, {0, 0, 255, 0}}},
+        {"32 bit without alpha bits", makeTGA(tgaHeader{imageType: tgaTrueColor, width: 1, height: 1, depth: 32},
+            []byte{0, 0, 255, 0}), 1, []color.NRGBA{red}},
+        {"right to left", makeTGA(tgaHeader{imageType: tgaTrueColor, width: 2, height: 1, depth: 24, descriptor: 0x10},
+            []byte{0, 0, 255, 0, 255, 0}), 2, []color.NRGBA{green, red}},
+        {"16 bit with alpha bit", makeTGA(tgaHeader{imageType: tgaTrueColor, width: 2, height: 1, depth: 16, descriptor: 0x21},
+            []byte{0x00, 0xfc, 0x1f, 0x00}), 2, []color.NRGBA{red, {0, 0, 255, 0}}},
+        {"15 bit", makeTGA(tgaHeader{imageType: tgaTrueColor, width: 1, height: 1, depth: 15},
+            []byte{0xe0, 0x03}), 1, []color.NRGBA{green}},
+        {"gray", makeTGA(tgaHeader{imageType: tgaGray, width: 1, height: 1, depth: 8, id: "test"},
+            []byte{100}), 1, []color.NRGBA{{100, 100, 100, 255}}},
+        {"gray with alpha", makeTGA(tgaHeader{imageType: tgaGray, width: 1, height: 1, depth: 16},
+            []byte{100, 50}), 1, []color.NRGBA{{100, 100, 100, 50}}},
+        {"color mapped", makeTGA(tgaHeader{imageType: tgaColorMapped, width: 3, height: 1, depth: 8, descriptor: 0x20, mapFirst: 1, mapDepth: 24,
+            palette: []byte{0, 0, 255, 0, 255, 0}}, []byte{2, 1, 0}), 3, []color.NRGBA{green, red, {}}},
+        {"RLE", makeTGA(tgaHeader{imageType: tgaRLETrueColor, width: 4, height: 1, depth: 24, descriptor: 0x20},
+            []byte{0x82, 0, 0, 255, 0x00, 255, 0, 0}), 4, []color.NRGBA{red, red, red, blue}},
+        {"RLE gray", makeTGA(tgaHeader{imageType: tgaRLEGray, width: 3, height: 1, depth: 8, descriptor: 0x20},
+            []byte{0x01, 10, 20, 0x80, 30}), 3, []color.NRGBA{{10, 10, 10, 255}, {20, 20, 20, 255}, {30, 30, 30, 255}}},
+    }
+    for _, test := range tests {
+        if !matchTGA(test.file) {
+            t.Errorf("%s: matchTGA is false", test.name)
+        }
+        img, err := decodeTGA(bytes.NewReader(test.file))
+        if err != nil {
+            t.Errorf("%s: %v", test.name, err)
+            continue
+        }
+        expectNRGBA(t, test.name, img, test.width, test.want)
+    }
+}
+
+func TestDecodeTGAErrors(t *testing.T) {
+    // The ID length says 255 bytes follow the header, but none do
+    idTruncated := makeTGA(tgaHeader{imageType: tgaTrueColor, width: 1, height: 1, depth: 24}, nil)
+    idTruncated[0] = 255
+
+    tests := []struct {
+        name    string
+        file    []byte
+        want    string
+    }{
+        {"header", make([]byte, 10), "file is truncated"},
+        {"image type", makeTGA(tgaHeader{imageType: 32, width: 1, height: 1, depth: 24}, []byte{0, 0, 0}), "unsupported image type 32"},
+        {"depth", makeTGA(tgaHeader{imageType: tgaTrueColor, width: 1, height: 1}, []byte{0, 0, 0}), "unsupported pixel depth 0"},
+        {"pixels", makeTGA(tgaHeader{imageType: tgaTrueColor, width: 2, height: 2, depth: 24}, make([]byte, 11)), "pixel data is truncated"},
+        {"RLE pixels", makeTGA(tgaHeader{imageType: tgaRLETrueColor, width: 4, height: 1, depth: 24}, []byte{0x81, 0, 0, 0}), "pixel data is truncated"},
+        {"ID", idTruncated, "pixel data is truncated"},
+        {"color map", makeTGA(tgaHeader{imageType: tgaColorMapped, width: 1, height: 1, depth: 8, mapDepth: 24, palette: make([]byte, 6)}, nil)[:20], "color map is truncated"},
+        {"too big", makeTGA(tgaHeader{imageType: tgaTrueColor, width: 1<<15 + 1, height: 1, depth: 24}, nil), "invalid size 32769x1"},
+        // An 18 byte header asking for 32768x32768 RLE pixels, which mustn't be allocated before the data is read
+        {"RLE too big", makeTGA(tgaHeader{imageType: tgaRLETrueColor, width: 1 << 15, height: 1 << 15, depth: 32}, []byte{0xff, 0, 0, 0, 0}), "pixel data is truncated"},
+    }
+    for _, test := range tests {
+        _, err := decodeTGA(bytes.NewReader(test.file))
+        if err == nil || !strings.HasPrefix(err.Error(), "tga: ") || !strings.Contains(err.Error(), test.want) {
+            t.Errorf("%s: got error %v, want one containing %q", test.name, err, test.want)
+        }
+    }
+}
+
+func TestMatchTGA(t *testing.T) {
+    tests := []struct {
+        name    string
+        header  tgaHeader
+        want    bool
+    }{
+        {"true color", tgaHeader{imageType: tgaTrueColor, width: 1, height: 1, depth: 32}, true},
+        {"RLE gray", tgaHeader{imageType: tgaRLEGray, width: 1, height: 1, depth: 8}, true},
+        {"color mapped", tgaHeader{imageType: tgaColorMapped, width: 1, height: 1, depth: 8, mapDepth: 24, palette: make([]byte, 3)}, true},
+        {"color mapped without a map", tgaHeader{imageType: tgaColorMapped, width: 1, height: 1, depth: 8}, false},
+        {"no width", tgaHeader{imageType: tgaTrueColor, height: 1, depth: 32}, false},
+        {"odd depth", tgaHeader{imageType: tgaTrueColor, width: 1, height: 1, depth: 12}, false},
+        {"gray depth", tgaHeader{imageType: tgaGray, width: 1, height: 1, depth: 24}, false},
+        {"no image", tgaHeader{width: 1, height: 1, depth: 24}, false},
+    }
+    for _, test := range tests {
+        if got := matchTGA(makeTGA(test.header, nil)); got != test.want {
+            t.Errorf("%s: matchTGA is %v, want %v", test.name, got, test.want)
+        }
+    }
+    if matchTGA(make([]byte, 17)) {
+        t.Error("matchTGA is true for a header shorter than 18 bytes")
+    }
+}
@@ -0,0 +1,160 @@
+// GL Thread Helper Functions
+package glf
+
+/*
+#include <stdint.h>
+#ifdef _WIN32
+#include <windows.h>
+#else
+#include <pthread.h>
+#endif
+
+// Identifies the calling OS thread
+static uint64_t glfCurrentThread(void) {
+#ifdef _WIN32
+    return (uint64_t)GetCurrentThreadId();
+#else
+    return (uint64_t)(uintptr_t)pthread_self();
+#endif
+}
+*/
+import "C"
+
+import (
+	"runtime"
+	"sync"
+	"sync/atomic"
+)
+
+// GLThread runs closures in order on one locked OS thread, the thread a GL context is current on
+//
+// GL calls are only valid on the thread that owns the context, so goroutines hand their GL work to a
+// GLThread instead of making the calls themselves.
+type GLThread struct {
+    commands    chan func()
+    done        chan struct{}
+    osThread    atomic.Uint64   // OS thread the run loop is locked to, 0 before it starts and after it stops
+    closeOnce   sync.Once
+}
+
+// Starts a new GL thread and runs (init) on it before anything else, usually to create or make current a context
+//
+// If (init) fails the thread is stopped and the error returned
+func NewGLThread(init func() error) (*GLThread, error) {
+    thread := &GLThread{
+        commands:   make(chan func(), 64),
+        done:       make(chan struct{}),
+    }
+    started := make(chan error)
+    go thread.run(init, started)
+    if err := <-started; err != nil {
+        return nil, err
+    }
+    return thread, nil
+}
+
+// Locks the OS thread, runs (init), then runs commands until the queue is closed
+func (thread *GLThread) run(init func() error, started chan<- error) {
+    runtime.LockOSThread()
+    defer runtime.UnlockOSThread()
+    defer close(thread.done)
+
+    // No other goroutine runs on a locked OS thread, so the thread identifies this goroutine
+    thread.osThread.Store(uint64(C.glfCurrentThread()))
+    defer thread.osThread.Store(0)
+    if init != nil {
+        if err := init(); err != nil {
+            started <- err
+            return
+        }
+    }
+    started <- nil
+
+    for command := range thread.commands {
+        command()
+    }
+}
+
+// Returns true if called from a command running on the thread
+func (thread *GLThread) OnThread() bool {
+    id := thread.osThread.Load()
+    return id != 0 && id == uint64(C.glfCurrentThread())
+}
+
+// Runs f on the GL thread after everything queued before it, and waits for it to return
+//
+// Called from the GL thread itself f just runs right away, so commands can use Call without deadlocking.
+// A panic in f is passed on to the caller.
+func (thread *GLThread) Call(f func()) {
+    if thread.OnThread() {
+        f()
+        return
+    }
+    var recovered any
+    <-thread.Submit(func() {
+        defer func() {
+            recovered = recover()
+        }()
+        f()
+    })
+    if recovered != nil {
+        panic(recovered)
+    }
+}
+
+// Queues f to run on the GL thread, and returns a channel that's closed once it has run
+func (thread *GLThread) Submit(f func()) <-chan struct{} {
+    finished := make(chan struct{})
+    thread.commands <- func() {
+        defer close(finished)
+        f()
+    }
+    return finished
+}
+
+// Stops the thread once the commands already queued have run, and waits for it to exit
+//
+// Nothing can be submitted after Close, and it must not be called from the GL thread
+func (thread *GLThread) Close() {
+    thread.closeOnce.Do(func() {
+        close(thread.commands)
+    })
+    <-thread.done
+}
+
+// Returns the GL thread the ShaderManager's work has to be sent to, or nil if it can run on the caller
+func (sm *ShaderManager[T]) glThread() *GLThread {
+    if sm.Context == nil || sm.Context.Thread == nil || sm.Context.Thread.OnThread() {
+        return nil
+    }
+    return sm.Context.Thread
+}
+
+// Loads a texture on the GL thread, see LoadTexture
+func (thread *GLThread) LoadTexture(filePath string) uint32 {
+    var texture uint32
+    thread.Call(func() {
+        texture = LoadTexture(filePath)
+    })
+    return texture
+}
+
+// Loads a texture with options on the GL thread, see LoadTextureWithOptions
+func (thread *GLThread) LoadTextureWithOptions(filePath string, opts TextureOptions) (uint32, error) {
+    var texture uint32
+    var err error
+    thread.Call(func() {
+        texture, err = LoadTextureWithOptions(filePath, opts)
+    })
+    return texture, err
+}
+
+// Checks the loaded shaders for changes on the GL thread, see CheckShadersforChanges
+func (thread *GLThread) CheckShadersforChanges() {
+    thread.Call(CheckShadersforChanges)
+}
+
+// Checks the acquired textures for changes on the GL thread, see CheckTexturesforChanges
+func (thread *GLThread) CheckTexturesforChanges() {
+    thread.Call(CheckTexturesforChanges)
+}
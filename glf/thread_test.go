@@ -0,0 +1,90 @@
+package glf
+
+import (
+	"testing"
+	"time"
+)
+
+func TestGLThreadOnThread(t *testing.T) {
+    thread, err := NewGLThread(nil)
+    if err != nil {
+        t.Fatal(err)
+    }
+    defer thread.Close()
+
+    if thread.OnThread() {
+        t.Error("OnThread is true on the test goroutine")
+    }
+    var inside, nested bool
+    thread.Call(func() {
+        inside = thread.OnThread()
+        // Would deadlock if Call didn't know it's already on the thread
+        thread.Call(func() {
+            nested = thread.OnThread()
+        })
+    })
+    if !inside || !nested {
+        t.Errorf("OnThread in a command is %v, in a nested Call %v, want true", inside, nested)
+    }
+}
+
+// Another goroutine calling while a command runs must queue its work, not run it where it is
+func TestGLThreadOnThreadDuringCommand(t *testing.T) {
+    thread, err := NewGLThread(nil)
+    if err != nil {
+        t.Fatal(err)
+    }
+    defer thread.Close()
+
+    running := make(chan struct{})
+    release := make(chan struct{})
+    finished := thread.Submit(func() {
+        close(running)
+        <-release
+    })
+    <-running
+
+    if thread.OnThread() {
+        t.Error("OnThread is true on another goroutine while a command runs")
+    }
+    called := make(chan bool)
+    go func() {
+        var onThread bool
+        thread.Call(func() {
+            onThread = thread.OnThread()
+        })
+        called <- onThread
+    }()
+    select {
+    case <-called:
+        t.Fatal("Call returned while the thread was still busy with another command")
+    case <-time.After(20 * time.Millisecond):
+    }
+    close(release)
+    <-finished
+    if !<-called {
+        t.Error("the queued call didn't run on the thread")
+    }
+}
+
+func TestGLThreadOnThreadAfterClose(t *testing.T) {
+    thread, err := NewGLThread(nil)
+    if err != nil {
+        t.Fatal(err)
+    }
+    thread.Close()
+    if thread.OnThread() {
+        t.Error("OnThread is true after Close")
+    }
+}
+
+func BenchmarkGLThreadOnThread(b *testing.B) {
+    thread, err := NewGLThread(nil)
+    if err != nil {
+        b.Fatal(err)
+    }
+    defer thread.Close()
+    for i := 0; i < b.N; i++ {
+        thread.OnThread()
+    }
+}